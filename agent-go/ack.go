@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 任务结果确认: 每次等待服务端 ack 的时间与最多发送次数
+const (
+	taskResultAckTimeout = 5 * time.Second
+	taskResultAttempts   = 3
+)
+
+// newResultID 生成任务结果的幂等 ID
+func newResultID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// encodeEvent 编码 Socket.IO 事件消息
+// ackID < 0 时为普通事件 42/agent,["event",data]，否则为 42/agent,<id>["event",data]
+func encodeEvent(ackID int, event string, data interface{}) (string, error) {
+	jsonData, err := json.Marshal([]interface{}{event, data})
+	if err != nil {
+		return "", err
+	}
+	if ackID < 0 {
+		return "42/agent," + string(jsonData), nil
+	}
+	return "42/agent," + strconv.Itoa(ackID) + string(jsonData), nil
+}
+
+// parseAckPacket 解析服务端的确认消息 43/agent,<id>[args...]
+func parseAckPacket(msg string) (int, json.RawMessage, bool) {
+	if !strings.HasPrefix(msg, "43/agent,") {
+		return 0, nil, false
+	}
+	rest := msg[9:]
+	end := strings.IndexByte(rest, '[')
+	if end <= 0 {
+		return 0, nil, false
+	}
+	id, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, nil, false
+	}
+	return id, json.RawMessage(rest[end:]), true
+}
+
+// emitWithAck 发送带确认 ID 的事件，服务端调用 ack 回调后返回的通道收到其参数
+// 发送失败时不缓存，由调用方决定是否重试
+func (a *AgentClient) emitWithAck(event string, data interface{}) (int, <-chan json.RawMessage, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		return 0, nil, fmt.Errorf("未连接")
+	}
+
+	a.ackSeq++
+	id := a.ackSeq
+	msg, err := encodeEvent(id, event, data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ch := make(chan json.RawMessage, 1)
+	a.ackWaiters[id] = ch
+	if err := a.writeFrame(msg); err != nil {
+		delete(a.ackWaiters, id)
+		return 0, nil, err
+	}
+	return id, ch, nil
+}
+
+// resolveAck 将服务端的确认交给对应的等待方 (未知或已超时的 ID 直接忽略)
+func (a *AgentClient) resolveAck(id int, args json.RawMessage) {
+	a.mu.Lock()
+	ch, ok := a.ackWaiters[id]
+	delete(a.ackWaiters, id)
+	a.mu.Unlock()
+	if ok {
+		ch <- args
+	}
+}
+
+// cancelAck 放弃等待某个确认
+func (a *AgentClient) cancelAck(id int) {
+	a.mu.Lock()
+	delete(a.ackWaiters, id)
+	a.mu.Unlock()
+}
+
+// sendTaskResult 上报任务结果并等待服务端确认，未确认时重发
+// 最后一次仍因断线发送失败时交给 emit，进入缓冲区等待重连后补发
+// 每个结果带有 result_id，服务端据此丢弃重发造成的重复结果
+func (a *AgentClient) sendTaskResult(result map[string]interface{}) {
+	if _, ok := result["result_id"]; !ok {
+		result["result_id"] = newResultID()
+	}
+	// 超出大小限制的事件会被服务端直接断开连接，重发也无济于事: 改为上报错误
+	if msg, err := encodeEvent(0, EventAgentTaskResult, result); err == nil && len(msg) > maxEventPayloadSize {
+		logger.Warnf("[Agent] 任务结果过大 (%d 字节)，改为上报错误", len(msg))
+		result["successful"] = false
+		result["data"] = fmt.Sprintf("任务结果过大 (%d 字节)，超出单条消息限制", len(msg))
+	}
+
+	a.mu.Lock()
+	acked := a.taskResultAck
+	a.mu.Unlock()
+	if !acked {
+		// 旧版服务端不会确认，等不到 ack 不代表没有收到: 只发送一次，写入失败时由 emit 缓存并在重连后补发
+		a.emit(EventAgentTaskResult, result)
+		return
+	}
+
+	var err error
+	for attempt := 1; attempt <= taskResultAttempts; attempt++ {
+		var id int
+		var ch <-chan json.RawMessage
+		id, ch, err = a.emitWithAck(EventAgentTaskResult, result)
+		if err != nil {
+			logger.Warnf("[Agent] 任务结果发送失败 (第 %d 次): %v", attempt, err)
+		} else {
+			select {
+			case <-ch:
+				return
+			case <-a.stopChan:
+				a.cancelAck(id)
+				return
+			case <-time.After(taskResultAckTimeout):
+				a.cancelAck(id)
+				logger.Warnf("[Agent] 任务结果未收到服务端确认 (第 %d 次)", attempt)
+			}
+		}
+
+		if err != nil && attempt < taskResultAttempts {
+			select {
+			case <-a.stopChan:
+				return
+			case <-time.After(taskResultAckTimeout):
+			}
+		}
+	}
+
+	if err != nil {
+		a.emit(EventAgentTaskResult, result)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAckTestClient 返回使用 fakeConn 的已连接客户端
+func newAckTestClient(taskResultAck bool) (*AgentClient, *fakeConn) {
+	a := NewAgentClient(newDefaultConfig())
+	conn := &fakeConn{}
+	a.conn = conn
+	a.taskResultAck = taskResultAck
+	return a, conn
+}
+
+// TestSendTaskResultWithoutAckSupport 服务端未声明确认任务结果时只发送一次，不等待 ack
+func TestSendTaskResultWithoutAckSupport(t *testing.T) {
+	a, conn := newAckTestClient(false)
+
+	start := time.Now()
+	a.sendTaskResult(map[string]interface{}{"id": "task-1", "successful": true})
+	if elapsed := time.Since(start); elapsed >= taskResultAckTimeout {
+		t.Errorf("sendTaskResult() 等待了 %v", elapsed)
+	}
+
+	if len(conn.frames) != 1 {
+		t.Fatalf("发送了 %d 帧, want 1: %v", len(conn.frames), conn.frames)
+	}
+	frame := conn.frames[0]
+	if !strings.HasPrefix(frame, `42/agent,["agent:task_result",`) {
+		t.Errorf("帧 = %q, want 不带 ack ID 的事件", frame)
+	}
+	if !strings.Contains(frame, `"result_id":"`) {
+		t.Errorf("帧 = %q, 缺少 result_id", frame)
+	}
+}
+
+// TestSendTaskResultAcked 服务端确认后不再重发，重发时 result_id 保持不变
+func TestSendTaskResultAcked(t *testing.T) {
+	a, conn := newAckTestClient(true)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.sendTaskResult(map[string]interface{}{"id": "task-2", "successful": true})
+	}()
+
+	// 等待第一次发送后确认
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn.mu.Lock()
+		n := len(conn.frames)
+		conn.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("任务结果未发送")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	a.resolveAck(1, json.RawMessage(`[{"ok":true}]`))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("收到确认后 sendTaskResult() 未返回")
+	}
+	if len(conn.frames) != 1 || !strings.HasPrefix(conn.frames[0], `42/agent,1["agent:task_result",`) {
+		t.Errorf("frames = %v, want 一个 ack ID 为 1 的事件", conn.frames)
+	}
+}
+
+// TestSendTaskResultKeepsResultID 调用方已设置的 result_id 不会被覆盖
+func TestSendTaskResultKeepsResultID(t *testing.T) {
+	a, conn := newAckTestClient(false)
+	a.sendTaskResult(map[string]interface{}{"id": "task-3", "result_id": "fixed"})
+	if len(conn.frames) != 1 || !strings.Contains(conn.frames[0], `"result_id":"fixed"`) {
+		t.Errorf("frames = %v", conn.frames)
+	}
+	if newResultID() == newResultID() {
+		t.Errorf("newResultID() 返回了重复的 ID")
+	}
+}
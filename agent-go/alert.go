@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// AlertRule 本地阈值告警规则
+type AlertRule struct {
+	Metric    string  `json:"metric"`    // cpu / mem_used_pct / disk_used_pct / load1
+	Op        string  `json:"op"`        // > / >= / < / <=
+	Threshold float64 `json:"threshold"` //
+	Webhook   string  `json:"webhook"`   // 告警和恢复通知以 JSON POST 到该地址
+}
+
+// alertMetrics 支持的告警指标
+var alertMetrics = map[string]bool{"cpu": true, "mem_used_pct": true, "disk_used_pct": true, "load1": true}
+
+// AlertEvent 发送到 Webhook 的告警/恢复通知
+type AlertEvent struct {
+	Status    string  `json:"status"` // firing / resolved
+	Metric    string  `json:"metric"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	Value     float64 `json:"value"`
+	Hostname  string  `json:"hostname"`
+	ServerID  string  `json:"server_id"`
+	Time      int64   `json:"time"` // Unix 秒
+}
+
+// alertEvaluator 对每次采集结果评估告警规则，只在进入/离开告警状态时通知 (避免每个采样都发送)
+type alertEvaluator struct {
+	mu       sync.Mutex
+	client   *http.Client
+	breached map[int]bool // 规则下标 -> 是否处于告警状态
+}
+
+func newAlertEvaluator(config *Config) *alertEvaluator {
+	transport := &http.Transport{}
+	if err := applyTransportProxy(config, transport); err != nil {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	return &alertEvaluator{
+		client:   &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		breached: make(map[int]bool),
+	}
+}
+
+// newCollectorAlerts 配置了告警规则时创建评估器
+func newCollectorAlerts(config *Config) *alertEvaluator {
+	if len(config.Alerts) == 0 {
+		return nil
+	}
+	return newAlertEvaluator(config)
+}
+
+// validateAlertRule 校验单条告警规则
+func validateAlertRule(rule AlertRule) error {
+	if !alertMetrics[rule.Metric] {
+		return fmt.Errorf("不支持的指标 %q (可选 cpu、mem_used_pct、disk_used_pct、load1)", rule.Metric)
+	}
+	switch rule.Op {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("不支持的比较运算符 %q", rule.Op)
+	}
+	if u, err := url.Parse(rule.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的 webhook 地址 %q", rule.Webhook)
+	}
+	return nil
+}
+
+// alertMetricValue 从采集结果中取出告警指标，memTotal/diskTotal 来自主机信息 (为 0 时指标不可用)
+func alertMetricValue(metric string, state *State, memTotal, diskTotal uint64) (float64, bool) {
+	switch metric {
+	case "cpu":
+		return state.CPU, true
+	case "load1":
+		return state.Load1, true
+	case "mem_used_pct":
+		if memTotal == 0 {
+			return 0, false
+		}
+		return float64(state.MemUsed) / float64(memTotal) * 100, true
+	case "disk_used_pct":
+		if diskTotal == 0 {
+			return 0, false
+		}
+		return float64(state.DiskUsed) / float64(diskTotal) * 100, true
+	}
+	return 0, false
+}
+
+// compareThreshold 按运算符比较
+func compareThreshold(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	}
+	return false
+}
+
+// evaluate 评估所有规则，状态发生变化的规则异步发送通知
+func (e *alertEvaluator) evaluate(config *Config, state *State, memTotal, diskTotal uint64) {
+	hostname := GetHostname(config)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, rule := range config.Alerts {
+		value, ok := alertMetricValue(rule.Metric, state, memTotal, diskTotal)
+		if !ok {
+			continue
+		}
+		breach := compareThreshold(value, rule.Op, rule.Threshold)
+		if breach == e.breached[i] {
+			continue
+		}
+		e.breached[i] = breach
+
+		status := "resolved"
+		if breach {
+			status = "firing"
+		}
+		event := AlertEvent{
+			Status:    status,
+			Metric:    rule.Metric,
+			Op:        rule.Op,
+			Threshold: rule.Threshold,
+			Value:     value,
+			Hostname:  hostname,
+			ServerID:  config.ServerID,
+			Time:      time.Now().Unix(),
+		}
+		logger.Warnf("[Alert] %s: %s %.2f %s %.2f", status, rule.Metric, value, rule.Op, rule.Threshold)
+		go e.send(rule.Webhook, event)
+	}
+}
+
+// send 发送通知 (失败只记录日志，不重试)
+func (e *alertEvaluator) send(webhook string, event AlertEvent) {
+	body, _ := json.Marshal(event)
+	resp, err := e.client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Warnf("[Alert] 发送 Webhook 失败: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		logger.Warnf("[Alert] Webhook 返回 HTTP %d", resp.StatusCode)
+	}
+}
+
+// alertLoop 未连接 Dashboard 时 (或 --no-report) 按上报间隔自行采集，保证本地告警不依赖与面板的连接
+// 已连接时 reportLoop 的每次采集都会评估告警，这里跳过避免重复采集
+func (a *AgentClient) alertLoop() {
+	interval, _ := a.reportIntervals()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			reporting := a.reporting
+			a.mu.Unlock()
+			if !reporting || a.cfg().NoReport {
+				// 内存/磁盘占比依赖主机信息中的总量
+				a.collector.mu.Lock()
+				hasHostInfo := a.collector.cachedHostInfo != nil
+				a.collector.mu.Unlock()
+				if !hasHostInfo {
+					a.collector.CollectHostInfo()
+				}
+				a.collector.CollectState()
+			}
+		}
+	}
+}
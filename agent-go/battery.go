@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 电池状态采集间隔: 电量变化缓慢；没有电池的主机 (台式机、服务器) 降低检测频率
+const (
+	batteryInterval       = time.Minute
+	batteryAbsentInterval = 30 * time.Minute
+	batteryCommandTimeout = 5 * time.Second
+)
+
+// BatteryStat 电池状态 (笔记本、带电池的边缘设备；多块电池时为汇总)
+type BatteryStat struct {
+	Percent     float64 `json:"percent"`      // 剩余电量 %
+	Charging    bool    `json:"charging"`     // 是否正在充电
+	SecondsLeft int64   `json:"seconds_left"` // 放电时的预计剩余时间 (秒)，充电中或未知为 0
+}
+
+// collectBatteryState 电池状态 (没有电池时为 nil，不上报该字段)
+func (c *Collector) collectBatteryState() func(*State) {
+	c.mu.Lock()
+	interval := batteryInterval
+	if c.lastBattery == nil {
+		interval = batteryAbsentInterval
+	}
+	if !c.lastBatteryTime.IsZero() && time.Since(c.lastBatteryTime) < interval {
+		battery := c.lastBattery
+		c.mu.Unlock()
+		return func(s *State) { s.Battery = battery }
+	}
+	c.lastBatteryTime = time.Now()
+	c.mu.Unlock()
+
+	battery := readBattery()
+
+	c.mu.Lock()
+	c.lastBattery = battery
+	c.mu.Unlock()
+	return func(s *State) { s.Battery = battery }
+}
+
+// readBattery 按平台读取电池状态
+func readBattery() *BatteryStat {
+	switch runtime.GOOS {
+	case "linux":
+		return readSysfsBatteries("/sys/class/power_supply")
+	case "darwin":
+		ctx, cancel := context.WithTimeout(context.Background(), batteryCommandTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "pmset", "-g", "batt").Output()
+		if err != nil {
+			return nil
+		}
+		return parsePmsetBattery(string(out))
+	case "windows":
+		ctx, cancel := context.WithTimeout(context.Background(), batteryCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
+			"Get-CimInstance Win32_Battery | Select-Object EstimatedChargeRemaining,BatteryStatus,EstimatedRunTime | ConvertTo-Json")
+		hideWindow(cmd)
+		out, err := cmd.Output()
+		if err != nil {
+			return nil
+		}
+		return parseWin32Battery(out)
+	}
+	return nil
+}
+
+// readSysfsBatteries 汇总 /sys/class/power_supply 下的系统电池 (忽略鼠标、键盘等外设电池)
+func readSysfsBatteries(root string) *BatteryStat {
+	dirs, _ := filepath.Glob(filepath.Join(root, "*"))
+
+	var percentSum, energyNow, energyFull, powerNow float64
+	var count int
+	charging, energyKnown := false, true
+	for _, dir := range dirs {
+		read := func(name string) string {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(string(data))
+		}
+		if read("type") != "Battery" || read("scope") == "Device" || read("present") == "0" {
+			continue
+		}
+
+		capacity, err := strconv.ParseFloat(read("capacity"), 64)
+		if err != nil {
+			continue
+		}
+		count++
+		percentSum += capacity
+		if read("status") == "Charging" {
+			charging = true
+		}
+
+		// 剩余时间: 能量 (µWh/µW) 或电荷 (µAh/µA) 二选一，两者比值相同
+		now, full, rate := read("energy_now"), read("energy_full"), read("power_now")
+		if now == "" {
+			now, full, rate = read("charge_now"), read("charge_full"), read("current_now")
+		}
+		n, err1 := strconv.ParseFloat(now, 64)
+		f, err2 := strconv.ParseFloat(full, 64)
+		r, err3 := strconv.ParseFloat(rate, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			energyKnown = false
+			continue
+		}
+		energyNow += n
+		energyFull += f
+		powerNow += r
+	}
+	if count == 0 {
+		return nil
+	}
+
+	battery := &BatteryStat{Percent: percentSum / float64(count), Charging: charging}
+	if energyKnown && energyFull > 0 {
+		battery.Percent = energyNow / energyFull * 100
+		if !charging && powerNow > 0 {
+			battery.SecondsLeft = int64(energyNow / powerNow * 3600)
+		}
+	}
+	return battery
+}
+
+// pmsetBatteryLine pmset -g batt 中的电池行，如 "-InternalBattery-0 (id=123)	85%; discharging; 4:12 remaining"
+var pmsetBatteryLine = regexp.MustCompile(`(\d+)%;\s*([^;]+);\s*(?:(\d+):(\d+) remaining)?`)
+
+// parsePmsetBattery 解析 macOS pmset -g batt 的输出 (没有电池时返回 nil)
+func parsePmsetBattery(output string) *BatteryStat {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "InternalBattery") {
+			continue
+		}
+		m := pmsetBatteryLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		percent, _ := strconv.ParseFloat(m[1], 64)
+		state := strings.TrimSpace(m[2])
+		battery := &BatteryStat{Percent: percent, Charging: state == "charging"}
+		if state == "discharging" && m[3] != "" {
+			hours, _ := strconv.ParseInt(m[3], 10, 64)
+			minutes, _ := strconv.ParseInt(m[4], 10, 64)
+			battery.SecondsLeft = hours*3600 + minutes*60
+		}
+		return battery
+	}
+	return nil
+}
+
+// win32Battery Win32_Battery 中用到的字段
+type win32Battery struct {
+	EstimatedChargeRemaining float64 `json:"EstimatedChargeRemaining"`
+	BatteryStatus            int     `json:"BatteryStatus"`    // 1 放电，2 接通电源，6-9 充电
+	EstimatedRunTime         int64   `json:"EstimatedRunTime"` // 分钟，接通电源时为 71582788
+}
+
+// parseWin32Battery 解析 Win32_Battery 的 JSON (单块电池时 ConvertTo-Json 输出对象而不是数组)
+func parseWin32Battery(data []byte) *BatteryStat {
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) == 0 {
+		return nil
+	}
+	var batteries []win32Battery
+	if data[0] == '{' {
+		var one win32Battery
+		if err := json.Unmarshal(data, &one); err != nil {
+			return nil
+		}
+		batteries = []win32Battery{one}
+	} else if err := json.Unmarshal(data, &batteries); err != nil || len(batteries) == 0 {
+		return nil
+	}
+
+	b := batteries[0]
+	battery := &BatteryStat{
+		Percent:  b.EstimatedChargeRemaining,
+		Charging: b.BatteryStatus >= 6 && b.BatteryStatus <= 9,
+	}
+	if b.BatteryStatus == 1 && b.EstimatedRunTime > 0 && b.EstimatedRunTime < 71582788 {
+		battery.SecondsLeft = b.EstimatedRunTime * 60
+	}
+	return battery
+}
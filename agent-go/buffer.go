@@ -0,0 +1,46 @@
+package main
+
+import "time"
+
+// bufferedEvent 发送失败、等待重连后补发的事件
+type bufferedEvent struct {
+	Event    string
+	Message  string // 已编码的 Socket.IO 消息
+	QueuedAt time.Time
+}
+
+// eventBuffer 固定容量的环形缓冲区，写满时覆盖最旧的事件 (非并发安全，由调用方加锁)
+type eventBuffer struct {
+	items []bufferedEvent
+	head  int // 最旧事件的位置
+	count int
+}
+
+// newEventBuffer 创建容量为 size 的缓冲区，size <= 0 时返回 nil (不缓冲)
+func newEventBuffer(size int) *eventBuffer {
+	if size <= 0 {
+		return nil
+	}
+	return &eventBuffer{items: make([]bufferedEvent, size)}
+}
+
+// push 追加事件，缓冲区已满时丢弃最旧的事件
+func (b *eventBuffer) push(e bufferedEvent) {
+	if b.count < len(b.items) {
+		b.items[(b.head+b.count)%len(b.items)] = e
+		b.count++
+		return
+	}
+	b.items[b.head] = e
+	b.head = (b.head + 1) % len(b.items)
+}
+
+// drain 按入队顺序取出并清空所有事件
+func (b *eventBuffer) drain() []bufferedEvent {
+	events := make([]bufferedEvent, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		events = append(events, b.items[(b.head+i)%len(b.items)])
+	}
+	b.head, b.count = 0, 0
+	return events
+}
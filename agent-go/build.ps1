@@ -11,7 +11,10 @@ New-Item -ItemType Directory -Force -Path $PUBLIC_DIR | Out-Null
 Write-Host "=== Building API Monitor Agent v$VERSION ===" -ForegroundColor Cyan
 
 # 设置通用的构建参数
-$LDFLAGS = "-s -w -X main.VERSION=$VERSION"
+$GIT_COMMIT = (git rev-parse --short HEAD 2>$null)
+if (-not $GIT_COMMIT) { $GIT_COMMIT = "unknown" }
+$BUILD_TIME = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+$LDFLAGS = "-s -w -X main.VERSION=$VERSION -X main.GitCommit=$GIT_COMMIT -X main.BuildTime=$BUILD_TIME"
 
 # 1. Windows amd64
 Write-Host "Building windows-amd64..."
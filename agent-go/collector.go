@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -20,85 +27,238 @@ import (
 	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
 )
 
 // HostInfo 主机静态信息
 type HostInfo struct {
-	Platform        string   `json:"platform"`
-	PlatformVersion string   `json:"platform_version"`
-	CPU             []string `json:"cpu"`
-	Cores           int      `json:"cores"`
-	GPU             []string `json:"gpu"`
-	GPUMemTotal     uint64   `json:"gpu_mem_total"`
-	MemTotal        uint64   `json:"mem_total"`
-	DiskTotal       uint64   `json:"disk_total"`
-	SwapTotal       uint64   `json:"swap_total"`
-	Arch            string   `json:"arch"`
-	Virtualization  string   `json:"virtualization"`
-	BootTime        int64    `json:"boot_time"`
-	IP              string   `json:"ip"`
-	CountryCode     string   `json:"country_code"`
-	AgentVersion    string   `json:"agent_version"`
+	Platform          string            `json:"platform"`
+	PlatformVersion   string            `json:"platform_version"`
+	CPU               []string          `json:"cpu"`
+	Cores             int               `json:"cores"`
+	CPUFreqBaseMhz    float64           `json:"cpu_freq_base_mhz"` // 标称频率 (gopsutil cpu.Info 的 Mhz)
+	GPU               []string          `json:"gpu"`
+	GPUMemTotal       uint64            `json:"gpu_mem_total"`
+	MemTotal          uint64            `json:"mem_total"`
+	DiskTotal         uint64            `json:"disk_total"`
+	InodesTotal       uint64            `json:"inodes_total"` // 所有分区 inode 总数 (不报告 inode 的文件系统不计入)
+	SwapTotal         uint64            `json:"swap_total"`
+	Arch              string            `json:"arch"`
+	Virtualization    string            `json:"virtualization"`
+	BootTime          int64             `json:"boot_time"`
+	IP                string            `json:"ip"`
+	IPv4              string            `json:"ipv4"`               // 公网 IPv4 (与 ip 相同，ip 保留用于兼容)
+	IPv6              string            `json:"ipv6"`               // 公网 IPv6 (无 IPv6 连接时为空)
+	NetworkInterfaces []NetInterface    `json:"network_interfaces"` // 本机网卡及内网地址
+	Tags              map[string]string `json:"tags"`               // 配置的主机标签
+	CountryCode       string            `json:"country_code"`
+	AgentVersion      string            `json:"agent_version"`
+	BinaryHash        string            `json:"binary_hash"` // Agent 可执行文件 SHA-256，配合 agent_version 校验完整性
+}
+
+// InterfaceStat 单个网卡的流量统计
+type InterfaceStat struct {
+	Name      string `json:"name"`
+	BytesSent uint64 `json:"bytes_sent"`
+	BytesRecv uint64 `json:"bytes_recv"`
+	InSpeed   uint64 `json:"in_speed"`  // bytes/s
+	OutSpeed  uint64 `json:"out_speed"` // bytes/s
+}
+
+// SwapDeviceStat 单个 Swap 设备/文件的用量
+type SwapDeviceStat struct {
+	Name string `json:"name"`
+	Used uint64 `json:"used"` // bytes
+	Free uint64 `json:"free"` // bytes
+}
+
+// GPUStat 单张 GPU 的实时状态
+type GPUStat struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name"`
+	Utilization float64 `json:"utilization"` // 使用率 (0-100)
+	MemUsed     uint64  `json:"mem_used"`    // bytes
+	MemTotal    uint64  `json:"mem_total"`   // bytes
+	Power       float64 `json:"power"`       // 瓦特
+	Temperature float64 `json:"temperature"` // 摄氏度
+	FanSpeed    float64 `json:"fan_speed"`   // 风扇转速百分比 (被动散热的卡为 0)
+}
+
+// ProcessInfo 进程资源占用 (Top N 进程列表)
+type ProcessInfo struct {
+	PID  int32   `json:"pid"`
+	Name string  `json:"name"`
+	CPU  float64 `json:"cpu"` // 使用率 (单核为 100)
+	Mem  uint64  `json:"mem"` // 常驻内存 RSS (bytes)
 }
 
 // DockerContainer 容器信息
 type DockerContainer struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Image   string `json:"image"`
-	Status  string `json:"status"`
-	Created string `json:"created"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	Status        string `json:"status"`
+	Created       string `json:"created"`
+	LogErrorCount int    `json:"log_error_count,omitempty"` // 最近日志中的错误行数 (仅监视的容器)
+
+	// 资源占用 (仅开启 dockerStats 时采集)
+	CPUPercent float64 `json:"cpu_percent,omitempty"`
+	MemUsage   uint64  `json:"mem_usage,omitempty"` // bytes
+	MemLimit   uint64  `json:"mem_limit,omitempty"` // bytes
 }
 
 // DockerInfo Docker 信息
 type DockerInfo struct {
 	Installed  bool              `json:"installed"`
+	Runtime    string            `json:"runtime"` // 使用的容器运行时: docker / podman
 	Running    int               `json:"running"`
 	Stopped    int               `json:"stopped"`
 	Containers []DockerContainer `json:"containers"`
+	Truncated  bool              `json:"truncated,omitempty"` // 容器列表超过 dockerMaxContainers 被截断 (Running/Stopped 仍为完整统计)
 }
 
 // State 实时状态
 type State struct {
-	CPU            float64    `json:"cpu"`
-	MemUsed        uint64     `json:"mem_used"`
-	SwapUsed       uint64     `json:"swap_used"`
-	DiskUsed       uint64     `json:"disk_used"`
-	NetInTransfer  uint64     `json:"net_in_transfer"`
-	NetOutTransfer uint64     `json:"net_out_transfer"`
-	NetInSpeed     uint64     `json:"net_in_speed"`
-	NetOutSpeed    uint64     `json:"net_out_speed"`
-	Uptime         uint64     `json:"uptime"`
-	Load1          float64    `json:"load1"`
-	Load5          float64    `json:"load5"`
-	Load15         float64    `json:"load15"`
-	TcpConnCount   int        `json:"tcp_conn_count"`
-	UdpConnCount   int        `json:"udp_conn_count"`
-	ProcessCount   int        `json:"process_count"`
-	Temperatures   []string   `json:"temperatures"`
-	GPU            float64    `json:"gpu"`
-	GPUMemUsed     uint64     `json:"gpu_mem_used"`
-	GPUMemTotal    uint64     `json:"gpu_mem_total"`
-	GPUPower       float64    `json:"gpu_power"`
-	Docker         DockerInfo `json:"docker"`
+	CPU             float64          `json:"cpu"`
+	CPUPerCore      []float64        `json:"cpu_per_core"` // 每个逻辑核心的使用率
+	CPUFreqMhz      float64          `json:"cpu_freq_mhz"` // 当前平均频率 (无法读取实时频率时为标称频率)
+	CPUSteal        float64          `json:"cpu_steal"`    // 被虚拟化层占用的时间占比 % (Windows 为 0)
+	CPUIOWait       float64          `json:"cpu_iowait"`   // 等待 IO 的时间占比 % (Windows 为 0)
+	MemUsed         uint64           `json:"mem_used"`
+	MemAvailable    uint64           `json:"mem_available"` // 可用内存 (含可回收的缓存)
+	MemCached       uint64           `json:"mem_cached"`    // 页缓存 (Linux，其他平台为 0)
+	MemBuffers      uint64           `json:"mem_buffers"`   // 缓冲区 (Linux，其他平台为 0)
+	SwapUsed        uint64           `json:"swap_used"`
+	SwapDevices     []SwapDeviceStat `json:"swap_devices"` // 每个 Swap 设备/文件的用量 (不支持的平台为空)
+	DiskUsed        uint64           `json:"disk_used"`
+	InodesUsed      uint64           `json:"inodes_used"`           // 与 HostInfo.InodesTotal 统计相同的分区
+	DiskHealth      []DiskHealthStat `json:"disk_health,omitempty"` // SMART 健康状态 (enableSmart 开启时)
+	NetInTransfer   uint64           `json:"net_in_transfer"`
+	NetOutTransfer  uint64           `json:"net_out_transfer"`
+	NetInSpeed      uint64           `json:"net_in_speed"`
+	NetOutSpeed     uint64           `json:"net_out_speed"`
+	Interfaces      []InterfaceStat  `json:"interfaces"`       // 每个网卡的流量统计
+	DiskReadSpeed   uint64           `json:"disk_read_speed"`  // 磁盘读取速度 (bytes/s)
+	DiskWriteSpeed  uint64           `json:"disk_write_speed"` // 磁盘写入速度 (bytes/s)
+	Uptime          uint64           `json:"uptime"`
+	Load1           float64          `json:"load1"`
+	Load5           float64          `json:"load5"`
+	Load15          float64          `json:"load15"`
+	Load1PerCore    float64          `json:"load1_per_core"` // 负载 / 逻辑核心数 (>1 表示过载)
+	Load5PerCore    float64          `json:"load5_per_core"`
+	Load15PerCore   float64          `json:"load15_per_core"`
+	ContextSwitches uint64           `json:"context_switches"` // 上下文切换次数/秒
+	Interrupts      uint64           `json:"interrupts"`       // 中断次数/秒
+	TcpConnCount    int              `json:"tcp_conn_count"`
+	TcpStates       map[string]int   `json:"tcp_states"` // 按状态统计的 TCP 连接数 (ESTABLISHED、TIME_WAIT、LISTEN 等)
+	UdpConnCount    int              `json:"udp_conn_count"`
+	InboundConns    int              `json:"inbound_conns"`  // 入站连接数 (本机提供服务)
+	OutboundConns   int              `json:"outbound_conns"` // 出站连接数 (本机主动发起)
+	ProcessCount    int              `json:"process_count"`
+	ProcessRunning  int              `json:"process_running"`   // 运行中 (R) 的进程数 (仅 Linux)
+	ProcessZombie   int              `json:"process_zombie"`    // 僵尸 (Z) 进程数 (仅 Linux)
+	OpenFDs         uint64           `json:"open_fds"`          // 系统已打开的文件描述符总数 (仅 Linux)
+	PSICPU          float64          `json:"psi_cpu"`           // CPU 压力 (PSI some avg10，%，仅 Linux)
+	PSIMem          float64          `json:"psi_mem"`           // 内存压力 (PSI some avg10，%，仅 Linux)
+	PSIIO           float64          `json:"psi_io"`            // IO 压力 (PSI some avg10，%，仅 Linux)
+	ClockOffsetMs   float64          `json:"clock_offset_ms"`   // 本机时钟相对 NTP 服务器的偏差 (毫秒，正数表示本机落后)
+	TimeSynced      bool             `json:"time_synced"`       // NTP 查询成功且偏差不超过 1 秒
+	DNSLatencyMs    float64          `json:"dns_latency_ms"`    // 解析 dnsProbeHost 的耗时 (毫秒，失败时为 -1)
+	DNSResolveOK    bool             `json:"dns_resolve_ok"`    // 最近一次 DNS 探测是否成功
+	Battery         *BatteryStat     `json:"battery,omitempty"` // 电池状态 (没有电池的主机不上报)
+	Temperatures    []string         `json:"temperatures"`
+	GPU             float64          `json:"gpu"`
+	GPUMemUsed      uint64           `json:"gpu_mem_used"`
+	GPUMemTotal     uint64           `json:"gpu_mem_total"`
+	GPUPower        float64          `json:"gpu_power"`
+	GPUTemp         float64          `json:"gpu_temp"`      // 各卡温度平均值 (摄氏度)
+	GPUFan          float64          `json:"gpu_fan"`       // 各卡风扇转速平均值 (%)
+	GPUs            []GPUStat        `json:"gpus"`          // 每张 GPU 的明细 (上面的 GPU 字段为其汇总)
+	GPUProcesses    []GPUProcess     `json:"gpu_processes"` // 占用 GPU 的进程 (NVIDIA)
+	SystemPower     float64          `json:"system_power"`  // 整机/CPU 封装功耗 (瓦特)，无传感器时为 0
+	TopProcesses    []ProcessInfo    `json:"top_processes"` // 按 CPU 使用率排序的前 N 个进程
+	Docker          DockerInfo       `json:"docker"`
+
+	// 紧急上报 (关键状态变化时立即上报，不等待下一个周期)
+	Urgent        bool     `json:"urgent,omitempty"`
+	UrgentReasons []string `json:"urgent_reasons,omitempty"`
+}
+
+// 容器日志错误扫描
+const (
+	logErrorScanInterval = 60 * time.Second // 扫描间隔
+	defaultLogErrorTail  = 200              // 默认扫描行数
+	maxLogErrorTail      = 1000             // 扫描行数上限
+)
+
+// dockerStatsTimeout docker stats 单次执行超时 (Docker 守护进程无响应时放弃本轮)
+const dockerStatsTimeout = 10 * time.Second
+
+// dockerContainerStats 单个容器的资源占用
+type dockerContainerStats struct {
+	CPUPercent float64
+	MemUsage   uint64
+	MemLimit   uint64
+}
+
+// logErrorPattern 匹配错误级别的日志行
+var logErrorPattern = regexp.MustCompile(`(?i)\b(error|fatal|panic|exception)\b`)
+
+// sensorsTemperatures 温度传感器数据源 (可替换，便于测试)
+var sensorsTemperatures = host.SensorsTemperatures
+
+// 磁盘分区与用量数据源 (可替换，便于测试)
+var (
+	diskPartitions = disk.Partitions
+	diskUsage      = disk.Usage
+)
+
+// containerPS 容器列表数据源 (可替换，便于测试)，返回 ps -a 每行一个 JSON 的输出
+var containerPS = func(engine string) ([]byte, error) {
+	cmd := exec.Command(engine, "ps", "-a", "--format", "{{json .}}")
+	hideWindow(cmd)
+	return cmd.Output()
 }
 
+// hostInfoStat 主机信息数据源 (可替换，便于测试)
+var hostInfoStat = host.Info
+
 // Collector 数据采集器
 type Collector struct {
 	mu             sync.Mutex
+	config         *atomic.Pointer[Config] // 当前配置 (重新加载时整体替换，通过 cfg() 读取)
+	httpClient     *http.Client            // 公网 IP 查询共用的客户端 (只走 IPv4)
+	httpClientV6   *http.Client            // 公网 IPv6 查询的客户端 (只走 IPv6)
+	hostInfoMu     sync.Mutex              // 串行执行 CollectHostInfo (含公网 IP、归属地和 GPU 查询，耗时较长，不占用 mu)
+	countryCode    string                  // 公网 IP 归属地缓存 (IP 变化时才重新查询，由 hostInfoMu 保护)
+	countryCodeIP  string
 	cachedHostInfo *HostInfo
 	cachedDiskUsed uint64
+	cachedInodes   uint64 // 已用 inode 数，与 cachedDiskUsed 一起刷新
+	diskUsedReady  bool   // 已完成首次磁盘用量采集
 
 	// 网络流量缓存
 	lastNetRx   uint64
 	lastNetTx   uint64
 	lastNetTime time.Time
 
-	// GPU 采集缓存 (节流: 每5秒采集一次)
+	// 每个网卡的流量缓存 (网卡名 -> 上次累计值)
+	lastIfaceCounters map[string]net.IOCountersStat
+	lastIfaceTime     time.Time
+
+	// 磁盘 I/O 缓存
+	lastDiskRead   uint64
+	lastDiskWrite  uint64
+	lastDiskIOTime time.Time
+
+	// GPU 采集缓存 (节流: 每 gpuInterval 采集一次，默认 5 秒)
 	lastGPUUsage   float64
 	lastGPUMemUsed uint64
 	lastGPUPower   float64
+	lastGPUs       []GPUStat
+	lastGPUProcs   []GPUProcess
 	lastGPUTime    time.Time
+	gpuInterval    time.Duration
 
 	// GPU 采集频率控制
 	lastGPUMetadataTime time.Time
@@ -106,6 +266,7 @@ type Collector struct {
 	// CPU 采集缓存
 	lastCPUTime  time.Time
 	lastCPUUsage float64
+	lastCPUTimes *cpu.TimesStat // 上次采样的累计 CPU 时间 (计算 steal/iowait 占比)
 
 	// Windows Native (PDH)
 	pdhQuery   uintptr
@@ -114,11 +275,92 @@ type Collector struct {
 	// NVIDIA Native (NVML)
 	nvmlLib         any
 	nvmlInitialized bool
+
+	// 上下文切换/中断计数缓存 (/proc/stat 累计值)
+	lastCtxt     uint64
+	lastIntr     uint64
+	lastStatTime time.Time
+
+	// 温度采集缓存 (节流: 每5秒采集一次)
+	lastTemperatures    []string
+	lastTemperatureTime time.Time
+
+	// SMART 采集缓存 (节流: 每 smartInterval 采集一次)
+	lastDiskHealth []DiskHealthStat
+	lastSMARTTime  time.Time
+
+	// 整机功耗采集缓存 (RAPL 能耗计数器 / IPMI)
+	lastRAPLEnergy  map[string]uint64
+	lastRAPLTime    time.Time
+	lastSystemPower float64
+	lastPowerTime   time.Time
+
+	// 紧急条件状态 (只在条件由无到有时触发)
+	activeUrgent map[string]bool
+	readOnlyBase map[string]bool // 首次检查时已是只读的挂载点，不视为异常
+
+	// 容器日志错误计数缓存 (容器名/ID -> 错误行数)
+	logErrorCounts  map[string]int
+	lastLogScanTime time.Time
+	logScanRunning  bool
+
+	// Top N 进程缓存 (节流: 每10秒采集一次)，保留进程对象以计算两次采样间的 CPU 使用率
+	procCache          map[int32]*process.Process
+	lastTopProcesses   []ProcessInfo
+	lastTopProcessTime time.Time
+
+	// 容器资源占用缓存 (短 ID -> 资源占用)，docker stats 较慢，异步刷新
+	dockerStats        map[string]dockerContainerStats
+	dockerStatsRunning bool
+
+	// host.Info() 缓存 (开销较大，按主机信息周期刷新，运行时长由 BootTime 推算)
+	hostStatMu   sync.Mutex
+	hostStat     *host.InfoStat
+	hostStatTime time.Time
+
+	// 进程状态与文件描述符缓存 (节流: 每5秒采集一次)
+	lastProcStates   processStates
+	lastOpenFDs      uint64
+	lastProcStatTime time.Time
+
+	// 时钟同步缓存 (节流: 每 ntpInterval 查询一次)
+	lastClockOffset float64
+	lastTimeSynced  bool
+	lastNTPTime     time.Time
+
+	// DNS 解析延迟缓存 (节流: 每 dnsProbeInterval 探测一次)
+	lastDNSLatency   float64
+	lastDNSResolveOK bool
+	lastDNSProbeTime time.Time
+
+	// 电池状态缓存 (节流: 有电池时每分钟一次，没有电池时每 30 分钟检测一次)
+	lastBattery     *BatteryStat
+	lastBatteryTime time.Time
+
+	alerts *alertEvaluator // 本地阈值告警 (未配置 alerts 时为 nil)
+
+	// 并行采集: 正在执行的采集项与上一次汇总的结果 (超时的采集项沿用旧值)
+	collecting map[string]bool
+	lastState  *State
 }
 
 // NewCollector 创建采集器
-func NewCollector() *Collector {
+func NewCollector(config *Config) *Collector {
+	ref := &atomic.Pointer[Config]{}
+	ref.Store(config)
 	return &Collector{
+		config:              ref,
+		httpClient:          newPublicIPClient(config, "tcp4"),
+		httpClientV6:        newPublicIPClient(config, "tcp6"),
+		gpuInterval:         gpuSampleInterval(config),
+		alerts:              newCollectorAlerts(config),
+		logErrorCounts:      make(map[string]int),
+		dockerStats:         make(map[string]dockerContainerStats),
+		procCache:           make(map[int32]*process.Process),
+		lastRAPLEnergy:      make(map[string]uint64),
+		lastIfaceCounters:   make(map[string]net.IOCountersStat),
+		activeUrgent:        make(map[string]bool),
+		collecting:          make(map[string]bool),
 		lastNetTime:         time.Now(),
 		lastGPUTime:         time.Now().Add(-1 * time.Hour), // 确保第一次采集立即执行
 		lastCPUTime:         time.Now().Add(-1 * time.Hour), // 确保第一次采集立即执行
@@ -127,18 +369,20 @@ func NewCollector() *Collector {
 }
 
 // CollectHostInfo 采集主机静态信息 (变化慢，10分钟采集一次)
+// 网络请求和外部命令不持有 c.mu，避免阻塞同一时间的实时状态采集
 func (c *Collector) CollectHostInfo() *HostInfo {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.hostInfoMu.Lock()
+	defer c.hostInfoMu.Unlock()
 
 	info := &HostInfo{
 		Platform:     runtime.GOOS,
 		Arch:         runtime.GOARCH,
 		AgentVersion: VERSION,
+		BinaryHash:   getBinaryHash(),
 	}
 
 	// 平台信息
-	if hostInfo, err := host.Info(); err == nil {
+	if hostInfo := c.cachedHostStat(0); hostInfo != nil {
 		info.Platform = hostInfo.Platform
 		info.PlatformVersion = fmt.Sprintf("%s %s", hostInfo.PlatformFamily, hostInfo.PlatformVersion)
 		info.BootTime = int64(hostInfo.BootTime)
@@ -154,6 +398,7 @@ func (c *Collector) CollectHostInfo() *HostInfo {
 	if cpuInfo, err := cpu.Info(); err == nil && len(cpuInfo) > 0 {
 		cpuDesc := fmt.Sprintf("%s %s %d Core(s)", cpuInfo[0].VendorID, cpuInfo[0].ModelName, logicalCores)
 		info.CPU = []string{strings.TrimSpace(cpuDesc)}
+		info.CPUFreqBaseMhz = cpuInfo[0].Mhz
 	} else {
 		// Fallback for Windows (using PowerShell since wmic might be missing)
 		cpuName := ""
@@ -173,7 +418,7 @@ func (c *Collector) CollectHostInfo() *HostInfo {
 		}
 	}
 	info.Cores = logicalCores
-	fmt.Printf("[Collector] Detected %d cores, Platform: %s\n", logicalCores, info.Platform)
+	logger.Infof("[Collector] Detected %d cores, Platform: %s", logicalCores, info.Platform)
 
 	// 内存信息
 	if memInfo, err := mem.VirtualMemory(); err == nil {
@@ -187,89 +432,436 @@ func (c *Collector) CollectHostInfo() *HostInfo {
 
 	// 磁盘信息
 	if partitions, err := disk.Partitions(false); err == nil {
-		var totalSize uint64
-		for _, p := range partitions {
+		var totalSize, totalInodes uint64
+		for _, p := range c.filterPartitions(partitions) {
 			if usage, err := disk.Usage(p.Mountpoint); err == nil {
 				totalSize += usage.Total
+				// 不报告 inode 的文件系统 (如大部分 Windows 卷) 总数为 0，跳过
+				if usage.InodesTotal > 0 {
+					totalInodes += usage.InodesTotal
+				}
 			}
 		}
 		info.DiskTotal = totalSize
+		info.InodesTotal = totalInodes
 	}
 
 	// 公网 IP
-	info.IP = getPublicIP()
+	if c.cfg().EnablePublicIP {
+		info.IPv4 = c.getPublicIP()
+		info.IP = info.IPv4
+		info.IPv6 = c.getPublicIPv6()
+		if c.cfg().EnableGeoIP {
+			info.CountryCode = c.lookupCountryCode(info.IP)
+		}
+	}
+
+	// 主机标签 (直接取自配置)
+	info.Tags = make(map[string]string, len(c.cfg().Tags))
+	for k, v := range c.cfg().Tags {
+		info.Tags[k] = v
+	}
+
+	// 本机网卡地址
+	info.NetworkInterfaces = collectNetInterfaces(c.cfg().IncludeAllInterfaces)
 
 	// GPU
-	gpuModels, gpuMemTotal := c.collectGPUMetadata()
-	info.GPU = gpuModels
-	info.GPUMemTotal = gpuMemTotal
-	c.lastGPUMetadataTime = time.Now()
+	info.GPU = []string{}
+	if c.cfg().EnableGPU {
+		gpuModels, gpuMemTotal := c.collectGPUMetadata()
+		info.GPU = gpuModels
+		info.GPUMemTotal = gpuMemTotal
+	}
 
+	c.mu.Lock()
+	c.lastGPUMetadataTime = time.Now()
 	c.cachedHostInfo = info
+	c.mu.Unlock()
 	return info
 }
 
+// defaultCollectTimeout 未配置上报间隔时单次采集的最长等待时间
+const defaultCollectTimeout = 1500 * time.Millisecond
+
+// stateCollector 一组可独立并行执行的采集项
+// collect 在独立的 goroutine 中执行，返回将结果写入 State 的函数 (由 CollectState 在汇总时调用)
+type stateCollector struct {
+	name    string
+	collect func() func(*State)
+}
+
 // CollectState 采集实时状态 (变化快，1-2秒采集一次)
+// 各采集项并行执行，最长等待一个上报周期；超时未完成的采集项沿用上一次的值
 func (c *Collector) CollectState() *State {
-	state := &State{
-		Temperatures: []string{},
+	timeout := time.Duration(c.cfg().ReportInterval) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultCollectTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.CollectStateContext(ctx)
+}
+
+// CollectStateContext 在 ctx 截止前汇总各采集项的结果
+func (c *Collector) CollectStateContext(ctx context.Context) *State {
+	cfg := c.cfg()
+	collectors := c.stateCollectors(cfg)
+	results := make(chan func(*State), len(collectors))
+
+	pending := 0
+	for _, sc := range collectors {
+		// 上一轮的同一采集项仍未完成时不再重复启动，避免慢采集项堆积
+		if !c.beginCollect(sc.name) {
+			continue
+		}
+		pending++
+		go func(sc stateCollector) {
+			defer c.endCollect(sc.name)
+			results <- sc.collect()
+		}(sc)
+	}
+
+	state := c.previousState()
+	resetDisabledState(state, cfg)
+wait:
+	for pending > 0 {
+		select {
+		case apply := <-results:
+			apply(state)
+			pending--
+		case <-ctx.Done():
+			logger.Debugf("[Collector] 采集超时，%d 项沿用上一次的值", pending)
+			break wait
+		}
+	}
+
+	// 按核心数归一化的负载 (负载来自不同采集项，汇总后统一计算)
+	cores := c.coreCount()
+	state.Load1PerCore = loadPerCore(state.Load1, cores)
+	state.Load5PerCore = loadPerCore(state.Load5, cores)
+	state.Load15PerCore = loadPerCore(state.Load15, cores)
+
+	saved := *state
+	c.mu.Lock()
+	c.lastState = &saved
+	var memTotal, diskTotal uint64
+	if c.cachedHostInfo != nil {
+		memTotal, diskTotal = c.cachedHostInfo.MemTotal, c.cachedHostInfo.DiskTotal
+	}
+	c.mu.Unlock()
+
+	// 本地阈值告警
+	if c.alerts != nil {
+		c.alerts.evaluate(c.cfg(), &saved, memTotal, diskTotal)
+	}
+	return state
+}
+
+// beginCollect 标记采集项开始执行，已在执行中时返回 false
+func (c *Collector) beginCollect(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.collecting[name] {
+		return false
+	}
+	c.collecting[name] = true
+	return true
+}
+
+// endCollect 标记采集项执行完毕
+func (c *Collector) endCollect(name string) {
+	c.mu.Lock()
+	delete(c.collecting, name)
+	c.mu.Unlock()
+}
+
+// previousState 以上一次的采集结果为基础 (超时的采集项保留旧值)，首次采集时返回空状态
+func (c *Collector) previousState() *State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastState == nil {
+		return &State{
+			CPUPerCore:   []float64{},
+			Interfaces:   []InterfaceStat{},
+			Temperatures: []string{},
+			TopProcesses: []ProcessInfo{},
+			TcpStates:    map[string]int{},
+			SwapDevices:  []SwapDeviceStat{},
+		}
 	}
+	state := *c.lastState
+	state.Urgent = false
+	state.UrgentReasons = nil
+	return &state
+}
+
+// stateCollectors 返回启用的采集项 (各采集项只访问自己的缓存字段，共享字段均通过 c.mu 保护)
+// 关闭的采集项不执行，对应字段由 resetDisabledState 清零
+func (c *Collector) stateCollectors(cfg *Config) []stateCollector {
+	collectors := []stateCollector{
+		{"cpu", c.collectCPUState},
+		{"mem", c.collectMemState},
+		{"disk", c.collectDiskState},
+		{"net", c.collectNetState},
+		{"system", c.collectSystemState},
+		{"sensors", c.collectSensorState},
+		{"procs", c.collectProcessState},
+	}
+	if cfg.EnableConnCount {
+		collectors = append(collectors, stateCollector{"conns", c.collectConnState})
+	}
+	if cfg.EnableDocker {
+		collectors = append(collectors, stateCollector{"docker", func() func(*State) {
+			docker := c.collectDockerInfo()
+			return func(s *State) { s.Docker = docker }
+		}})
+	}
+	if cfg.EnableGPU {
+		collectors = append(collectors, stateCollector{"gpu", c.collectGPUStateFields})
+	}
+	if cfg.NTPServer != "" {
+		collectors = append(collectors, stateCollector{"ntp", c.collectClockState})
+	}
+	if cfg.EnableSMART {
+		collectors = append(collectors, stateCollector{"smart", c.collectSMARTState})
+	}
+	if cfg.DNSProbeHost != "" {
+		collectors = append(collectors, stateCollector{"dns", c.collectDNSState})
+	}
+	collectors = append(collectors, stateCollector{"battery", c.collectBatteryState})
+	if runtime.GOOS == "linux" {
+		collectors = append(collectors, stateCollector{"psi", c.collectPSIState})
+	}
+	return collectors
+}
+
+// resetDisabledState 清空关闭的采集项对应的字段
+// 状态以上一次的结果为基础，热重载关闭某项采集后不能继续上报关闭前的旧值
+func resetDisabledState(s *State, cfg *Config) {
+	if !cfg.EnableConnCount {
+		s.TcpConnCount = 0
+		s.TcpStates = map[string]int{}
+		s.UdpConnCount = 0
+		s.InboundConns = 0
+		s.OutboundConns = 0
+	}
+	if !cfg.EnableDocker {
+		s.Docker = DockerInfo{Containers: []DockerContainer{}}
+	}
+	if !cfg.EnableGPU {
+		s.GPU = 0
+		s.GPUMemUsed = 0
+		s.GPUMemTotal = 0
+		s.GPUPower = 0
+		s.GPUTemp = 0
+		s.GPUFan = 0
+		s.GPUs = nil
+		s.GPUProcesses = nil
+	}
+	if cfg.NTPServer == "" {
+		applyClockState(s, 0, false)
+	}
+	if !cfg.EnableSMART {
+		s.DiskHealth = nil
+	}
+	if cfg.DNSProbeHost == "" {
+		applyDNSState(s, 0, false)
+	}
+}
+
+// collectCPUState CPU 使用率 (Windows 下同时用 CPU 使用率模拟负载)
+func (c *Collector) collectCPUState() func(*State) {
+	var usage float64
+	perCore := []float64{}
 
 	// CPU 使用率 (带缓存：如果本次采集返回 0 且距上次采集不足 500ms，使用缓存值)
-	if cpuPercent, err := cpu.Percent(0, false); err == nil && len(cpuPercent) > 0 {
+	// 只采集一次每核数据，总使用率取各核平均值，避免两次采样
+	cpuPercent, err := cpu.Percent(0, true)
+	if err == nil && len(cpuPercent) > 0 {
+		perCore = cpuPercent
+		var total float64
+		for _, p := range cpuPercent {
+			total += p
+		}
+		cpuPercent = []float64{total / float64(len(cpuPercent))}
+	} else {
+		cpuPercent, err = cpu.Percent(0, false)
+	}
+
+	c.mu.Lock()
+	if err == nil && len(cpuPercent) > 0 {
 		currentCPU := cpuPercent[0]
-		now := time.Now()
-		
+
 		// 如果返回 0 但距上次有效采集不足 3 秒，使用缓存值
 		if currentCPU < 0.1 && time.Since(c.lastCPUTime) < 3*time.Second && c.lastCPUUsage > 0 {
-			state.CPU = c.lastCPUUsage
+			usage = c.lastCPUUsage
 		} else {
-			state.CPU = currentCPU
+			usage = currentCPU
 			// 只有非零值才更新缓存
 			if currentCPU >= 0.1 {
-				c.mu.Lock()
 				c.lastCPUUsage = currentCPU
-				c.lastCPUTime = now
-				c.mu.Unlock()
+				c.lastCPUTime = time.Now()
 			}
 		}
 	} else if c.lastCPUUsage > 0 {
 		// 采集失败时使用缓存值
-		state.CPU = c.lastCPUUsage
+		usage = c.lastCPUUsage
 	}
+	c.mu.Unlock()
 
-	// 内存
-	if memInfo, err := mem.VirtualMemory(); err == nil {
-		state.MemUsed = memInfo.Used
+	// steal/iowait: 与上次采样的累计时间做差 (Windows 没有这两项)
+	var steal, iowait float64
+	if runtime.GOOS != "windows" {
+		if times, err := cpu.Times(false); err == nil && len(times) > 0 {
+			c.mu.Lock()
+			if c.lastCPUTimes != nil {
+				steal, iowait = cpuTimesPercent(*c.lastCPUTimes, times[0])
+			}
+			c.lastCPUTimes = &times[0]
+			c.mu.Unlock()
+		}
 	}
 
-	// Swap
-	if swapInfo, err := mem.SwapMemory(); err == nil {
-		state.SwapUsed = swapInfo.Used
+	// 当前频率 (用于发现降频和云主机 CPU 限流)
+	freq, ok := currentCPUFreq()
+	if !ok {
+		freq = c.cpuBaseFreq()
 	}
 
-	// 磁盘使用 (异步更新缓存)
-	go func() {
-		if partitions, err := disk.Partitions(false); err == nil {
-			var usedSize uint64
-			for _, p := range partitions {
-				if usage, err := disk.Usage(p.Mountpoint); err == nil {
-					usedSize += usage.Used
-				}
-			}
-			c.mu.Lock()
-			c.cachedDiskUsed = usedSize
-			c.mu.Unlock()
+	cores := c.coreCount()
+	return func(s *State) {
+		s.CPU = usage
+		s.CPUPerCore = perCore
+		s.CPUFreqMhz = freq
+		s.CPUSteal = steal
+		s.CPUIOWait = iowait
+		if runtime.GOOS == "windows" {
+			// Windows 不支持负载，使用 CPU 使用率模拟
+			s.Load1 = usage / 100 * float64(cores)
+			s.Load5 = s.Load1
+			s.Load15 = s.Load1
 		}
-	}()
+	}
+}
+
+// cpuTimesPercent 两次累计 CPU 时间之间 steal 与 iowait 的占比 (%)
+func cpuTimesPercent(prev, cur cpu.TimesStat) (steal, iowait float64) {
+	total := cur.Total() - prev.Total()
+	if total <= 0 {
+		return 0, 0
+	}
+	steal = (cur.Steal - prev.Steal) / total * 100
+	iowait = (cur.Iowait - prev.Iowait) / total * 100
+	if steal < 0 {
+		steal = 0
+	}
+	if iowait < 0 {
+		iowait = 0
+	}
+	return steal, iowait
+}
+
+// gpuSampleInterval GPU 采样间隔，不低于上报间隔 (避免 nvidia-smi 在一个上报周期内被重复调用)
+func gpuSampleInterval(config *Config) time.Duration {
+	interval := config.GPUInterval
+	if interval <= 0 {
+		interval = 5000
+	}
+	if interval < config.ReportInterval {
+		interval = config.ReportInterval
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// coreCount 逻辑核心数 (优先使用主机信息中缓存的值)
+func (c *Collector) coreCount() int {
 	c.mu.Lock()
-	state.DiskUsed = c.cachedDiskUsed
+	defer c.mu.Unlock()
+	if c.cachedHostInfo != nil && c.cachedHostInfo.Cores > 0 {
+		return c.cachedHostInfo.Cores
+	}
+	return runtime.NumCPU()
+}
+
+// loadPerCore 按核心数归一化负载
+func loadPerCore(load float64, cores int) float64 {
+	if cores <= 0 {
+		return 0
+	}
+	return load / float64(cores)
+}
+
+// collectMemState 内存与 Swap
+func (c *Collector) collectMemState() func(*State) {
+	memInfo, _ := mem.VirtualMemory()
+	var swapUsed uint64
+	if swapInfo, err := mem.SwapMemory(); err == nil {
+		swapUsed = swapInfo.Used
+	}
+	swapDevices := collectSwapDevices()
+	return func(s *State) {
+		if memInfo != nil {
+			applyMemStat(s, memInfo)
+		}
+		s.SwapUsed = swapUsed
+		s.SwapDevices = swapDevices
+	}
+}
+
+// applyMemStat 将 gopsutil 的内存统计写入 State (平台不提供的字段为 0)
+func applyMemStat(s *State, m *mem.VirtualMemoryStat) {
+	s.MemUsed = m.Used
+	s.MemAvailable = m.Available
+	s.MemCached = m.Cached
+	s.MemBuffers = m.Buffers
+}
+
+// collectSwapDevices 每个 Swap 设备/文件的用量，平台不支持或读取失败时返回空列表
+func collectSwapDevices() []SwapDeviceStat {
+	result := []SwapDeviceStat{}
+	devices, err := mem.SwapDevices()
+	if err != nil {
+		return result
+	}
+	for _, d := range devices {
+		result = append(result, SwapDeviceStat{Name: d.Name, Used: d.UsedBytes, Free: d.FreeBytes})
+	}
+	return result
+}
+
+// collectDiskState 磁盘用量与 I/O 速度
+func (c *Collector) collectDiskState() func(*State) {
+	// 磁盘使用 (首次同步采集，之后异步更新缓存，本次上报使用上一次的结果)
+	c.mu.Lock()
+	diskReady := c.diskUsedReady
+	c.mu.Unlock()
+	if diskReady {
+		go c.refreshDiskUsed()
+	} else {
+		c.refreshDiskUsed()
+	}
+	c.mu.Lock()
+	diskUsed := c.cachedDiskUsed
+	inodesUsed := c.cachedInodes
 	c.mu.Unlock()
 
-	// 网络流量
+	readSpeed, writeSpeed := c.collectDiskIOSpeed()
+	return func(s *State) {
+		s.DiskUsed = diskUsed
+		s.InodesUsed = inodesUsed
+		s.DiskReadSpeed = readSpeed
+		s.DiskWriteSpeed = writeSpeed
+	}
+}
+
+// collectNetState 网络总流量、速度与每个网卡的流量
+func (c *Collector) collectNetState() func(*State) {
+	var inTransfer, outTransfer, inSpeed, outSpeed uint64
+	ok := false
 	if netIO, err := net.IOCounters(false); err == nil && len(netIO) > 0 {
-		state.NetInTransfer = netIO[0].BytesRecv
-		state.NetOutTransfer = netIO[0].BytesSent
+		ok = true
+		inTransfer = netIO[0].BytesRecv
+		outTransfer = netIO[0].BytesSent
 
 		// 计算速度
 		c.mu.Lock()
@@ -277,10 +869,10 @@ func (c *Collector) CollectState() *State {
 		elapsed := now.Sub(c.lastNetTime).Seconds()
 		if elapsed > 0 && c.lastNetTime.Unix() > 0 {
 			if netIO[0].BytesRecv >= c.lastNetRx {
-				state.NetInSpeed = uint64(float64(netIO[0].BytesRecv-c.lastNetRx) / elapsed)
+				inSpeed = uint64(float64(netIO[0].BytesRecv-c.lastNetRx) / elapsed)
 			}
 			if netIO[0].BytesSent >= c.lastNetTx {
-				state.NetOutSpeed = uint64(float64(netIO[0].BytesSent-c.lastNetTx) / elapsed)
+				outSpeed = uint64(float64(netIO[0].BytesSent-c.lastNetTx) / elapsed)
 			}
 		}
 		c.lastNetRx = netIO[0].BytesRecv
@@ -289,82 +881,853 @@ func (c *Collector) CollectState() *State {
 		c.mu.Unlock()
 	}
 
-	// 运行时长
-	if hostInfo, err := host.Info(); err == nil {
-		state.Uptime = hostInfo.Uptime
+	interfaces := c.collectInterfaceStats()
+	return func(s *State) {
+		if ok {
+			s.NetInTransfer = inTransfer
+			s.NetOutTransfer = outTransfer
+			s.NetInSpeed = inSpeed
+			s.NetOutSpeed = outSpeed
+		}
+		s.Interfaces = interfaces
+	}
+}
+
+// collectSystemState 运行时长、负载、上下文切换与中断速率
+func (c *Collector) collectSystemState() func(*State) {
+	var uptime uint64
+	// 运行时长 (由缓存的 BootTime 推算，不必每次调用 host.Info())
+	maxAge := time.Duration(c.cfg().HostInfoInterval) * time.Millisecond
+	if hostInfo := c.cachedHostStat(maxAge); hostInfo != nil {
+		uptime = uptimeSince(hostInfo, c.hostStatFetchedAt(), time.Now())
 	}
 
-	// 负载 (Windows 不支持，使用 CPU 模拟)
+	// 负载 (Windows 不支持，由 CPU 采集项模拟)
+	var loadAvg *load.AvgStat
 	if runtime.GOOS != "windows" {
-		if loadAvg, err := load.Avg(); err == nil {
-			state.Load1 = loadAvg.Load1
-			state.Load5 = loadAvg.Load5
-			state.Load15 = loadAvg.Load15
+		loadAvg, _ = load.Avg()
+	}
+
+	// 上下文切换与中断速率
+	ctxSwitches, interrupts := c.collectSwitchRates()
+
+	return func(s *State) {
+		s.Uptime = uptime
+		if loadAvg != nil {
+			s.Load1 = loadAvg.Load1
+			s.Load5 = loadAvg.Load5
+			s.Load15 = loadAvg.Load15
 		}
-	} else {
-		// Windows: 使用 CPU 使用率模拟
-		cpuCount := float64(runtime.NumCPU())
-		state.Load1 = state.CPU / 100 * cpuCount
-		state.Load5 = state.Load1
-		state.Load15 = state.Load1
+		s.ContextSwitches = ctxSwitches
+		s.Interrupts = interrupts
+	}
+}
+
+// collectConnState TCP/UDP 连接数
+func (c *Collector) collectConnState() func(*State) {
+	conns, err := net.Connections("all")
+	if err != nil {
+		return func(s *State) {}
 	}
 
-	// TCP/UDP 连接数
-	if conns, err := net.Connections("all"); err == nil {
-		for _, conn := range conns {
-			switch conn.Type {
-			case 1: // TCP
-				state.TcpConnCount++
-			case 2: // UDP
-				state.UdpConnCount++
+	var tcpCount, udpCount int
+	tcpStates := make(map[string]int)
+	for _, conn := range conns {
+		switch conn.Type {
+		case 1: // TCP
+			tcpCount++
+			if conn.Status != "" {
+				tcpStates[conn.Status]++
 			}
+		case 2: // UDP
+			udpCount++
 		}
 	}
+	inbound, outbound := c.classifyConnections(conns)
+	return func(s *State) {
+		s.TcpConnCount = tcpCount
+		s.TcpStates = tcpStates
+		s.UdpConnCount = udpCount
+		s.InboundConns = inbound
+		s.OutboundConns = outbound
+	}
+}
+
+// collectGPUStateFields GPU 使用率、显存与功耗 (每次都采集，与 CPU 保持一致的 1.5 秒频率)
+func (c *Collector) collectGPUStateFields() func(*State) {
+	// 节流: 距上次采样不足 gpuInterval 时直接使用缓存值
+	c.mu.Lock()
+	sample := time.Since(c.lastGPUTime) >= c.gpuInterval
+	if sample {
+		c.lastGPUTime = time.Now()
+	}
+	c.mu.Unlock()
+
+	var gpuUsage, gpuPower float64
+	var gpuMemUsed uint64
+	var gpus []GPUStat
+	var gpuProcs []GPUProcess
+	if sample {
+		gpuUsage, gpuMemUsed, gpuPower, gpus = c.collectGPUState()
+		gpuProcs = c.collectGPUProcesses()
+	}
 
-	// Docker 信息采集
-	state.Docker = c.collectDockerInfo()
-	
-	// GPU 使用率、显存与功耗采集 (每次都采集，与 CPU 保持一致的 1.5 秒频率)
-	gpuUsage, gpuMemUsed, gpuPower := c.collectGPUState()
+	c.mu.Lock()
 	// 只有采集到有效数据才更新缓存
 	if gpuUsage > 0 || gpuMemUsed > 0 || gpuPower > 0 {
 		c.lastGPUUsage = gpuUsage
 		c.lastGPUMemUsed = gpuMemUsed
 		c.lastGPUPower = gpuPower
-		c.lastGPUTime = time.Now()
+		c.lastGPUs = gpus
+	}
+	if gpuProcs != nil {
+		c.lastGPUProcs = gpuProcs
 	}
 
 	// 补救措施：如果显存总量为 0，尝试重新获取静态信息 (增加冷却时间，防止频繁调用 PowerShell)
+	shouldRetry := false
 	if c.cachedHostInfo != nil && c.cachedHostInfo.GPUMemTotal == 0 {
-		c.mu.Lock()
-		shouldRetry := time.Since(c.lastGPUMetadataTime) > 10*time.Minute
+		shouldRetry = time.Since(c.lastGPUMetadataTime) > 10*time.Minute
 		if shouldRetry {
 			c.lastGPUMetadataTime = time.Now() // 预设时间，防止下一秒再次触发
 		}
-		c.mu.Unlock()
+	}
+
+	usage, memUsed, power, lastGPUs := c.lastGPUUsage, c.lastGPUMemUsed, c.lastGPUPower, c.lastGPUs
+	procs := c.lastGPUProcs
+	var memTotal uint64
+	if c.cachedHostInfo != nil {
+		memTotal = c.cachedHostInfo.GPUMemTotal
+	}
+	c.mu.Unlock()
+
+	if shouldRetry {
+		go func() {
+			models, total := c.collectGPUMetadata()
+			if total > 0 {
+				c.mu.Lock()
+				c.cachedHostInfo.GPU = models
+				c.cachedHostInfo.GPUMemTotal = total
+				c.mu.Unlock()
+				logger.Infof("[Collector] GPU metadata refreshed: %d MiB", total/1024/1024)
+			}
+		}()
+	}
+
+	named := c.gpuStatsWithNames(lastGPUs)
+	temp, fan := averageGPUThermals(lastGPUs)
+	return func(s *State) {
+		s.GPU = usage
+		s.GPUMemUsed = memUsed
+		s.GPUMemTotal = memTotal
+		s.GPUPower = power
+		s.GPUTemp = temp
+		s.GPUFan = fan
+		s.GPUs = named
+		s.GPUProcesses = procs
+	}
+}
+
+// collectSensorState 整机功耗、温度和 Top N 进程 (均有各自的节流)
+func (c *Collector) collectSensorState() func(*State) {
+	power := c.collectSystemPower()
+	temps := c.collectTemperatures()
+	top := c.collectTopProcesses()
+	return func(s *State) {
+		s.SystemPower = power
+		s.Temperatures = temps
+		s.TopProcesses = top
+	}
+}
+
+// collectTopProcesses 采集 CPU 使用率最高的 N 个进程 (topProcessCount 为 0 时不采集)
+func (c *Collector) collectTopProcesses() []ProcessInfo {
+	count := c.cfg().TopProcessCount
+	if count <= 0 {
+		return []ProcessInfo{}
+	}
+
+	// 节流期内直接返回缓存，同时保证同一时刻只有一个调用方遍历进程 (procCache 不加锁)
+	c.mu.Lock()
+	if time.Since(c.lastTopProcessTime) < 10*time.Second {
+		top := c.lastTopProcesses
+		c.mu.Unlock()
+		if top == nil {
+			return []ProcessInfo{}
+		}
+		return top
+	}
+	c.lastTopProcessTime = time.Now()
+	c.mu.Unlock()
+
+	pids, err := process.Pids()
+	if err != nil {
+		return []ProcessInfo{}
+	}
+
+	alive := make(map[int32]bool, len(pids))
+	list := make([]ProcessInfo, 0, len(pids))
+	for _, pid := range pids {
+		alive[pid] = true
+		p, ok := c.procCache[pid]
+		if !ok {
+			if p, err = process.NewProcess(pid); err != nil {
+				continue
+			}
+			c.procCache[pid] = p
+		}
+
+		// Percent(0) 返回与上次调用之间的 CPU 使用率，新进程首次采样为 0
+		cpuPercent, err := p.Percent(0)
+		if err != nil {
+			continue
+		}
+		info := ProcessInfo{PID: pid, CPU: cpuPercent}
+		info.Name, _ = p.Name()
+		if memInfo, err := p.MemoryInfo(); err == nil {
+			info.Mem = memInfo.RSS
+		}
+		list = append(list, info)
+	}
+
+	// 清理已退出的进程
+	for pid := range c.procCache {
+		if !alive[pid] {
+			delete(c.procCache, pid)
+		}
+	}
+
+	top := topProcessesByCPU(list, count)
+
+	c.mu.Lock()
+	c.lastTopProcesses = top
+	c.mu.Unlock()
+	return top
+}
+
+// topProcessesByCPU 按 CPU 使用率降序排序 (相同时按内存)，返回前 n 个
+func topProcessesByCPU(list []ProcessInfo, n int) []ProcessInfo {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CPU != list[j].CPU {
+			return list[i].CPU > list[j].CPU
+		}
+		return list[i].Mem > list[j].Mem
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// refreshDiskUsed 统计所有 (未排除的) 分区已用空间并更新缓存
+func (c *Collector) refreshDiskUsed() {
+	partitions, err := diskPartitions(false)
+	if err != nil {
+		return
+	}
+
+	var usedSize, usedInodes uint64
+	for _, p := range c.filterPartitions(partitions) {
+		if usage, err := diskUsage(p.Mountpoint); err == nil {
+			usedSize += usage.Used
+			if usage.InodesTotal > 0 {
+				usedInodes += usage.InodesUsed
+			}
+		}
+	}
+	c.mu.Lock()
+	c.cachedDiskUsed = usedSize
+	c.cachedInodes = usedInodes
+	c.diskUsedReady = true
+	c.mu.Unlock()
+}
+
+// classifyConnections 区分入站/出站连接
+// 本地端口为监听端口 (含配置的 listenPorts) 或小于 1024 视为入站，其余视为出站
+func (c *Collector) classifyConnections(conns []net.ConnectionStat) (int, int) {
+	listening := make(map[uint32]bool)
+	for _, port := range c.cfg().ListenPorts {
+		listening[port] = true
+	}
+	for _, conn := range conns {
+		if conn.Status == "LISTEN" {
+			listening[conn.Laddr.Port] = true
+		}
+	}
+
+	var inbound, outbound int
+	for _, conn := range conns {
+		// 只统计已建立远端连接的套接字
+		if conn.Raddr.Port == 0 || conn.Status == "LISTEN" {
+			continue
+		}
+		if listening[conn.Laddr.Port] || conn.Laddr.Port < 1024 {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+	return inbound, outbound
+}
+
+// collectInterfaceStats 采集每个网卡的流量与速度 (按 netInterfaceExclude 过滤)
+func (c *Collector) collectInterfaceStats() []InterfaceStat {
+	stats := []InterfaceStat{}
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		return stats
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastIfaceTime).Seconds()
+	current := make(map[string]net.IOCountersStat, len(counters))
+	for _, counter := range counters {
+		current[counter.Name] = counter
+		if c.isInterfaceExcluded(counter.Name) {
+			continue
+		}
+
+		stat := InterfaceStat{
+			Name:      counter.Name,
+			BytesSent: counter.BytesSent,
+			BytesRecv: counter.BytesRecv,
+		}
+		// 新出现的网卡第一次只建立基准；计数器回退时速度记为 0
+		if last, ok := c.lastIfaceCounters[counter.Name]; ok && elapsed > 0 {
+			if counter.BytesRecv >= last.BytesRecv {
+				stat.InSpeed = uint64(float64(counter.BytesRecv-last.BytesRecv) / elapsed)
+			}
+			if counter.BytesSent >= last.BytesSent {
+				stat.OutSpeed = uint64(float64(counter.BytesSent-last.BytesSent) / elapsed)
+			}
+		}
+		stats = append(stats, stat)
+	}
+	c.lastIfaceCounters = current
+	c.lastIfaceTime = now
+
+	return stats
+}
+
+// isInterfaceExcluded 判断网卡名是否匹配排除列表 (支持通配符，如 veth*)
+func (c *Collector) isInterfaceExcluded(name string) bool {
+	for _, pattern := range c.cfg().NetInterfaceExclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPartitions 按 diskExcludeFsTypes / diskExcludeMounts 过滤分区，避免 overlay、tmpfs 等重复计入磁盘总量
+func (c *Collector) filterPartitions(partitions []disk.PartitionStat) []disk.PartitionStat {
+	return filterPartitions(partitions, c.cfg().DiskExcludeFsTypes, c.cfg().DiskExcludeMounts)
+}
+
+// filterPartitions 过滤掉文件系统类型或挂载点 (支持通配符) 命中排除列表的分区
+func filterPartitions(partitions []disk.PartitionStat, excludeFsTypes, excludeMounts []string) []disk.PartitionStat {
+	filtered := make([]disk.PartitionStat, 0, len(partitions))
+	for _, p := range partitions {
+		if isExcludedPartition(p, excludeFsTypes, excludeMounts) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// isExcludedPartition 判断分区是否命中排除列表
+func isExcludedPartition(p disk.PartitionStat, excludeFsTypes, excludeMounts []string) bool {
+	for _, fsType := range excludeFsTypes {
+		if strings.EqualFold(p.Fstype, fsType) {
+			return true
+		}
+	}
+	for _, pattern := range excludeMounts {
+		if matchMountPattern(pattern, p.Mountpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMountPattern 挂载点本身或其任一上级目录匹配时返回 true
+// path.Match 的 * 不匹配 /，逐级检查上级目录使 /var/lib/docker/* 也能排除 /var/lib/docker/overlay2/<id>/merged
+func matchMountPattern(pattern, mountpoint string) bool {
+	for dir := mountpoint; ; {
+		if dir == pattern {
+			return true
+		}
+		if matched, _ := path.Match(pattern, dir); matched {
+			return true
+		}
+		parent := path.Dir(dir)
+		if parent == dir || parent == "." {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// collectDiskIOSpeed 根据 disk.IOCounters() 累计值的增量计算读写速度
+func (c *Collector) collectDiskIOSpeed() (uint64, uint64) {
+	counters, err := disk.IOCounters()
+	if err != nil || len(counters) == 0 {
+		return 0, 0
+	}
+
+	var readBytes, writeBytes uint64
+	for name, counter := range counters {
+		// Linux 下分区 (sda1) 与整盘 (sda) 同时出现，跳过分区避免重复统计
+		if runtime.GOOS == "linux" {
+			if _, err := os.Stat(fmt.Sprintf("/sys/class/block/%s/partition", name)); err == nil {
+				continue
+			}
+		}
+		readBytes += counter.ReadBytes
+		writeBytes += counter.WriteBytes
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastDiskIOTime).Seconds()
+	var readSpeed, writeSpeed uint64
+	// 第一次采样只建立基准；计数器回退 (重启) 时速度记为 0
+	if !c.lastDiskIOTime.IsZero() && elapsed > 0 {
+		if readBytes >= c.lastDiskRead {
+			readSpeed = uint64(float64(readBytes-c.lastDiskRead) / elapsed)
+		}
+		if writeBytes >= c.lastDiskWrite {
+			writeSpeed = uint64(float64(writeBytes-c.lastDiskWrite) / elapsed)
+		}
+	}
+	c.lastDiskRead = readBytes
+	c.lastDiskWrite = writeBytes
+	c.lastDiskIOTime = now
+
+	return readSpeed, writeSpeed
+}
+
+// collectSwitchRates 根据 /proc/stat 中 ctxt/intr 累计值的增量计算每秒速率 (仅 Linux)
+func (c *Collector) collectSwitchRates() (uint64, uint64) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return 0, 0
+	}
+
+	var ctxt, intr uint64
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ctxt":
+			ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "intr":
+			// intr 第一列为总中断数，之后为各中断号明细
+			intr, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastStatTime).Seconds()
+	var ctxtRate, intrRate uint64
+	// 第一次采样只建立基准；计数器回退 (重启) 时速率记为 0
+	if !c.lastStatTime.IsZero() && elapsed > 0 {
+		if ctxt >= c.lastCtxt {
+			ctxtRate = uint64(float64(ctxt-c.lastCtxt) / elapsed)
+		}
+		if intr >= c.lastIntr {
+			intrRate = uint64(float64(intr-c.lastIntr) / elapsed)
+		}
+	}
+	c.lastCtxt = ctxt
+	c.lastIntr = intr
+	c.lastStatTime = now
+
+	return ctxtRate, intrRate
+}
+
+// processStates 进程数量及按状态的统计
+type processStates struct {
+	Total   int
+	Running int
+	Zombie  int
+}
+
+// collectProcessState 进程数、运行中/僵尸进程数和打开的文件描述符数 (带节流缓存)
+func (c *Collector) collectProcessState() func(*State) {
+	c.mu.Lock()
+	if time.Since(c.lastProcStatTime) < 5*time.Second {
+		states, fds := c.lastProcStates, c.lastOpenFDs
+		c.mu.Unlock()
+		return func(s *State) { applyProcessState(s, states, fds) }
+	}
+	c.lastProcStatTime = time.Now()
+	c.mu.Unlock()
+
+	var states processStates
+	var fds uint64
+	if runtime.GOOS == "linux" {
+		states = countProcessStates("/proc")
+		if data, err := os.ReadFile("/proc/sys/fs/file-nr"); err == nil {
+			fds = parseFileNr(string(data))
+		}
+	}
+	// 其他平台 (或 /proc 不可读的容器环境) 只统计进程总数
+	if states.Total == 0 {
+		if pids, err := process.Pids(); err == nil {
+			states.Total = len(pids)
+		}
+	}
+
+	c.mu.Lock()
+	c.lastProcStates = states
+	c.lastOpenFDs = fds
+	c.mu.Unlock()
+	return func(s *State) { applyProcessState(s, states, fds) }
+}
+
+func applyProcessState(s *State, states processStates, fds uint64) {
+	s.ProcessCount = states.Total
+	s.ProcessRunning = states.Running
+	s.ProcessZombie = states.Zombie
+	s.OpenFDs = fds
+}
+
+// countProcessStates 遍历 procDir 下的 /proc/<pid>/stat，按进程状态计数
+func countProcessStates(procDir string) processStates {
+	var states processStates
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return states
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path.Join(procDir, entry.Name(), "stat"))
+		if err != nil {
+			continue // 进程已退出
+		}
+		states.Total++
+		switch parseProcState(string(data)) {
+		case 'R':
+			states.Running++
+		case 'Z':
+			states.Zombie++
+		}
+	}
+	return states
+}
+
+// parseProcState 从 /proc/<pid>/stat 内容中取出进程状态字符
+// 格式为 "pid (comm) S ..."，comm 可能包含空格和括号，因此以最后一个 ')' 定位
+func parseProcState(stat string) byte {
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return 0
+	}
+	return stat[i+2]
+}
+
+// parseFileNr 解析 /proc/sys/fs/file-nr ("已分配 未使用 上限")，返回正在使用的文件描述符数
+func parseFileNr(data string) uint64 {
+	fields := strings.Fields(data)
+	if len(fields) < 2 {
+		return 0
+	}
+	allocated, _ := strconv.ParseUint(fields[0], 10, 64)
+	unused, _ := strconv.ParseUint(fields[1], 10, 64)
+	if unused > allocated {
+		return 0
+	}
+	return allocated - unused
+}
+
+// collectTemperatures 采集温度传感器读数 (读取较慢，带节流缓存)
+func (c *Collector) collectTemperatures() []string {
+	c.mu.Lock()
+	if time.Since(c.lastTemperatureTime) < 5*time.Second && c.lastTemperatures != nil {
+		temps := c.lastTemperatures
+		c.mu.Unlock()
+		return temps
+	}
+	c.lastTemperatureTime = time.Now()
+	c.mu.Unlock()
+
+	// Linux 下部分传感器读取失败时会返回 Warnings 但仍带有可用数据；不支持的平台返回空
+	sensors, _ := sensorsTemperatures()
+	temps := formatTemperatures(sensors)
+
+	c.mu.Lock()
+	c.lastTemperatures = temps
+	c.mu.Unlock()
+	return temps
+}
+
+// formatTemperatures 将传感器读数格式化为 "sensor:54.0"
+func formatTemperatures(sensors []host.TemperatureStat) []string {
+	temps := []string{}
+	for _, s := range sensors {
+		if s.SensorKey == "" || s.Temperature <= 0 {
+			continue
+		}
+		temps = append(temps, fmt.Sprintf("%s:%.1f", s.SensorKey, s.Temperature))
+	}
+	return temps
+}
+
+// collectSystemPower 采集整机/CPU 封装功耗 (瓦特)
+// Linux 优先读取 Intel RAPL 能耗计数器，回退到 ipmitool；无可用传感器时返回 0
+func (c *Collector) collectSystemPower() float64 {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+
+	c.mu.Lock()
+	if time.Since(c.lastPowerTime) < 5*time.Second {
+		power := c.lastSystemPower
+		c.mu.Unlock()
+		return power
+	}
+	c.lastPowerTime = time.Now()
+	c.mu.Unlock()
+
+	power, ok := c.collectRAPLPower()
+	if !ok {
+		power = collectIPMIPower()
+	}
+
+	c.mu.Lock()
+	c.lastSystemPower = power
+	c.mu.Unlock()
+	return power
+}
+
+// collectRAPLPower 根据 /sys/class/powercap/intel-rapl:N/energy_uj 的增量计算功耗
+// 注意: 内核 5.10+ 出于安全原因仅 root 可读 energy_uj
+func (c *Collector) collectRAPLPower() (float64, bool) {
+	zones, err := os.ReadDir("/sys/class/powercap")
+	if err != nil {
+		return 0, false
+	}
+
+	now := time.Now()
+	energies := make(map[string]uint64)
+	for _, z := range zones {
+		// 只统计顶层 package 域 (intel-rapl:0)，子域 (intel-rapl:0:0) 已包含在内
+		if !strings.HasPrefix(z.Name(), "intel-rapl:") || strings.Count(z.Name(), ":") != 1 {
+			continue
+		}
+		data, err := os.ReadFile(fmt.Sprintf("/sys/class/powercap/%s/energy_uj", z.Name()))
+		if err != nil {
+			continue
+		}
+		energy, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue
+		}
+		energies[z.Name()] = energy
+	}
+	if len(energies) == 0 {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elapsed := now.Sub(c.lastRAPLTime).Seconds()
+	var totalUJ float64
+	first := len(c.lastRAPLEnergy) == 0
+	for name, energy := range energies {
+		last, ok := c.lastRAPLEnergy[name]
+		if !ok {
+			first = true
+			continue
+		}
+		if energy >= last {
+			totalUJ += float64(energy - last)
+		} else if data, err := os.ReadFile(fmt.Sprintf("/sys/class/powercap/%s/max_energy_range_uj", name)); err == nil {
+			// 计数器回绕
+			maxRange, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if maxRange > last {
+				totalUJ += float64(maxRange - last + energy)
+			}
+		}
+	}
+	c.lastRAPLEnergy = energies
+	c.lastRAPLTime = now
+
+	// 第一次采样只建立基准
+	if first || elapsed <= 0 {
+		return 0, true
+	}
+	return totalUJ / 1e6 / elapsed, true
+}
+
+// collectIPMIPower 使用 ipmitool 读取 DCMI 功耗 (需要 root 权限和 BMC 支持)
+func collectIPMIPower() float64 {
+	if _, err := exec.LookPath("ipmitool"); err != nil {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "ipmitool", "dcmi", "power", "reading").Output()
+	if err != nil {
+		return 0
+	}
+
+	// Instantaneous power reading:                   120 Watts
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.Contains(line, "Instantaneous power reading") {
+			continue
+		}
+		if idx := strings.Index(line, ":"); idx != -1 {
+			fields := strings.Fields(line[idx+1:])
+			if len(fields) > 0 {
+				watts, _ := strconv.ParseFloat(fields[0], 64)
+				return watts
+			}
+		}
+	}
+	return 0
+}
+
+// 紧急条件类型
+const (
+	UrgentFSReadOnly   = "fs_readonly"   // 文件系统变为只读
+	UrgentProcessDown  = "process_down"  // 监视的进程退出
+	UrgentRAIDDegraded = "raid_degraded" // 软 RAID 阵列降级
+)
+
+// CheckUrgentConditions 检查配置的紧急条件，返回本次新触发的条件 (如 "fs_readonly:/var")
+func (c *Collector) CheckUrgentConditions() []string {
+	current := make(map[string]bool)
+	for _, cond := range c.cfg().UrgentConditions {
+		var hits []string
+		switch cond {
+		case UrgentFSReadOnly:
+			hits = c.checkReadOnlyMounts()
+		case UrgentProcessDown:
+			hits = checkWatchedProcesses(c.cfg().WatchProcesses)
+		case UrgentRAIDDegraded:
+			hits = checkRAIDDegraded()
+		}
+		for _, hit := range hits {
+			current[cond+":"+hit] = true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var triggered []string
+	for reason := range current {
+		if !c.activeUrgent[reason] {
+			triggered = append(triggered, reason)
+		}
+	}
+	c.activeUrgent = current
+	return triggered
+}
+
+// checkReadOnlyMounts 返回由读写变为只读的挂载点
+func (c *Collector) checkReadOnlyMounts() []string {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil
+	}
+
+	readOnly := make(map[string]bool)
+	for _, p := range partitions {
+		for _, opt := range p.Opts {
+			if opt == "ro" {
+				readOnly[p.Mountpoint] = true
+				break
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.readOnlyBase == nil {
+		c.readOnlyBase = readOnly
+		return nil
+	}
+
+	var hits []string
+	for mountpoint := range readOnly {
+		if !c.readOnlyBase[mountpoint] {
+			hits = append(hits, mountpoint)
+		}
+	}
+	return hits
+}
+
+// checkWatchedProcesses 返回未在运行的监视进程名
+func checkWatchedProcesses(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	procs, err := process.Processes()
+	if err != nil {
+		return nil
+	}
+
+	running := make(map[string]bool)
+	for _, p := range procs {
+		if name, err := p.Name(); err == nil {
+			running[name] = true
+		}
+	}
 
-		if shouldRetry {
-			go func() {
-				models, total := c.collectGPUMetadata()
-				if total > 0 {
-					c.mu.Lock()
-					c.cachedHostInfo.GPU = models
-					c.cachedHostInfo.GPUMemTotal = total
-					c.mu.Unlock()
-					fmt.Printf("[Collector] GPU metadata refreshed: %d MiB\n", total/1024/1024)
-				}
-			}()
+	var hits []string
+	for _, name := range names {
+		if !running[name] {
+			hits = append(hits, name)
 		}
 	}
-	state.GPU = c.lastGPUUsage
-	state.GPUMemUsed = c.lastGPUMemUsed
-	state.GPUMemTotal = 0
-	if c.cachedHostInfo != nil {
-		state.GPUMemTotal = c.cachedHostInfo.GPUMemTotal
+	return hits
+}
+
+// checkRAIDDegraded 解析 /proc/mdstat，返回降级的阵列 (状态中含 "_"，如 [U_])
+func checkRAIDDegraded() []string {
+	data, err := os.ReadFile("/proc/mdstat")
+	if err != nil {
+		return nil
 	}
-	state.GPUPower = c.lastGPUPower
 
-	return state
+	var hits []string
+	var current string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "md") {
+			current = strings.Fields(line)[0]
+			continue
+		}
+		if current == "" {
+			continue
+		}
+		if start := strings.LastIndex(line, "["); start != -1 && strings.HasSuffix(strings.TrimSpace(line), "]") {
+			if strings.Contains(line[start:], "_") {
+				hits = append(hits, current)
+			}
+			current = ""
+		}
+	}
+	return hits
 }
 
 // collectDockerInfo 采集 Docker 容器信息
@@ -376,96 +1739,475 @@ func (c *Collector) collectDockerInfo() DockerInfo {
 		Containers: []DockerContainer{},
 	}
 
-	// 检查 Docker 是否可用
-	if _, err := exec.LookPath("docker"); err != nil {
+	// 检查 Docker 是否可用 (没有 docker 时回退到 CLI 兼容的 podman)
+	engine := containerEngine()
+	if engine == "" {
 		return info
 	}
 
 	// 尝试执行 docker ps 命令
-	cmd := exec.Command("docker", "ps", "-a", "--format", "{{json .}}")
-	hideWindow(cmd)
-	output, err := cmd.Output()
+	output, err := containerPS(engine)
 	if err != nil {
 		// Docker 可能已安装但无权限或未运行
 		return info
 	}
 
 	info.Installed = true
+	info.Runtime = engine
 
-	// 解析容器列表
+	// 解析容器列表 (运行中的容器优先上报)
+	var running, stopped []DockerContainer
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		var container struct {
-			ID      string `json:"ID"`
-			Names   string `json:"Names"`
-			Image   string `json:"Image"`
-			State   string `json:"State"`
-			Status  string `json:"Status"`
-			Created string `json:"CreatedAt"`
-		}
-
-		if err := json.Unmarshal([]byte(line), &container); err != nil {
+		// 跳过格式异常或缺少 ID 的行
+		container, ok := parseContainerPSLine(line)
+		if !ok {
 			continue
 		}
 
 		dc := DockerContainer{
-			ID:      container.ID[:12], // 短 ID
+			ID:      shortContainerID(container.ID),
 			Name:    container.Names,
 			Image:   container.Image,
 			Status:  container.Status,
 			Created: container.Created,
 		}
 
-		info.Containers = append(info.Containers, dc)
+		c.mu.Lock()
+		if count, ok := c.logErrorCounts[dc.Name]; ok {
+			dc.LogErrorCount = count
+		} else if count, ok := c.logErrorCounts[dc.ID]; ok {
+			dc.LogErrorCount = count
+		}
+		if stats, ok := c.dockerStats[dc.ID]; ok {
+			dc.CPUPercent = stats.CPUPercent
+			dc.MemUsage = stats.MemUsage
+			dc.MemLimit = stats.MemLimit
+		}
+		c.mu.Unlock()
 
 		// 统计运行/停止状态
 		if container.State == "running" {
-			info.Running++
+			running = append(running, dc)
 		} else {
-			info.Stopped++
+			stopped = append(stopped, dc)
 		}
 	}
+	info.Running = len(running)
+	info.Stopped = len(stopped)
+	info.Containers, info.Truncated = limitContainers(running, stopped, c.cfg().DockerMaxContainers)
+
+	// 异步刷新监视容器的日志错误计数
+	c.scanContainerLogErrors(engine)
+
+	// 异步刷新容器资源占用 (结果在下一轮上报中体现)
+	if c.cfg().DockerStats && info.Running > 0 {
+		c.refreshDockerStats(engine)
+	}
 
 	return info
 }
 
-// getPublicIP 获取公网 IP
-func getPublicIP() string {
-	endpoints := []string{
-		"http://ip.sb",
-		"https://api.ipify.org",
-		"https://icanhazip.com",
+// limitContainers 合并容器列表，超过 max 时优先保留运行中的容器 (max <= 0 不限制)
+func limitContainers(running, stopped []DockerContainer, max int) ([]DockerContainer, bool) {
+	all := make([]DockerContainer, 0, len(running)+len(stopped))
+	all = append(all, running...)
+	all = append(all, stopped...)
+	if max <= 0 || len(all) <= max {
+		return all, false
+	}
+	return all[:max], true
+}
+
+// containerEngine 返回可用的容器运行时命令 (优先 docker，其次 podman)，都没有时返回空
+func containerEngine() string {
+	for _, name := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// dockerCommand 使用检测到的容器运行时 (docker 或 podman) 构造命令
+// 都没有安装时仍使用 docker，执行时返回未找到命令的错误
+func dockerCommand(args ...string) *exec.Cmd {
+	return exec.Command(dockerEngine(), args...)
+}
+
+// dockerCommandContext 同 dockerCommand，超时或取消由 ctx 控制
+func dockerCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, dockerEngine(), args...)
+}
+
+func dockerEngine() string {
+	if engine := containerEngine(); engine != "" {
+		return engine
+	}
+	return "docker"
+}
+
+// psContainer ps -a --format "{{json .}}" 输出的单个容器
+type psContainer struct {
+	ID      string
+	Names   string
+	Image   string
+	State   string
+	Status  string
+	Created string
+}
+
+// parseContainerPSLine 解析 docker/podman ps 的一行 JSON 输出
+// Docker 使用 ID/Names(字符串)/CreatedAt；Podman 使用 Id 或 ID、Names(数组)、CreatedAt 或 Created(时间戳)
+func parseContainerPSLine(line string) (psContainer, bool) {
+	var raw map[string]json.RawMessage
+	if line == "" || json.Unmarshal([]byte(line), &raw) != nil {
+		return psContainer{}, false
+	}
+
+	str := func(keys ...string) string {
+		for _, key := range keys {
+			value, ok := raw[key]
+			if !ok {
+				continue
+			}
+			var s string
+			if json.Unmarshal(value, &s) == nil {
+				return s
+			}
+			// Podman 的 Names 为数组
+			var list []string
+			if json.Unmarshal(value, &list) == nil {
+				return strings.Join(list, ",")
+			}
+			// Podman 的 Created 为 Unix 时间戳
+			var ts int64
+			if json.Unmarshal(value, &ts) == nil {
+				return time.Unix(ts, 0).Format("2006-01-02 15:04:05 -0700 MST")
+			}
+		}
+		return ""
+	}
+
+	container := psContainer{
+		ID:      str("ID", "Id"),
+		Names:   str("Names"),
+		Image:   str("Image"),
+		State:   strings.ToLower(str("State")),
+		Status:  str("Status"),
+		Created: str("CreatedAt", "Created"),
+	}
+	return container, container.ID != ""
+}
+
+// scanContainerLogErrors 采样监视容器的最近日志并统计错误行 (开销较大，节流执行)
+func (c *Collector) scanContainerLogErrors(engine string) {
+	if len(c.cfg().LogErrorWatch) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	if c.logScanRunning || time.Since(c.lastLogScanTime) < logErrorScanInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.logScanRunning = true
+	c.lastLogScanTime = time.Now()
+	c.mu.Unlock()
+
+	tail := c.cfg().LogErrorTail
+	if tail <= 0 {
+		tail = defaultLogErrorTail
+	}
+	if tail > maxLogErrorTail {
+		tail = maxLogErrorTail
+	}
+
+	go func() {
+		counts := make(map[string]int)
+		for _, container := range c.cfg().LogErrorWatch {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			cmd := exec.CommandContext(ctx, engine, "logs", "--tail", strconv.Itoa(tail), container)
+			hideWindow(cmd)
+			output, err := cmd.CombinedOutput()
+			cancel()
+			if err != nil {
+				// 容器不存在或日志驱动不支持读取，跳过
+				logger.Debugf("[Collector] 读取容器日志失败 %s: %v", container, err)
+				continue
+			}
+
+			count := 0
+			for _, line := range strings.Split(string(output), "\n") {
+				if logErrorPattern.MatchString(line) {
+					count++
+				}
+			}
+			counts[container] = count
+		}
+
+		c.mu.Lock()
+		c.logErrorCounts = counts
+		c.logScanRunning = false
+		c.mu.Unlock()
+	}()
+}
+
+// refreshDockerStats 异步执行 docker stats 并缓存各容器的 CPU/内存占用
+func (c *Collector) refreshDockerStats(engine string) {
+	c.mu.Lock()
+	if c.dockerStatsRunning {
+		c.mu.Unlock()
+		return
 	}
+	c.dockerStatsRunning = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			c.dockerStatsRunning = false
+			c.mu.Unlock()
+		}()
 
-	client := &http.Client{Timeout: 5 * time.Second}
+		ctx, cancel := context.WithTimeout(context.Background(), dockerStatsTimeout)
+		defer cancel()
 
-	for _, endpoint := range endpoints {
-		resp, err := client.Get(endpoint)
+		cmd := exec.CommandContext(ctx, engine, "stats", "--no-stream", "--format", "{{json .}}")
+		hideWindow(cmd)
+		output, err := cmd.Output()
 		if err != nil {
+			logger.Debugf("[Collector] docker stats 执行失败: %v", err)
+			return
+		}
+
+		stats := parseDockerStats(string(output))
+		c.mu.Lock()
+		c.dockerStats = stats
+		c.mu.Unlock()
+	}()
+}
+
+// parseDockerStats 解析 docker stats --format "{{json .}}" 的输出，按短 ID 索引
+func parseDockerStats(output string) map[string]dockerContainerStats {
+	result := make(map[string]dockerContainerStats)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
 			continue
 		}
-		defer resp.Body.Close()
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
+		var entry struct {
+			ID       string `json:"ID"`
+			CPUPerc  string `json:"CPUPerc"`  // 如 "0.52%"
+			MemUsage string `json:"MemUsage"` // 如 "12.5MiB / 1.944GiB"
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.ID == "" {
 			continue
 		}
 
-		ip := strings.TrimSpace(string(body))
-		if ip != "" {
-			return ip
+		var stats dockerContainerStats
+		stats.CPUPercent, _ = strconv.ParseFloat(strings.TrimSuffix(entry.CPUPerc, "%"), 64)
+		if usage, limit, found := strings.Cut(entry.MemUsage, "/"); found {
+			stats.MemUsage = parseDockerSize(usage)
+			stats.MemLimit = parseDockerSize(limit)
 		}
+
+		result[shortContainerID(entry.ID)] = stats
 	}
+	return result
+}
 
-	return ""
+// shortContainerID 返回容器的 12 位短 ID (不足 12 位时原样返回)
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// parseDockerSize 解析 docker 输出的容量字符串 (如 "12.5MiB"、"1.2GB"、"0B")，返回字节数
+func parseDockerSize(s string) uint64 {
+	s = strings.TrimSpace(s)
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		// 二进制单位需要先于十进制单位匹配
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), 64)
+			if err != nil {
+				return 0
+			}
+			return uint64(value * unit.multiplier)
+		}
+	}
+	return 0
+}
+
+// QueryMetric 按名称查询 gopsutil 原始指标 (只读)
+// 支持: cpu, mem, swap, host, load, disk, disk:<挂载点>, diskio, net, net:<网卡>, conn, proc, proc:<PID>
+func (c *Collector) QueryMetric(name string) (interface{}, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(name), ":")
+
+	switch kind {
+	case "cpu":
+		info, err := cpu.Info()
+		if err != nil {
+			return nil, err
+		}
+		times, _ := cpu.Times(true)
+		return map[string]interface{}{"info": info, "times": times}, nil
+	case "mem":
+		return mem.VirtualMemory()
+	case "swap":
+		return mem.SwapMemory()
+	case "host":
+		return host.Info()
+	case "load":
+		avg, err := load.Avg()
+		if err != nil {
+			return nil, err
+		}
+		misc, _ := load.Misc()
+		return map[string]interface{}{"avg": avg, "misc": misc}, nil
+	case "disk":
+		if arg == "" {
+			return disk.Partitions(false)
+		}
+		return disk.Usage(arg)
+	case "diskio":
+		return disk.IOCounters()
+	case "net":
+		counters, err := net.IOCounters(true)
+		if err != nil || arg == "" {
+			return counters, err
+		}
+		for _, counter := range counters {
+			if counter.Name == arg {
+				return counter, nil
+			}
+		}
+		return nil, fmt.Errorf("网卡不存在: %s", arg)
+	case "conn":
+		return net.Connections("all")
+	case "proc":
+		if arg == "" {
+			return listProcesses()
+		}
+		pid, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 PID: %s", arg)
+		}
+		return describeProcess(int32(pid))
+	}
+
+	return nil, fmt.Errorf("不支持的指标: %s", name)
+}
+
+// listProcesses 列出所有进程的简要信息
+func listProcesses() ([]map[string]interface{}, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]map[string]interface{}, 0, len(procs))
+	for _, p := range procs {
+		name, _ := p.Name()
+		ppid, _ := p.Ppid()
+		list = append(list, map[string]interface{}{
+			"pid":  p.Pid,
+			"ppid": ppid,
+			"name": name,
+		})
+	}
+	return list, nil
+}
+
+// describeProcess 采集单个进程的详细信息
+func describeProcess(pid int32) (map[string]interface{}, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := map[string]interface{}{"pid": pid}
+	if name, err := p.Name(); err == nil {
+		detail["name"] = name
+	}
+	if exe, err := p.Exe(); err == nil {
+		detail["exe"] = exe
+	}
+	if cmdline, err := p.Cmdline(); err == nil {
+		detail["cmdline"] = cmdline
+	}
+	if ppid, err := p.Ppid(); err == nil {
+		detail["ppid"] = ppid
+	}
+	if status, err := p.Status(); err == nil {
+		detail["status"] = status
+	}
+	if username, err := p.Username(); err == nil {
+		detail["username"] = username
+	}
+	if createTime, err := p.CreateTime(); err == nil {
+		detail["create_time"] = createTime
+	}
+	if cpuPercent, err := p.CPUPercent(); err == nil {
+		detail["cpu_percent"] = cpuPercent
+	}
+	if memInfo, err := p.MemoryInfo(); err == nil {
+		detail["memory"] = memInfo
+	}
+	if memPercent, err := p.MemoryPercent(); err == nil {
+		detail["memory_percent"] = memPercent
+	}
+	if threads, err := p.NumThreads(); err == nil {
+		detail["num_threads"] = threads
+	}
+	if ioCounters, err := p.IOCounters(); err == nil {
+		detail["io"] = ioCounters
+	}
+	return detail, nil
+}
+
+var (
+	binaryHash     string
+	binaryHashOnce sync.Once
+)
+
+// getBinaryHash 计算 Agent 自身可执行文件的 SHA-256 (运行期间不变，只计算一次)
+func getBinaryHash() string {
+	binaryHashOnce.Do(func() {
+		exePath, err := os.Executable()
+		if err != nil {
+			return
+		}
+		f, err := os.Open(exePath)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return
+		}
+		binaryHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return binaryHash
 }
 
-// GetHostname 获取主机名
-func GetHostname() string {
+// GetHostname 获取上报的主机名 (配置了 hostname 时使用配置值)
+func GetHostname(config *Config) string {
+	if config.Hostname != "" {
+		return config.Hostname
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		return "unknown"
@@ -498,11 +2240,18 @@ func (c *Collector) collectGPUMetadata() ([]string, uint64) {
 				return models, totalMem
 			}
 		} else {
-			fmt.Printf("[Collector] nvidia-smi failed: %v\n", err)
+			logger.Warnf("[Collector] nvidia-smi failed: %v", err)
+		}
+	}
+
+	// 2. 尝试 AMD rocm-smi
+	if rocmSmi := c.getRocmSmiPath(); rocmSmi != "" {
+		if models, totalMem := c.collectAMDGPUMetadata(rocmSmi); len(models) > 0 {
+			return models, totalMem
 		}
 	}
 
-	// 2. Windows 下回退到 PowerShell (CIM/WMI)
+	// 3. Windows 下回退到 PowerShell (CIM/WMI)
 	if runtime.GOOS == "windows" {
 		return c.collectGPUInfoWindows()
 	}
@@ -518,7 +2267,7 @@ func (c *Collector) collectGPUInfoWindows() ([]string, uint64) {
 	hideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
-		fmt.Printf("[Collector] PowerShell GPU info failed: %v\n", err)
+		logger.Warnf("[Collector] PowerShell GPU info failed: %v", err)
 		return []string{}, 0
 	}
 
@@ -552,82 +2301,151 @@ func (c *Collector) collectGPUInfoWindows() ([]string, uint64) {
 
 // collectGPUState 采集 GPU 使用率、显存占用和功耗 (带超时保护)
 // 支持: NVIDIA (nvidia-smi), AMD (rocm-smi/sysfs), Intel (sysfs/performance counter)
-func (c *Collector) collectGPUState() (float64, uint64, float64) {
+// 检测顺序: NVIDIA -> AMD -> 平台回退方案
+// 返回汇总值 (平均使用率、显存/功耗之和) 以及每张显卡的明细 (仅 NVIDIA/AMD 可用)
+func (c *Collector) collectGPUState() (float64, uint64, float64, []GPUStat) {
 	// 1. 首先尝试 NVIDIA GPU (nvidia-smi)
 	nvidiaSmi := c.getNvidiaSmiPath()
 	if nvidiaSmi != "" {
-		usage, mem, power := c.collectNvidiaGPUState(nvidiaSmi)
-		if usage > 0 || mem > 0 {
-			return usage, mem, power
+		gpus := c.collectNvidiaGPUState(nvidiaSmi)
+		if usage, mem, power := aggregateGPUStats(gpus); usage > 0 || mem > 0 {
+			return usage, mem, power, gpus
+		}
+	}
+
+	// 2. 其次尝试 AMD GPU (rocm-smi)，混合显卡环境下 NVIDIA 优先
+	if rocmSmi := c.getRocmSmiPath(); rocmSmi != "" {
+		gpus := c.collectAMDGPUState(rocmSmi)
+		if usage, mem, power := aggregateGPUStats(gpus); usage > 0 || mem > 0 {
+			return usage, mem, power, gpus
 		}
 	}
 
-	// 2. 如果没有 NVIDIA，尝试其他方案
+	// 3. 都没有时，尝试其他方案 (只有汇总使用率，没有每卡明细)
 	if runtime.GOOS == "windows" {
 		// 如果 meta 数据中已经确认没有 GPU 型号，则不再尝试采集使用率，避免频繁调用 PowerShell
 		c.mu.Lock()
 		hasGPU := c.cachedHostInfo != nil && len(c.cachedHostInfo.GPU) > 0
 		c.mu.Unlock()
 		if !hasGPU {
-			return 0, 0, 0
+			return 0, 0, 0, nil
 		}
 
 		// Windows: 使用 Performance Counter 采集所有 GPU
-		return c.collectGPUStateWindows()
+		usage, mem, power := c.collectGPUStateWindows()
+		return usage, mem, power, nil
 	} else if runtime.GOOS == "linux" {
-		// Linux: 尝试 AMD (rocm-smi / sysfs) 或 Intel (sysfs)
-		return c.collectGPUStateLinux()
+		// Linux: 尝试 AMD/Intel sysfs 或 intel_gpu_top
+		usage, mem, power := c.collectGPUStateLinux()
+		return usage, mem, power, nil
 	}
 
-	return 0, 0, 0
+	return 0, 0, 0, nil
+}
+
+// gpuStatsWithNames 为缺少型号的 GPU 明细补充主机信息中采集到的型号
+func (c *Collector) gpuStatsWithNames(gpus []GPUStat) []GPUStat {
+	result := make([]GPUStat, len(gpus))
+	copy(result, gpus)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cachedHostInfo == nil {
+		return result
+	}
+	for i := range result {
+		if result[i].Name == "" && result[i].Index < len(c.cachedHostInfo.GPU) {
+			result[i].Name = c.cachedHostInfo.GPU[result[i].Index]
+		}
+	}
+	return result
+}
+
+// aggregateGPUStats 汇总每卡数据: 使用率取平均，显存和功耗求和
+func aggregateGPUStats(gpus []GPUStat) (float64, uint64, float64) {
+	if len(gpus) == 0 {
+		return 0, 0, 0
+	}
+
+	var totalUsage, totalPower float64
+	var totalUsedMem uint64
+	for _, gpu := range gpus {
+		totalUsage += gpu.Utilization
+		totalUsedMem += gpu.MemUsed
+		totalPower += gpu.Power
+	}
+	return totalUsage / float64(len(gpus)), totalUsedMem, totalPower
 }
 
-// collectNvidiaGPUState 使用 NVML (优先) 或 nvidia-smi 采集 NVIDIA GPU 状态
-func (c *Collector) collectNvidiaGPUState(nvidiaSmi string) (float64, uint64, float64) {
+// collectNvidiaGPUState 使用 NVML (优先) 或 nvidia-smi 采集每张 NVIDIA GPU 的状态
+func (c *Collector) collectNvidiaGPUState(nvidiaSmi string) []GPUStat {
 	// 1. 尝试使用原生 NVML API (性能更高，不产生新进程)
-	if usage, usedMem, power, ok := c.collectNvidiaGPUStateNative(); ok {
-		return usage, usedMem, power
+	if gpus, ok := c.collectNvidiaGPUStateNative(); ok {
+		return gpus
 	}
 
 	// 2. 回退到 nvidia-smi 命令行工具
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, nvidiaSmi, "--query-gpu=utilization.gpu,memory.used,power.draw", "--format=csv,noheader,nounits")
+	cmd := exec.CommandContext(ctx, nvidiaSmi, "--query-gpu=index,name,utilization.gpu,memory.used,memory.total,power.draw,temperature.gpu,fan.speed", "--format=csv,noheader,nounits")
 	hideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
-		return 0, 0, 0
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 0 {
-		return 0, 0, 0
+		return nil
 	}
 
-	var totalUsage float64
-	var totalUsedMem uint64
-	var totalPower float64
-	var count int
-
-	for _, line := range lines {
+	var gpus []GPUStat
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
 		parts := strings.Split(line, ",")
-		if len(parts) >= 3 {
-			usage, _ := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-			used, _ := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
-			power, _ := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
-			totalUsage += usage
-			totalUsedMem += used * 1024 * 1024 // MiB 转为 Bytes
-			totalPower += power
-			count++
+		if len(parts) < 8 {
+			continue
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
 		}
+		// 不支持的字段输出为 [N/A]，解析失败时按 0 处理
+		index, _ := strconv.Atoi(parts[0])
+		usage, _ := strconv.ParseFloat(parts[2], 64)
+		used, _ := strconv.ParseUint(parts[3], 10, 64)
+		total, _ := strconv.ParseUint(parts[4], 10, 64)
+		power, _ := strconv.ParseFloat(parts[5], 64)
+		temp, _ := strconv.ParseFloat(parts[6], 64)
+		fan, _ := strconv.ParseFloat(parts[7], 64)
+		gpus = append(gpus, GPUStat{
+			Index:       index,
+			Name:        parts[1],
+			Utilization: usage,
+			MemUsed:     used * 1024 * 1024, // MiB 转为 Bytes
+			MemTotal:    total * 1024 * 1024,
+			Power:       power,
+			Temperature: temp,
+			FanSpeed:    fan,
+		})
 	}
+	return gpus
+}
 
-	if count == 0 {
-		return 0, 0, 0
+// averageGPUThermals 计算各卡温度和风扇转速的平均值 (不支持的卡读数为 0，不计入平均)
+func averageGPUThermals(gpus []GPUStat) (temp, fan float64) {
+	var tempCount, fanCount int
+	for _, gpu := range gpus {
+		if gpu.Temperature > 0 {
+			temp += gpu.Temperature
+			tempCount++
+		}
+		if gpu.FanSpeed > 0 {
+			fan += gpu.FanSpeed
+			fanCount++
+		}
 	}
-
-	return totalUsage / float64(count), totalUsedMem, totalPower
+	if tempCount > 0 {
+		temp /= float64(tempCount)
+	}
+	if fanCount > 0 {
+		fan /= float64(fanCount)
+	}
+	return temp, fan
 }
 
 // collectGPUStateWindows Windows 下采集 AMD/Intel/NVIDIA GPU 使用率
@@ -688,19 +2506,14 @@ if ($gpu.Average) { [math]::Round($gpu.Average, 1) } else { 0 }
 	return usage, 0, 0
 }
 
-// collectGPUStateLinux Linux 下采集 AMD/Intel GPU 使用率
+// collectGPUStateLinux Linux 下采集 AMD/Intel GPU 使用率 (无 rocm-smi 时)
 func (c *Collector) collectGPUStateLinux() (float64, uint64, float64) {
-	// 1. 尝试 AMD rocm-smi
-	if usage, mem, power := c.collectAMDGPULinux(); usage > 0 || mem > 0 {
-		return usage, mem, power
-	}
-
-	// 2. 尝试 AMD/Intel sysfs
+	// 1. 尝试 AMD/Intel sysfs
 	if usage := c.collectGPUFromSysfs(); usage > 0 {
 		return usage, 0, 0
 	}
 
-	// 3. 尝试 Intel intel_gpu_top (需要 intel-gpu-tools)
+	// 2. 尝试 Intel intel_gpu_top (需要 intel-gpu-tools)
 	if usage := c.collectIntelGPULinux(); usage > 0 {
 		return usage, 0, 0
 	}
@@ -708,44 +2521,119 @@ func (c *Collector) collectGPUStateLinux() (float64, uint64, float64) {
 	return 0, 0, 0
 }
 
-// collectAMDGPULinux 使用 rocm-smi 采集 AMD GPU
-func (c *Collector) collectAMDGPULinux() (float64, uint64, float64) {
-	if _, err := exec.LookPath("rocm-smi"); err != nil {
-		return 0, 0, 0
+// getRocmSmiPath 查找 AMD rocm-smi 工具 (Windows 下为 rocm-smi.exe)
+func (c *Collector) getRocmSmiPath() string {
+	name := "rocm-smi"
+	if runtime.GOOS == "windows" {
+		name = "rocm-smi.exe"
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p
 	}
+	return ""
+}
 
+// runRocmSmi 执行 rocm-smi 并解析 CSV 输出为 "列名 -> 值" 的行列表 (带 2 秒超时)
+func runRocmSmi(rocmSmi string, args ...string) []map[string]string {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	// rocm-smi --showuse --showmemuse --showpower --csv
-	cmd := exec.CommandContext(ctx, "rocm-smi", "--showuse", "--csv")
+	cmd := exec.CommandContext(ctx, rocmSmi, append(args, "--csv")...)
+	hideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
-		return 0, 0, 0
+		return nil
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
-		return 0, 0, 0
+	// rocm-smi 可能在 CSV 前后输出分隔线和提示信息，只保留以表头开始的部分
+	text := string(output)
+	if idx := strings.Index(text, "device,"); idx != -1 {
+		text = text[idx:]
+	}
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil || len(records) < 2 {
+		return nil
 	}
 
-	// 解析 CSV (跳过表头)
-	var totalUsage float64
-	var count int
-	for _, line := range lines[1:] {
-		parts := strings.Split(line, ",")
-		if len(parts) >= 2 {
-			// GPU Use (%) 通常在第二列
-			usage, _ := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-			totalUsage += usage
-			count++
+	header := records[0]
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		if len(record) != len(header) {
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			row[col] = strings.TrimSpace(record[i])
 		}
+		rows = append(rows, row)
 	}
+	return rows
+}
 
-	if count > 0 {
-		return totalUsage / float64(count), 0, 0
+// rocmColumn 按关键字 (不区分大小写) 查找列值，兼容不同 rocm-smi 版本的列名
+func rocmColumn(row map[string]string, keywords ...string) string {
+	for col, val := range row {
+		lower := strings.ToLower(col)
+		matched := true
+		for _, kw := range keywords {
+			if !strings.Contains(lower, kw) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return val
+		}
 	}
-	return 0, 0, 0
+	return ""
+}
+
+// collectAMDGPUMetadata 使用 rocm-smi 采集 AMD GPU 型号和显存总量
+func (c *Collector) collectAMDGPUMetadata(rocmSmi string) ([]string, uint64) {
+	rows := runRocmSmi(rocmSmi, "--showproductname", "--showmeminfo", "vram")
+
+	var models []string
+	var totalMem uint64
+	for _, row := range rows {
+		name := rocmColumn(row, "card series")
+		if name == "" {
+			name = rocmColumn(row, "card model")
+		}
+		if name == "" {
+			name = "AMD GPU"
+		}
+		models = append(models, name)
+		mem, _ := strconv.ParseUint(rocmColumn(row, "vram total memory"), 10, 64)
+		totalMem += mem // rocm-smi 显存单位为 Bytes
+	}
+	return models, totalMem
+}
+
+// collectAMDGPUState 使用 rocm-smi 采集每张 AMD GPU 的使用率、显存、功耗和温度
+func (c *Collector) collectAMDGPUState(rocmSmi string) []GPUStat {
+	rows := runRocmSmi(rocmSmi, "--showuse", "--showmeminfo", "vram", "--showpower", "--showtemp")
+
+	var gpus []GPUStat
+	for i, row := range rows {
+		index := i
+		if n, err := strconv.Atoi(strings.TrimPrefix(row["device"], "card")); err == nil {
+			index = n
+		}
+		usage, _ := strconv.ParseFloat(rocmColumn(row, "gpu use"), 64)
+		used, _ := strconv.ParseUint(rocmColumn(row, "vram total used memory"), 10, 64)
+		total, _ := strconv.ParseUint(rocmColumn(row, "vram total memory"), 10, 64)
+		power, _ := strconv.ParseFloat(rocmColumn(row, "power"), 64)
+		temp, _ := strconv.ParseFloat(rocmColumn(row, "temperature", "edge"), 64)
+		gpus = append(gpus, GPUStat{
+			Index:       index,
+			Utilization: usage,
+			MemUsed:     used,
+			MemTotal:    total,
+			Power:       power,
+			Temperature: temp,
+		})
+	}
+	return gpus
 }
 
 // collectGPUFromSysfs 从 Linux sysfs 读取 GPU 使用率
@@ -869,6 +2757,41 @@ func (c *Collector) getNvidiaSmiPath() string {
 
 // 废弃旧方法
 func (c *Collector) collectGPUUsage() float64 {
-	u, _, _ := c.collectGPUState()
+	u, _, _, _ := c.collectGPUState()
 	return u
 }
+
+// cachedHostStat 返回缓存的 host.Info() 结果，超过 maxAge 时重新查询 (maxAge 为 0 时强制刷新)
+// 查询失败时保留上一次的结果
+func (c *Collector) cachedHostStat(maxAge time.Duration) *host.InfoStat {
+	c.hostStatMu.Lock()
+	defer c.hostStatMu.Unlock()
+
+	if c.hostStat != nil && maxAge > 0 && time.Since(c.hostStatTime) < maxAge {
+		return c.hostStat
+	}
+	if stat, err := hostInfoStat(); err == nil {
+		c.hostStat = stat
+		c.hostStatTime = time.Now()
+	}
+	return c.hostStat
+}
+
+// hostStatFetchedAt 缓存的 host.Info() 结果的查询时间
+func (c *Collector) hostStatFetchedAt() time.Time {
+	c.hostStatMu.Lock()
+	defer c.hostStatMu.Unlock()
+	return c.hostStatTime
+}
+
+// uptimeSince 根据缓存的主机信息推算当前运行时长 (秒)
+// 优先使用 BootTime，没有 BootTime 时在查询时的 Uptime 上累加经过的时间
+func uptimeSince(stat *host.InfoStat, fetchedAt, now time.Time) uint64 {
+	if stat.BootTime > 0 && uint64(now.Unix()) >= stat.BootTime {
+		return uint64(now.Unix()) - stat.BootTime
+	}
+	if elapsed := now.Sub(fetchedAt); elapsed > 0 {
+		return stat.Uptime + uint64(elapsed/time.Second)
+	}
+	return stat.Uptime
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// TestCollectStateMemAvailable Linux 从 /proc/meminfo 的 MemAvailable 读取可用内存，上报值不应为 0
+func TestCollectStateMemAvailable(t *testing.T) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		t.Skipf("读取内存信息失败: %v", err)
+	}
+
+	state := newTestCollector().CollectState()
+	if state.MemAvailable == 0 {
+		t.Fatalf("MemAvailable = 0")
+	}
+	if state.MemAvailable > vm.Total {
+		t.Errorf("MemAvailable = %d, 超过内存总量 %d", state.MemAvailable, vm.Total)
+	}
+	if state.MemUsed == 0 || state.MemUsed > vm.Total {
+		t.Errorf("MemUsed = %d, 内存总量 %d", state.MemUsed, vm.Total)
+	}
+}
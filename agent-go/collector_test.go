@@ -0,0 +1,422 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// TestCollectTemperatures 传感器读数格式化，部分读取失败 (返回 Warnings) 时仍使用已有数据
+func TestCollectTemperatures(t *testing.T) {
+	orig := sensorsTemperatures
+	defer func() { sensorsTemperatures = orig }()
+	sensorsTemperatures = func() ([]host.TemperatureStat, error) {
+		return []host.TemperatureStat{
+			{SensorKey: "coretemp_package_id_0", Temperature: 54.04},
+			{SensorKey: "nvme_composite", Temperature: 38.25},
+			{SensorKey: "", Temperature: 40},          // 没有名称
+			{SensorKey: "acpitz", Temperature: 0},     // 无效读数
+			{SensorKey: "iwlwifi_1", Temperature: -1}, // 无效读数
+		}, errors.New("部分传感器读取失败")
+	}
+
+	c := &Collector{}
+	got := c.collectTemperatures()
+	want := []string{"coretemp_package_id_0:54.0", "nvme_composite:38.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectTemperatures() = %v, want %v", got, want)
+	}
+
+	// 节流期间不重复读取传感器
+	sensorsTemperatures = func() ([]host.TemperatureStat, error) {
+		t.Error("节流期间重复读取了传感器")
+		return nil, nil
+	}
+	if got := c.collectTemperatures(); !reflect.DeepEqual(got, want) {
+		t.Errorf("缓存的 collectTemperatures() = %v, want %v", got, want)
+	}
+}
+
+// TestFormatTemperaturesEmpty 没有传感器时返回空列表而不是 nil (上报为 [] 而不是 null)
+func TestFormatTemperaturesEmpty(t *testing.T) {
+	if got := formatTemperatures(nil); got == nil || len(got) != 0 {
+		t.Errorf("formatTemperatures(nil) = %#v, want empty slice", got)
+	}
+}
+
+// newTestCollector 关闭 GPU、Docker、公网 IP 等依赖外部命令和网络的采集项
+func newTestCollector() *Collector {
+	config := newDefaultConfig()
+	config.EnableGPU = false
+	config.EnableDocker = false
+	config.EnablePublicIP = false
+	config.EnableGeoIP = false
+	config.NTPServer = ""
+	config.DNSProbeHost = ""
+	return NewCollector(config)
+}
+
+// TestCPUPerCoreMatchesCores 每核使用率的数量与主机信息中的逻辑核心数一致
+func TestCPUPerCoreMatchesCores(t *testing.T) {
+	c := newTestCollector()
+	info := c.CollectHostInfo()
+	if info.Cores <= 0 {
+		t.Fatalf("CollectHostInfo().Cores = %d", info.Cores)
+	}
+
+	// 第一次采样建立基准
+	c.CollectState()
+	time.Sleep(200 * time.Millisecond)
+	state := c.CollectState()
+	if len(state.CPUPerCore) != info.Cores {
+		t.Errorf("len(CPUPerCore) = %d, want %d (Cores)", len(state.CPUPerCore), info.Cores)
+	}
+	for i, p := range state.CPUPerCore {
+		if p < 0 || p > 100 {
+			t.Errorf("CPUPerCore[%d] = %v, want 0-100", i, p)
+		}
+	}
+}
+
+// TestFilterPartitions 按文件系统类型 (不区分大小写) 和挂载点 (支持通配符) 排除分区
+func TestFilterPartitions(t *testing.T) {
+	partitions := []disk.PartitionStat{
+		{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+		{Device: "/dev/sda2", Mountpoint: "/boot", Fstype: "vfat"},
+		{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		{Device: "overlay", Mountpoint: "/var/lib/docker/overlay2/abc/merged", Fstype: "OVERLAY"},
+		{Device: "/dev/loop0", Mountpoint: "/snap/core/1", Fstype: "squashfs"},
+		{Device: "/dev/loop1", Mountpoint: "/snap/lxd/2", Fstype: "ext4"},
+		{Device: "/dev/sdb1", Mountpoint: "/data", Fstype: "xfs"},
+		{Device: "/dev/sdc1", Mountpoint: "/mnt/backup", Fstype: "ext4"},
+		{Device: "/dev/sdc2", Mountpoint: "/mnt/backup/archive", Fstype: "ext4"},
+		{Device: "/dev/sdd1", Mountpoint: "/mnt/backups", Fstype: "ext4"},
+		{Device: "shm", Mountpoint: "/var/lib/docker/containers/abc/mounts/shm", Fstype: "shm"},
+	}
+
+	got := filterPartitions(partitions,
+		[]string{"tmpfs", "overlay", "squashfs"},
+		[]string{"/snap/*", "/mnt/backup", "/var/lib/docker/*"})
+
+	var mounts []string
+	for _, p := range got {
+		mounts = append(mounts, p.Mountpoint)
+	}
+	want := []string{"/", "/boot", "/data", "/mnt/backups"}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("filterPartitions() mounts = %v, want %v", mounts, want)
+	}
+
+	if got := filterPartitions(partitions, nil, nil); len(got) != len(partitions) {
+		t.Errorf("未配置排除项时 filterPartitions() 返回 %d 个分区, want %d", len(got), len(partitions))
+	}
+}
+
+// diskHooks 测试中替换的磁盘数据源
+// 其他测试的 Collector 可能仍在后台刷新磁盘用量，不能直接改写 diskPartitions/diskUsage
+var diskHooks struct {
+	sync.Mutex
+	partitions func(bool) ([]disk.PartitionStat, error)
+	usage      func(string) (*disk.UsageStat, error)
+}
+
+func init() {
+	realPartitions, realUsage := diskPartitions, diskUsage
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) {
+		diskHooks.Lock()
+		f := diskHooks.partitions
+		diskHooks.Unlock()
+		if f == nil {
+			return realPartitions(all)
+		}
+		return f(all)
+	}
+	diskUsage = func(mountpoint string) (*disk.UsageStat, error) {
+		diskHooks.Lock()
+		f := diskHooks.usage
+		diskHooks.Unlock()
+		if f == nil {
+			return realUsage(mountpoint)
+		}
+		return f(mountpoint)
+	}
+}
+
+// setDiskHooks 在当前测试期间替换磁盘数据源
+func setDiskHooks(t *testing.T, partitions func(bool) ([]disk.PartitionStat, error), usage func(string) (*disk.UsageStat, error)) {
+	diskHooks.Lock()
+	diskHooks.partitions, diskHooks.usage = partitions, usage
+	diskHooks.Unlock()
+	t.Cleanup(func() {
+		diskHooks.Lock()
+		diskHooks.partitions, diskHooks.usage = nil, nil
+		diskHooks.Unlock()
+	})
+}
+
+// TestFirstCollectStateReportsDisk 第一次 CollectState 同步统计磁盘用量，不会上报 0
+func TestFirstCollectStateReportsDisk(t *testing.T) {
+	partitions := func(all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"},
+		}, nil
+	}
+	usage := func(mountpoint string) (*disk.UsageStat, error) {
+		switch mountpoint {
+		case "/":
+			return &disk.UsageStat{Path: "/", Used: 40 << 30, InodesTotal: 1000, InodesUsed: 300}, nil
+		default:
+			t.Errorf("统计了被排除的分区: %s", mountpoint)
+			return &disk.UsageStat{Path: mountpoint, Used: 1 << 30}, nil
+		}
+	}
+	setDiskHooks(t, partitions, usage)
+
+	c := newTestCollector()
+	state := c.CollectState()
+	if state.DiskUsed != 40<<30 {
+		t.Errorf("DiskUsed = %d, want %d", state.DiskUsed, uint64(40<<30))
+	}
+	if state.InodesUsed != 300 {
+		t.Errorf("InodesUsed = %d, want 300", state.InodesUsed)
+	}
+}
+
+// TestParseContainerPSLine Docker/Podman 的输出格式与异常行
+func TestParseContainerPSLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   psContainer
+		wantOK bool
+	}{
+		{
+			"docker",
+			`{"ID":"0123456789ab","Names":"web","Image":"nginx","State":"running","Status":"Up 2 hours","CreatedAt":"2024-01-01 00:00:00 +0000 UTC"}`,
+			psContainer{ID: "0123456789ab", Names: "web", Image: "nginx", State: "running", Status: "Up 2 hours", Created: "2024-01-01 00:00:00 +0000 UTC"},
+			true,
+		},
+		{
+			"podman",
+			`{"Id":"abcdef","Names":["db","db-alias"],"Image":"postgres","State":"Exited","Status":"Exited (0)","Created":0}`,
+			psContainer{ID: "abcdef", Names: "db,db-alias", Image: "postgres", State: "exited", Status: "Exited (0)", Created: time.Unix(0, 0).Format("2006-01-02 15:04:05 -0700 MST")},
+			true,
+		},
+		{"空行", "", psContainer{}, false},
+		{"截断的 JSON", `{"ID":"0123456789ab","Names":"web`, psContainer{}, false},
+		{"非 JSON 输出", "Cannot connect to the Docker daemon", psContainer{}, false},
+		{"缺少 ID", `{"Names":"web","State":"running"}`, psContainer{}, false},
+		{"JSON 数组", `["web"]`, psContainer{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseContainerPSLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("parseContainerPSLine() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseContainerPSLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCollectDockerInfoSkipsMalformedLines 异常行被跳过，不影响其他容器的统计
+func TestCollectDockerInfoSkipsMalformedLines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 Unix 可执行文件模拟 docker 命令")
+	}
+	// containerEngine 通过 PATH 检测 docker，放一个假的可执行文件
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	orig := containerPS
+	defer func() { containerPS = orig }()
+	containerPS = func(engine string) ([]byte, error) {
+		return []byte(strings.Join([]string{
+			`{"ID":"0123456789abcdef","Names":"web","Image":"nginx","State":"running","Status":"Up 2 hours"}`,
+			`{"ID":"broken`,
+			`WARNING: Error loading config file`,
+			`{"Names":"no-id","State":"running"}`,
+			`{"ID":"fedcba987654","Names":"job","Image":"alpine","State":"exited","Status":"Exited (0)"}`,
+		}, "\n")), nil
+	}
+
+	info := newTestCollector().collectDockerInfo()
+	if !info.Installed || info.Runtime != "docker" {
+		t.Fatalf("Installed = %v, Runtime = %q", info.Installed, info.Runtime)
+	}
+	if info.Running != 1 || info.Stopped != 1 {
+		t.Errorf("Running = %d, Stopped = %d, want 1, 1", info.Running, info.Stopped)
+	}
+	var names []string
+	for _, c := range info.Containers {
+		names = append(names, c.Name)
+	}
+	if want := []string{"web", "job"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("containers = %v, want %v", names, want)
+	}
+	if len(info.Containers) > 0 && info.Containers[0].ID != "0123456789ab" {
+		t.Errorf("ID = %q, want short ID", info.Containers[0].ID)
+	}
+}
+
+// TestUptimeSince 由 BootTime 或查询时的 Uptime 推算运行时长
+func TestUptimeSince(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fetchedAt := now.Add(-90 * time.Second)
+	tests := []struct {
+		name string
+		stat host.InfoStat
+		want uint64
+	}{
+		{"BootTime", host.InfoStat{BootTime: 1700000000 - 3600, Uptime: 10}, 3600},
+		{"没有 BootTime 时累加经过的时间", host.InfoStat{Uptime: 100}, 190},
+		{"BootTime 晚于当前时间 (时钟回拨)", host.InfoStat{BootTime: 1700000000 + 60, Uptime: 100}, 190},
+	}
+	for _, tt := range tests {
+		if got := uptimeSince(&tt.stat, fetchedAt, now); got != tt.want {
+			t.Errorf("%s: uptimeSince() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestUptimeAdvancesWithoutHostInfo 运行时长在缓存有效期内持续增长，不重复调用 host.Info()
+func TestUptimeAdvancesWithoutHostInfo(t *testing.T) {
+	orig := hostInfoStat
+	defer func() { hostInfoStat = orig }()
+	calls := 0
+	hostInfoStat = func() (*host.InfoStat, error) {
+		calls++
+		return &host.InfoStat{Uptime: 1000}, nil
+	}
+
+	c := newTestCollector()
+	var first State
+	c.collectSystemState()(&first)
+
+	// 模拟距离上次查询已经过去 30 秒
+	c.hostStatMu.Lock()
+	c.hostStatTime = c.hostStatTime.Add(-30 * time.Second)
+	c.hostStatMu.Unlock()
+
+	var second State
+	c.collectSystemState()(&second)
+
+	if calls != 1 {
+		t.Errorf("host.Info() 调用了 %d 次, want 1", calls)
+	}
+	if first.Uptime < 1000 || second.Uptime < first.Uptime+30 {
+		t.Errorf("Uptime = %d -> %d, want 至少增长 30 秒", first.Uptime, second.Uptime)
+	}
+}
+
+// TestApplyMemStat 可用/缓存/缓冲内存按原值写入 State
+func TestApplyMemStat(t *testing.T) {
+	var s State
+	applyMemStat(&s, &mem.VirtualMemoryStat{Total: 8 << 30, Used: 3 << 30, Available: 4 << 30, Cached: 2 << 30, Buffers: 256 << 20})
+	if s.MemUsed != 3<<30 || s.MemAvailable != 4<<30 || s.MemCached != 2<<30 || s.MemBuffers != 256<<20 {
+		t.Errorf("applyMemStat() = used %d, available %d, cached %d, buffers %d", s.MemUsed, s.MemAvailable, s.MemCached, s.MemBuffers)
+	}
+}
+
+// TestCollectStateProcessCount 第一次 CollectState 即上报进程数
+func TestCollectStateProcessCount(t *testing.T) {
+	state := newTestCollector().CollectState()
+	if state.ProcessCount <= 0 {
+		t.Errorf("ProcessCount = %d, want > 0", state.ProcessCount)
+	}
+	if state.ProcessRunning > state.ProcessCount || state.ProcessZombie > state.ProcessCount {
+		t.Errorf("ProcessRunning = %d, ProcessZombie = %d, 超过 ProcessCount %d", state.ProcessRunning, state.ProcessZombie, state.ProcessCount)
+	}
+}
+
+// TestCollectStateResetsDisabledCollectors 热重载关闭采集项后，下一次状态不再沿用关闭前的值
+func TestCollectStateResetsDisabledCollectors(t *testing.T) {
+	c := newTestCollector()
+	next := *c.cfg()
+	next.EnableConnCount = false
+	next.EnableSMART = false
+	c.config.Store(&next)
+
+	// 模拟关闭前各采集项上报过的值
+	c.lastState = &State{
+		TcpConnCount:  12,
+		TcpStates:     map[string]int{"ESTABLISHED": 12},
+		UdpConnCount:  3,
+		InboundConns:  5,
+		OutboundConns: 7,
+		Docker:        DockerInfo{Installed: true, Running: 2, Containers: []DockerContainer{{ID: "abc"}}},
+		GPU:           40,
+		GPUMemUsed:    1 << 30,
+		GPUTemp:       65,
+		GPUs:          []GPUStat{{Index: 0}},
+		ClockOffsetMs: 120,
+		TimeSynced:    true,
+		DiskHealth:    []DiskHealthStat{{Device: "/dev/sda"}},
+		DNSLatencyMs:  8,
+		DNSResolveOK:  true,
+	}
+
+	state := c.CollectState()
+	if state.TcpConnCount != 0 || len(state.TcpStates) != 0 || state.UdpConnCount != 0 || state.InboundConns != 0 || state.OutboundConns != 0 {
+		t.Errorf("连接数未清零: tcp=%d states=%v udp=%d in=%d out=%d", state.TcpConnCount, state.TcpStates, state.UdpConnCount, state.InboundConns, state.OutboundConns)
+	}
+	if state.Docker.Installed || state.Docker.Running != 0 || len(state.Docker.Containers) != 0 {
+		t.Errorf("Docker = %+v, want 零值", state.Docker)
+	}
+	if state.GPU != 0 || state.GPUMemUsed != 0 || state.GPUTemp != 0 || len(state.GPUs) != 0 {
+		t.Errorf("GPU 字段未清零: gpu=%v mem=%d temp=%v gpus=%v", state.GPU, state.GPUMemUsed, state.GPUTemp, state.GPUs)
+	}
+	if state.ClockOffsetMs != 0 || state.TimeSynced {
+		t.Errorf("ClockOffsetMs = %v, TimeSynced = %v, want 零值", state.ClockOffsetMs, state.TimeSynced)
+	}
+	if len(state.DiskHealth) != 0 {
+		t.Errorf("DiskHealth = %v, want 空", state.DiskHealth)
+	}
+	if state.DNSLatencyMs != 0 || state.DNSResolveOK {
+		t.Errorf("DNSLatencyMs = %v, DNSResolveOK = %v, want 零值", state.DNSLatencyMs, state.DNSResolveOK)
+	}
+}
+
+// TestCountProcessStates 按 /proc/<pid>/stat 的状态字段计数，进程名中的空格和括号不影响解析
+func TestCountProcessStates(t *testing.T) {
+	procDir := t.TempDir()
+	stats := map[string]string{
+		"1":   "1 (systemd) S 0 1 1 0 -1",
+		"42":  "42 (my (weird) proc) R 1 42 42 0 -1",
+		"43":  "43 (defunct) Z 1 43 43 0 -1",
+		"100": "100 (bash) R 1 100 100 0 -1",
+	}
+	for pid, stat := range stats {
+		if err := os.MkdirAll(filepath.Join(procDir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procDir, pid, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 非进程目录和已退出 (没有 stat) 的进程不计入
+	os.MkdirAll(filepath.Join(procDir, "sys"), 0755)
+	os.MkdirAll(filepath.Join(procDir, "999"), 0755)
+
+	got := countProcessStates(procDir)
+	want := processStates{Total: 4, Running: 2, Zombie: 1}
+	if got != want {
+		t.Errorf("countProcessStates() = %+v, want %+v", got, want)
+	}
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"bytes"
 	"runtime"
 	"syscall"
 	"unsafe"
@@ -77,10 +78,9 @@ func (c *Collector) collectGPUUsagePDH() (float64, bool) {
 }
 
 // NVIDIA NVML 原生支持 (Windows 版)
-func (c *Collector) collectNvidiaGPUStateNative() (float64, uint64, float64, bool) {
+// 枚举所有设备，逐卡读取使用率、显存、功耗、温度和风扇转速；单项读取失败时该项按 0 处理
+func (c *Collector) collectNvidiaGPUStateNative() ([]GPUStat, bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	if !c.nvmlInitialized {
 		if c.nvmlLib == nil {
 			c.nvmlLib = syscall.NewLazyDLL("nvml.dll")
@@ -90,48 +90,89 @@ func (c *Collector) collectNvidiaGPUStateNative() (float64, uint64, float64, boo
 		// 尝试初始化
 		initProc := lib.NewProc("nvmlInit_v2")
 		if err := initProc.Find(); err != nil {
-			return 0, 0, 0, false
+			c.mu.Unlock()
+			return nil, false
 		}
 		ret, _, _ := initProc.Call()
 		if ret != 0 {
-			return 0, 0, 0, false
+			c.mu.Unlock()
+			return nil, false
 		}
 		c.nvmlInitialized = true
 	}
-
 	lib := c.nvmlLib.(*syscall.LazyDLL)
-	// 获取第一个设备的句柄 (简化处理)
-	getHandle := lib.NewProc("nvmlDeviceGetHandleByIndex_v2")
-	var device uintptr
-	ret, _, _ := getHandle.Call(0, uintptr(unsafe.Pointer(&device)))
-	if ret != 0 {
-		return 0, 0, 0, false
-	}
+	c.mu.Unlock()
 
-	// 获取利用率
-	getUtil := lib.NewProc("nvmlDeviceGetUtilizationRates")
-	var util struct {
-		GPU    uint32
-		Memory uint32
-	}
-	ret, _, _ = getUtil.Call(device, uintptr(unsafe.Pointer(&util)))
-	if ret != 0 {
-		return 0, 0, 0, false
+	// NVML 本身是线程安全的，初始化后的查询不需要持有 c.mu
+	var count uint32
+	ret, _, _ := lib.NewProc("nvmlDeviceGetCount_v2").Call(uintptr(unsafe.Pointer(&count)))
+	if ret != 0 || count == 0 {
+		return nil, false
 	}
 
-	// 获取显存
+	getHandle := lib.NewProc("nvmlDeviceGetHandleByIndex_v2")
+	getName := lib.NewProc("nvmlDeviceGetName")
+	getUtil := lib.NewProc("nvmlDeviceGetUtilizationRates")
 	getMem := lib.NewProc("nvmlDeviceGetMemoryInfo")
-	var mem struct {
-		Total uint64
-		Free  uint64
-		Used  uint64
-	}
-	ret, _, _ = getMem.Call(device, uintptr(unsafe.Pointer(&mem)))
-
-	// 获取功耗 (单位通常是毫瓦)
 	getPower := lib.NewProc("nvmlDeviceGetPowerUsage")
-	var power uint32
-	ret, _, _ = getPower.Call(device, uintptr(unsafe.Pointer(&power)))
+	getTemp := lib.NewProc("nvmlDeviceGetTemperature")
+	getFan := lib.NewProc("nvmlDeviceGetFanSpeed")
+
+	gpus := make([]GPUStat, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var device uintptr
+		if ret, _, _ := getHandle.Call(uintptr(i), uintptr(unsafe.Pointer(&device))); ret != 0 {
+			continue
+		}
+		gpu := GPUStat{Index: int(i)}
+
+		// 设备名称 (NVML_DEVICE_NAME_V2_BUFFER_SIZE = 96)
+		var name [96]byte
+		if ret, _, _ := getName.Call(device, uintptr(unsafe.Pointer(&name[0])), uintptr(len(name))); ret == 0 {
+			if n := bytes.IndexByte(name[:], 0); n >= 0 {
+				gpu.Name = string(name[:n])
+			}
+		}
+
+		// 获取利用率
+		var util struct {
+			GPU    uint32
+			Memory uint32
+		}
+		if ret, _, _ := getUtil.Call(device, uintptr(unsafe.Pointer(&util))); ret == 0 {
+			gpu.Utilization = float64(util.GPU)
+		}
+
+		// 获取显存
+		var mem struct {
+			Total uint64
+			Free  uint64
+			Used  uint64
+		}
+		if ret, _, _ := getMem.Call(device, uintptr(unsafe.Pointer(&mem))); ret == 0 {
+			gpu.MemUsed = mem.Used
+			gpu.MemTotal = mem.Total
+		}
 
-	return float64(util.GPU), mem.Used, float64(power) / 1000.0, true
+		// 获取功耗 (单位是毫瓦)
+		var power uint32
+		if ret, _, _ := getPower.Call(device, uintptr(unsafe.Pointer(&power))); ret == 0 {
+			gpu.Power = float64(power) / 1000.0
+		}
+
+		// 核心温度 (NVML_TEMPERATURE_GPU = 0)
+		var temp uint32
+		if ret, _, _ := getTemp.Call(device, 0, uintptr(unsafe.Pointer(&temp))); ret == 0 {
+			gpu.Temperature = float64(temp)
+		}
+
+		// 风扇转速 (%)，被动散热的卡返回 NVML_ERROR_NOT_SUPPORTED
+		var fan uint32
+		if ret, _, _ := getFan.Call(device, uintptr(unsafe.Pointer(&fan))); ret == 0 {
+			gpu.FanSpeed = float64(fan)
+		}
+
+		gpus = append(gpus, gpu)
+	}
+	return gpus, len(gpus) > 0
 }
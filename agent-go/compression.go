@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// compressionLogEvery 调试模式下每发送多少帧输出一次压缩率
+const compressionLogEvery = 200
+
+// countingConn 统计实际写入网络的字节数 (压缩后，含 WebSocket 帧头和 TLS 开销)
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// countingDialContext 包装拨号函数，返回的连接写入时累加到 written
+func countingDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error), written *atomic.Int64) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, written: written}, nil
+	}
+}
+
+// compressionStats 单个连接上的发送统计，用于计算压缩率
+type compressionStats struct {
+	wire    atomic.Int64 // 写入网络的字节数
+	payload int64        // Socket.IO 消息原始字节数 (调用方持有 a.mu)
+	frames  int
+}
+
+// record 记录一帧原始消息，每 compressionLogEvery 帧在调试日志中输出一次累计压缩率
+func (s *compressionStats) record(size int) {
+	s.payload += int64(size)
+	s.frames++
+	if s.frames%compressionLogEvery != 0 || !logger.Enabled(LevelDebug) {
+		return
+	}
+	wire := s.wire.Load()
+	if s.payload > 0 && wire > 0 {
+		logger.Debugf("[Agent] 压缩统计: 原始 %d 字节，实际发送 %d 字节 (%.1f%%)",
+			s.payload, wire, float64(wire)/float64(s.payload)*100)
+	}
+}
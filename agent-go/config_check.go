@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// defaultMinReportInterval reportInterval 的默认下限 (毫秒)，过小的间隔会让 GPU/连接扫描和服务端负载过高
+const defaultMinReportInterval = 500
+
+// clampReportInterval 将低于 minReportInterval 的 reportInterval 调整为下限，返回是否做了调整
+func clampReportInterval(config *Config) bool {
+	if config.MinReportInterval <= 0 || config.ReportInterval <= 0 || config.ReportInterval >= config.MinReportInterval {
+		return false
+	}
+	logger.Warnf("[Config] reportInterval %dms 低于下限 %dms (minReportInterval)，已调整为 %dms",
+		config.ReportInterval, config.MinReportInterval, config.MinReportInterval)
+	config.ReportInterval = config.MinReportInterval
+	return true
+}
+
+// validateConfig 校验合并后的配置 (启动、SIGHUP 重新加载和 validate 子命令共用)
+// 配置文件、环境变量和命令行参数合并后统一在这里处理，reportInterval 低于下限时会被调整
+func validateConfig(config *Config) []error {
+	var errs []error
+
+	if config.ServerID == "" {
+		errs = append(errs, fmt.Errorf("缺少 serverId，使用 --id 或 API_MONITOR_SERVER_ID 指定"))
+	}
+	if config.AgentKey == "" {
+		errs = append(errs, fmt.Errorf("缺少 agentKey，使用 -k 或 API_MONITOR_KEY 指定"))
+	}
+
+	field := "serverUrl"
+	if len(config.ServerURLs) > 0 {
+		field = "serverUrls"
+	}
+	for _, serverURL := range serverURLList(config) {
+		if u, err := url.Parse(serverURL); err != nil {
+			errs = append(errs, fmt.Errorf("%s 无法解析: %v", field, err))
+		} else if u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s 缺少主机名: %q", field, serverURL))
+		} else {
+			switch u.Scheme {
+			case "http", "https":
+			default:
+				errs = append(errs, fmt.Errorf("%s 协议不受支持: %q (仅支持 http/https)", field, u.Scheme))
+			}
+		}
+	}
+
+	if config.EngineIOVersion != engineIOv3 && config.EngineIOVersion != engineIOv4 {
+		errs = append(errs, fmt.Errorf("engineIoVersion 只能为 3 或 4: %d", config.EngineIOVersion))
+	}
+	switch config.Transport {
+	case "", transportAuto, transportWebSocket, transportPolling:
+	default:
+		errs = append(errs, fmt.Errorf("transport 只能为 auto、websocket 或 polling: %q", config.Transport))
+	}
+
+	if unknown := unknownFields(config.StateFields, reflect.TypeOf(State{})); len(unknown) > 0 {
+		errs = append(errs, fmt.Errorf("stateFields 包含未知字段: %s", strings.Join(unknown, ", ")))
+	}
+	if unknown := unknownFields(config.HostInfoFields, reflect.TypeOf(HostInfo{})); len(unknown) > 0 {
+		errs = append(errs, fmt.Errorf("hostInfoFields 包含未知字段: %s", strings.Join(unknown, ", ")))
+	}
+
+	if config.GeoIPEndpoint != "" {
+		if u, err := url.Parse(config.GeoIPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("geoIpEndpoint 无效: %q (需要 http/https 地址)", config.GeoIPEndpoint))
+		}
+	}
+
+	if config.DNSResolver != "" {
+		if host, _, err := net.SplitHostPort(dnsResolverAddr(config.DNSResolver)); err != nil || net.ParseIP(host) == nil {
+			errs = append(errs, fmt.Errorf("dnsResolver 无效: %q (需要 ip 或 ip:port)", config.DNSResolver))
+		}
+	}
+
+	for i, rule := range config.Alerts {
+		if err := validateAlertRule(rule); err != nil {
+			errs = append(errs, fmt.Errorf("alerts[%d]: %v", i, err))
+		}
+	}
+
+	for key := range config.ExecEnv {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			errs = append(errs, fmt.Errorf("execEnv 变量名无效: %q", key))
+		}
+	}
+	if config.PTYCommand != "" && strings.TrimSpace(config.PTYCommand) == "" {
+		errs = append(errs, fmt.Errorf("ptyCommand 不能只包含空白"))
+	}
+
+	if config.DockerMaxContainers < 0 {
+		errs = append(errs, fmt.Errorf("dockerMaxContainers 不能为负数"))
+	}
+
+	if config.ReportInterval <= 0 {
+		errs = append(errs, fmt.Errorf("reportInterval 必须大于 0"))
+	}
+	if config.HostInfoInterval <= 0 {
+		errs = append(errs, fmt.Errorf("hostInfoInterval 必须大于 0"))
+	}
+	clampReportInterval(config)
+	if config.HostInfoInterval > 0 && config.ReportInterval > 0 && config.HostInfoInterval < config.ReportInterval {
+		errs = append(errs, fmt.Errorf("hostInfoInterval (%dms) 不能小于 reportInterval (%dms)", config.HostInfoInterval, config.ReportInterval))
+	}
+	if config.ReconnectDelay <= 0 {
+		errs = append(errs, fmt.Errorf("reconnectDelay 必须大于 0"))
+	}
+	if config.TopProcessCount < 0 {
+		errs = append(errs, fmt.Errorf("topProcessCount 不能为负数"))
+	}
+	if config.LogLevel != "" && !strings.EqualFold(levelNames[parseLogLevel(config.LogLevel)], config.LogLevel) {
+		errs = append(errs, fmt.Errorf("未知的 logLevel: %s (可选 debug/info/warn/error)", config.LogLevel))
+	}
+	if config.ProxyURL != "" {
+		if _, _, err := parseProxyURL(config.ProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("proxyUrl 无效: %v", err))
+		}
+	}
+	// 证书配置错误时不能退回到不带证书连接 (服务端要求 mTLS 或使用私有 CA 时会连接失败或信任错误的证书)
+	if config.ClientKeyFile != "" && config.ClientCertFile == "" {
+		errs = append(errs, fmt.Errorf("设置了 clientKeyFile 但缺少 clientCertFile"))
+	}
+	if _, err := loadTLSConfig(config); err != nil {
+		errs = append(errs, fmt.Errorf("TLS 配置无效: %v", err))
+	}
+
+	return errs
+}
+
+// redactedConfig 返回隐藏密钥后的配置副本，用于输出
+func redactedConfig(config *Config) Config {
+	c := *config
+	if c.AgentKey != "" {
+		c.AgentKey = "******"
+	}
+	return c
+}
+
+// runConfigCheck 输出合并后的有效配置和校验结果，返回进程退出码
+func runConfigCheck(config *Config, configPath string) int {
+	if _, err := os.Stat(configPath); err == nil {
+		fmt.Printf("配置文件: %s\n", configPath)
+	} else {
+		fmt.Printf("配置文件: %s (不存在，仅使用默认值、环境变量和命令行参数)\n", configPath)
+	}
+
+	data, _ := json.MarshalIndent(redactedConfig(config), "", "  ")
+	fmt.Println(string(data))
+	fmt.Println()
+
+	errs := validateConfig(config)
+	if len(errs) == 0 {
+		fmt.Println("✅ 配置有效")
+		return 0
+	}
+	for _, err := range errs {
+		fmt.Printf("❌ %v\n", err)
+	}
+	return 1
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidateConfigTLSFiles 证书文件无法加载时校验失败，而不是在启动时退回到不带证书连接
+func TestValidateConfigTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	badCA := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(badCA, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"未配置证书", func(c *Config) {}, ""},
+		{"客户端证书不存在", func(c *Config) { c.ClientCertFile = filepath.Join(dir, "missing.pem") }, "加载客户端证书失败"},
+		{"CA 证书不存在", func(c *Config) { c.CACertFile = filepath.Join(dir, "missing-ca.pem") }, "读取 CA 证书失败"},
+		{"CA 证书无效", func(c *Config) { c.CACertFile = badCA }, "没有有效的 PEM 证书"},
+		{"只有私钥", func(c *Config) { c.ClientKeyFile = filepath.Join(dir, "key.pem") }, "缺少 clientCertFile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newDefaultConfig()
+			config.ServerID = "test"
+			config.AgentKey = "test"
+			tt.modify(config)
+
+			var msgs []string
+			for _, err := range validateConfig(config) {
+				msgs = append(msgs, err.Error())
+			}
+			joined := strings.Join(msgs, "; ")
+			if tt.want == "" {
+				if len(msgs) > 0 {
+					t.Errorf("validateConfig() = %s, want no errors", joined)
+				}
+				return
+			}
+			if !strings.Contains(joined, tt.want) {
+				t.Errorf("validateConfig() = %q, want error containing %q", joined, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+// connectionStats 连接稳定性统计 (通过 /status 和 /metrics 暴露，用于发现频繁断线重连)
+type connectionStats struct {
+	ReconnectCount       int       `json:"reconnect_count"`                  // 启动以来的重连次数 (不含首次连接)
+	LastConnectedAt      time.Time `json:"last_connected_at,omitempty"`      // 最近一次建立连接的时间
+	LastDisconnectReason string    `json:"last_disconnect_reason,omitempty"` // 最近一次连接断开的原因
+	Transport            string    `json:"transport,omitempty"`              // 当前/最近一次连接使用的传输方式 (websocket / polling)
+}
+
+// noteDisconnect 记录当前连接即将断开的原因 (以第一个原因为准，主动断开时先于读取错误记录)
+func (a *AgentClient) noteDisconnect(reason string) {
+	a.mu.Lock()
+	if a.dropReason == "" {
+		a.dropReason = reason
+	}
+	a.mu.Unlock()
+}
+
+// connectionStats 返回连接统计的快照
+func (a *AgentClient) connectionStats() connectionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.connStats
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// currentCPUFreq 当前各核心的平均频率 (MHz)
+// Linux 优先读取 cpufreq 的 scaling_cur_freq，其次 /proc/cpuinfo 的 "cpu MHz"；其他平台不支持时返回 false
+func currentCPUFreq() (float64, bool) {
+	if paths, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq"); len(paths) > 0 {
+		var total float64
+		var n int
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			khz, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+			if err != nil || khz <= 0 {
+				continue
+			}
+			total += khz / 1000
+			n++
+		}
+		if n > 0 {
+			return total / float64(n), true
+		}
+	}
+
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		if mhz := parseCPUInfoMhz(string(data)); mhz > 0 {
+			return mhz, true
+		}
+	}
+	return 0, false
+}
+
+// parseCPUInfoMhz 计算 /proc/cpuinfo 中所有 "cpu MHz" 行的平均值 (没有时返回 0)
+func parseCPUInfoMhz(data string) float64 {
+	var total float64
+	var n int
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "cpu MHz" {
+			continue
+		}
+		mhz, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || mhz <= 0 {
+			continue
+		}
+		total += mhz
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+// cpuBaseFreq 主机信息中缓存的标称频率 (MHz)
+func (c *Collector) cpuBaseFreq() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cachedHostInfo != nil {
+		return c.cachedHostInfo.CPUFreqBaseMhz
+	}
+	return 0
+}
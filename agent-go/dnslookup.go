@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSLookupRequest DNS 查询任务参数 (也可以直接传域名，查询 A 记录)
+type DNSLookupRequest struct {
+	Host string `json:"host"`
+	Type string `json:"type"` // A / AAAA / MX / TXT / CNAME，默认 A
+}
+
+// DNSLookupResult DNS 查询结果
+type DNSLookupResult struct {
+	Host        string   `json:"host"`
+	Type        string   `json:"type"`
+	Resolver    string   `json:"resolver"` // 使用的 DNS 服务器，system 为系统解析器
+	Records     []string `json:"records"`
+	ResolveTime float64  `json:"resolve_time"`         // 毫秒
+	ErrorType   string   `json:"error_type,omitempty"` // nxdomain (域名不存在或没有该类型记录) / timeout / error
+	Error       string   `json:"error,omitempty"`
+}
+
+// dnsResolverAddr 补全 DNS 服务器地址的端口 (默认 53)
+func dnsResolverAddr(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+}
+
+// newDNSResolver 返回使用指定 DNS 服务器的解析器，addr 为空时使用系统解析器
+func newDNSResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	server := dnsResolverAddr(addr)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// runDNSLookup 按 dnsResolver 配置解析域名 (超时由 ctx 控制)
+// 只有参数无效时返回 error，解析失败记录在结果的 ErrorType/Error 中
+func runDNSLookup(ctx context.Context, config *Config, data string) (*DNSLookupResult, error) {
+	var req DNSLookupRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		// 兼容直接传域名
+		req.Host = data
+	}
+	req.Host = strings.TrimSpace(req.Host)
+	req.Type = strings.ToUpper(strings.TrimSpace(req.Type))
+	if req.Type == "" {
+		req.Type = "A"
+	}
+	if req.Host == "" {
+		return nil, fmt.Errorf("域名不能为空")
+	}
+
+	result := &DNSLookupResult{Host: req.Host, Type: req.Type, Resolver: "system", Records: []string{}}
+	if config.DNSResolver != "" {
+		result.Resolver = dnsResolverAddr(config.DNSResolver)
+	}
+	resolver := newDNSResolver(config.DNSResolver)
+
+	start := time.Now()
+	var err error
+	switch req.Type {
+	case "A", "AAAA":
+		network := "ip4"
+		if req.Type == "AAAA" {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = resolver.LookupIP(ctx, network, req.Host)
+		for _, ip := range ips {
+			result.Records = append(result.Records, ip.String())
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = resolver.LookupMX(ctx, req.Host)
+		for _, mx := range mxs {
+			result.Records = append(result.Records, strconv.Itoa(int(mx.Pref))+" "+mx.Host)
+		}
+	case "TXT":
+		var txts []string
+		txts, err = resolver.LookupTXT(ctx, req.Host)
+		result.Records = append(result.Records, txts...)
+	case "CNAME":
+		var cname string
+		cname, err = resolver.LookupCNAME(ctx, req.Host)
+		if cname != "" {
+			result.Records = append(result.Records, cname)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的记录类型: %s (支持 A/AAAA/MX/TXT/CNAME)", req.Type)
+	}
+	result.ResolveTime = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		result.ErrorType = classifyDNSError(ctx, err)
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// classifyDNSError 区分域名不存在、超时和其他解析错误
+func classifyDNSError(ctx context.Context, err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return "nxdomain"
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout, errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
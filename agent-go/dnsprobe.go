@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DNS 解析延迟探测
+const (
+	dnsProbeInterval = time.Minute     // 探测间隔
+	dnsProbeTimeout  = 5 * time.Second // 单次解析超时
+)
+
+// probeDNS 使用系统解析器解析 host，返回耗时 (毫秒)
+func probeDNS(host string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return 0, err
+	}
+	return float64(time.Since(start)) / float64(time.Millisecond), nil
+}
+
+// collectDNSState 解析 dnsProbeHost 的耗时 (每 dnsProbeInterval 探测一次，期间沿用缓存)
+// 解析失败时延迟为 -1
+func (c *Collector) collectDNSState() func(*State) {
+	c.mu.Lock()
+	if time.Since(c.lastDNSProbeTime) < dnsProbeInterval {
+		latency, ok := c.lastDNSLatency, c.lastDNSResolveOK
+		c.mu.Unlock()
+		return func(s *State) { applyDNSState(s, latency, ok) }
+	}
+	c.lastDNSProbeTime = time.Now()
+	c.mu.Unlock()
+
+	latency, err := probeDNS(c.cfg().DNSProbeHost)
+	ok := err == nil
+	if !ok {
+		latency = -1
+		logger.Warnf("[DNS] 解析 %s 失败: %v", c.cfg().DNSProbeHost, err)
+	}
+
+	c.mu.Lock()
+	c.lastDNSLatency = latency
+	c.lastDNSResolveOK = ok
+	c.mu.Unlock()
+	return func(s *State) { applyDNSState(s, latency, ok) }
+}
+
+func applyDNSState(s *State, latency float64, ok bool) {
+	s.DNSLatencyMs = latency
+	s.DNSResolveOK = ok
+}
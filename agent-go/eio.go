@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Engine.IO 协议版本: 4 对应 Socket.IO v3/v4 服务端，3 对应 Socket.IO v2 服务端
+// 两者的差异:
+//   - 握手的 polling 响应: v4 为 0{...}；v3 为带长度前缀的 payload，如 96:0{...}2:40
+//   - 心跳: v4 由服务端发送 ping (2)，客户端回复 pong (3)；v3 由客户端发送 ping，服务端回复 pong
+//   - v3 服务端会自动连接默认命名空间并发送 40，需要跳过后再等待 40/agent
+const (
+	engineIOv3 = 3
+	engineIOv4 = 4
+)
+
+// engineIOVersion 配置的 Engine.IO 版本 (未配置或无效时为 4)
+func engineIOVersion(config *Config) int {
+	if config.EngineIOVersion == engineIOv3 {
+		return engineIOv3
+	}
+	return engineIOv4
+}
+
+// eio3PayloadPrefix v3 polling payload 中每个包前面的长度前缀
+var eio3PayloadPrefix = regexp.MustCompile(`^(\d+):`)
+
+// parseHandshakeBody 从握手的 polling 响应中取出 open 包的 JSON，并检测协议版本是否与配置不符
+func parseHandshakeBody(body string, eio int) (string, error) {
+	body = strings.TrimSpace(body)
+	m := eio3PayloadPrefix.FindStringSubmatch(body)
+
+	if eio == engineIOv4 {
+		if m != nil {
+			return "", fmt.Errorf("服务端使用 Engine.IO v3 (Socket.IO v2)，请设置 engineIoVersion 为 3")
+		}
+		if !strings.HasPrefix(body, "0{") {
+			return "", fmt.Errorf("无效的握手响应")
+		}
+		return body[1:], nil
+	}
+
+	if m == nil {
+		if strings.HasPrefix(body, "0{") {
+			return "", fmt.Errorf("服务端使用 Engine.IO v4 (Socket.IO v3/v4)，请设置 engineIoVersion 为 4 (或删除该配置)")
+		}
+		return "", fmt.Errorf("无效的握手响应")
+	}
+	packets, err := decodePollingPayload(body, engineIOv3)
+	if err != nil || !strings.HasPrefix(packets[0], "0{") {
+		return "", fmt.Errorf("无效的握手响应")
+	}
+	return packets[0][1:], nil
+}
+
+// handshakeVersionError 服务端以 400 拒绝握手时，判断是否为协议版本不匹配 ({"code":5,"message":"Unsupported protocol version"})
+func handshakeVersionError(body string, eio int) error {
+	if !strings.Contains(body, "Unsupported protocol version") {
+		return nil
+	}
+	other := engineIOv3
+	if eio == engineIOv3 {
+		other = engineIOv4
+	}
+	return fmt.Errorf("服务端不支持 Engine.IO v%d，请尝试设置 engineIoVersion 为 %d", eio, other)
+}
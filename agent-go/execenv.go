@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// buildExecEnv 在 base 环境变量上合并 execEnv 配置，同名变量被覆盖
+// 值中的 $VAR / ${VAR} 引用 base 中的原值，可用于扩展而不是替换 (如 "PATH": "/opt/tools/bin:$PATH")
+func buildExecEnv(base []string, extra map[string]string) []string {
+	if len(extra) == 0 {
+		return base
+	}
+
+	lookup := func(name string) string {
+		for i := len(base) - 1; i >= 0; i-- {
+			if key, value, ok := strings.Cut(base[i], "="); ok && envKeyEqual(key, name) {
+				return value
+			}
+		}
+		return ""
+	}
+
+	// 按变量名排序，保证每次生成的环境一致
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(base)+len(keys))
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if !envKeyOverridden(key, keys) {
+			env = append(env, kv)
+		}
+	}
+	for _, key := range keys {
+		env = append(env, key+"="+os.Expand(extra[key], lookup))
+	}
+	return env
+}
+
+// envKeyOverridden key 是否被 execEnv 中的变量覆盖
+func envKeyOverridden(key string, keys []string) bool {
+	for _, k := range keys {
+		if envKeyEqual(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// envKeyEqual 比较环境变量名 (Windows 下不区分大小写)
+func envKeyEqual(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,42 @@
+package main
+
+// serverURLList 按优先级返回 Dashboard 地址列表
+// 配置了 serverUrls 时使用该列表，否则只有 serverUrl (兼容单服务器配置)
+func serverURLList(config *Config) []string {
+	if len(config.ServerURLs) > 0 {
+		return config.ServerURLs
+	}
+	return []string{config.ServerURL}
+}
+
+// currentServerURL 当前连接 (或正在尝试) 的 Dashboard 地址
+func (a *AgentClient) currentServerURL() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	urls := serverURLList(a.cfg())
+	return urls[a.serverIndex%len(urls)]
+}
+
+// serverFailed 记录一次对当前服务器的连接失败 (连接失败或认证前断开)，
+// 连续失败达到 failoverAttempts 次后切换到下一个地址
+func (a *AgentClient) serverFailed() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	urls := serverURLList(a.cfg())
+	if len(urls) < 2 {
+		return
+	}
+	a.serverFailures++
+	limit := a.cfg().FailoverAttempts
+	if limit <= 0 {
+		limit = 1
+	}
+	if a.serverFailures < limit {
+		return
+	}
+
+	a.serverFailures = 0
+	a.serverIndex = (a.serverIndex + 1) % len(urls)
+	logger.Warnf("[Agent] 连续 %d 次连接失败，切换到备用服务器: %s", limit, urls[a.serverIndex])
+}
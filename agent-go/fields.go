@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// 紧急上报标记不受字段白名单限制 (服务端依赖它们区分紧急状态)
+var alwaysReportedFields = []string{"urgent", "urgent_reasons"}
+
+// filterFields 按 JSON 字段名白名单裁剪上报内容，fields 为空时原样返回
+func filterFields(v interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return v
+	}
+
+	filtered := make(map[string]json.RawMessage, len(fields)+len(alwaysReportedFields))
+	for _, list := range [][]string{fields, alwaysReportedFields} {
+		for _, name := range list {
+			if raw, ok := all[name]; ok {
+				filtered[name] = raw
+			}
+		}
+	}
+	return filtered
+}
+
+// jsonFieldNames 返回结构体所有字段的 JSON 名称 (用于校验白名单)
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// unknownFields 返回白名单中不存在的字段名
+func unknownFields(fields []string, t reflect.Type) []string {
+	known := jsonFieldNames(t)
+	var unknown []string
+	for _, name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
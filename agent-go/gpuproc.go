@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// GPUProcess 占用 GPU 的进程 (目前只支持 NVIDIA)
+type GPUProcess struct {
+	PID        int32  `json:"pid"`
+	Name       string `json:"name"`
+	GPUMemUsed uint64 `json:"gpu_mem_used"` // bytes (Windows WDDM 模式下无法获取，为 0)
+}
+
+// collectGPUProcesses 通过 nvidia-smi 查询计算进程，进程名优先使用本机进程表中的名称
+// 没有 nvidia-smi 或查询失败时返回 nil，没有进程时返回空列表
+func (c *Collector) collectGPUProcesses() []GPUProcess {
+	nvidiaSmi := c.getNvidiaSmiPath()
+	if nvidiaSmi == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, nvidiaSmi, "--query-compute-apps=pid,process_name,used_memory", "--format=csv,noheader,nounits")
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	procs := parseGPUComputeApps(string(output))
+	for i := range procs {
+		if p, err := process.NewProcess(procs[i].PID); err == nil {
+			if name, err := p.Name(); err == nil && name != "" {
+				procs[i].Name = name
+			}
+		}
+	}
+	return procs
+}
+
+// parseGPUComputeApps 解析 nvidia-smi --query-compute-apps 的 CSV 输出
+// "No running processes found" 等非数据行会被跳过，显存为 [N/A] 时按 0 处理
+func parseGPUComputeApps(output string) []GPUProcess {
+	procs := []GPUProcess{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.Split(line, ",")
+		if len(parts) < 3 {
+			continue
+		}
+		pid, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 32)
+		if err != nil {
+			continue
+		}
+		// 进程名本身可能包含逗号，显存固定在最后一列
+		name := strings.TrimSpace(strings.Join(parts[1:len(parts)-1], ","))
+		used, _ := strconv.ParseUint(strings.TrimSpace(parts[len(parts)-1]), 10, 64)
+		procs = append(procs, GPUProcess{
+			PID:        int32(pid),
+			Name:       filepath.Base(strings.ReplaceAll(name, "\\", "/")),
+			GPUMemUsed: used * 1024 * 1024, // MiB 转为 Bytes
+		})
+	}
+	return procs
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP 检查的响应体处理
+const (
+	httpCheckSnippetSize = 512              // 结果中保留的响应体开头字节数
+	httpCheckMaxBodySize = 10 * 1024 * 1024 // 最多读取的响应体大小，超出部分不计入 body_size
+	httpCheckMaxRedirect = 10
+)
+
+// HTTPCheckRequest HTTP 检查任务参数
+type HTTPCheckRequest struct {
+	URL             string `json:"url"`
+	ExpectStatus    int    `json:"expect_status"`    // 期望的状态码，0 表示任意 2xx/3xx
+	FollowRedirects bool   `json:"follow_redirects"` // 是否跟随重定向 (默认不跟随，直接返回 3xx)
+}
+
+// HTTPCheckResult HTTP 检查结果
+type HTTPCheckResult struct {
+	URL          string  `json:"url"`
+	StatusCode   int     `json:"status_code"`
+	ResponseTime float64 `json:"response_time"` // 毫秒，从发出请求到读完响应体
+	BodySize     int64   `json:"body_size"`
+	Snippet      string  `json:"snippet"`              // 响应体开头部分
+	ErrorType    string  `json:"error_type,omitempty"` // dns / tls / timeout / connect / status
+	Error        string  `json:"error,omitempty"`
+}
+
+// runHTTPCheck 对目标 URL 发起一次 GET 请求 (超时由 ctx 控制，经过配置的代理)
+// 只有参数无效时返回 error，请求失败记录在结果的 ErrorType/Error 中
+func runHTTPCheck(ctx context.Context, config *Config, data string) (*HTTPCheckResult, error) {
+	var req HTTPCheckRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		// 兼容直接传 URL
+		req.URL = strings.TrimSpace(data)
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的 URL: %q", req.URL)
+	}
+
+	transport := &http.Transport{
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   true,
+	}
+	if err := applyTransportProxy(config, transport); err != nil {
+		return nil, fmt.Errorf("代理配置无效: %v", err)
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if !req.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= httpCheckMaxRedirect {
+				return fmt.Errorf("重定向次数超过 %d", httpCheckMaxRedirect)
+			}
+			return nil
+		},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 URL: %v", err)
+	}
+	httpReq.Header.Set("User-Agent", "API-Monitor-Agent/"+VERSION)
+
+	result := &HTTPCheckResult{URL: req.URL}
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		result.ResponseTime = float64(time.Since(start).Microseconds()) / 1000
+		result.ErrorType = classifyHTTPError(ctx, err)
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	snippet := make([]byte, httpCheckSnippetSize)
+	n, _ := io.ReadFull(resp.Body, snippet)
+	rest, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, httpCheckMaxBodySize-int64(n)))
+	result.ResponseTime = float64(time.Since(start).Microseconds()) / 1000
+	result.StatusCode = resp.StatusCode
+	result.BodySize = int64(n) + rest
+	result.Snippet = strings.ToValidUTF8(string(snippet[:n]), "")
+
+	if !httpStatusOK(resp.StatusCode, req.ExpectStatus) {
+		result.ErrorType = "status"
+		if req.ExpectStatus > 0 {
+			result.Error = fmt.Sprintf("状态码 %d，期望 %d", resp.StatusCode, req.ExpectStatus)
+		} else {
+			result.Error = fmt.Sprintf("状态码 %d", resp.StatusCode)
+		}
+	}
+	return result, nil
+}
+
+// httpStatusOK 未指定期望状态码时 2xx/3xx 视为成功
+func httpStatusOK(status, expect int) bool {
+	if expect > 0 {
+		return status == expect
+	}
+	return status >= 200 && status < 400
+}
+
+// classifyHTTPError 将请求错误归类，便于面板区分证书问题、DNS 故障和网络不通
+func classifyHTTPError(ctx context.Context, err error) string {
+	var dnsErr *net.DNSError
+	var unknownAuthority x509.UnknownAuthorityError
+	var certInvalid x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &unknownAuthority), errors.As(err, &certInvalid), errors.As(err, &hostnameErr),
+		errors.As(err, &recordErr), errors.As(err, &verifyErr), strings.Contains(err.Error(), "tls:"):
+		return "tls"
+	case ctx.Err() == context.DeadlineExceeded, errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "connect"
+	}
+}
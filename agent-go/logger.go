@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[LogLevel]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// parseLogLevel 解析配置中的日志级别，无法识别时返回 info
+func parseLogLevel(s string) LogLevel {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level
+		}
+	}
+	return LevelInfo
+}
+
+// Logger 分级日志，输出到标准库 log 的输出目标 (控制台 + agent.log)
+// text 格式沿用 log 的时间前缀，json 格式每行一个 JSON 对象，便于采集到集中日志系统
+type Logger struct {
+	mu     sync.Mutex
+	level  LogLevel
+	asJSON bool
+}
+
+// logger 全局日志实例
+var logger = &Logger{level: LevelInfo}
+
+// Configure 按配置设置日志级别和格式 (debug 为 true 时强制 debug 级别)
+func (l *Logger) Configure(config *Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.level = parseLogLevel(config.LogLevel)
+	if config.Debug {
+		l.level = LevelDebug
+	}
+	l.asJSON = strings.EqualFold(config.LogFormat, "json")
+}
+
+// Enabled 判断该级别的日志是否会输出
+func (l *Logger) Enabled(level LogLevel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level >= l.level
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) { l.output(LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...interface{})  { l.output(LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...interface{})  { l.output(LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...interface{}) { l.output(LevelError, format, args...) }
+
+// Fatalf 输出错误日志后退出
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.output(LevelError, format, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) output(level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+
+	l.mu.Lock()
+	asJSON := l.asJSON
+	l.mu.Unlock()
+
+	if !asJSON {
+		log.Printf("%-5s %s", strings.ToUpper(levelNames[level]), msg)
+		return
+	}
+
+	// 将 "[Agent] xxx" 形式的前缀拆分为 component 字段
+	entry := map[string]string{
+		"time":  time.Now().Format(time.RFC3339),
+		"level": levelNames[level],
+		"msg":   msg,
+	}
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "]"); end > 0 {
+			entry["component"] = msg[1:end]
+			entry["msg"] = strings.TrimSpace(msg[end+1:])
+		}
+	}
+	data, _ := json.Marshal(entry)
+	log.Writer().Write(append(data, '\n'))
+}
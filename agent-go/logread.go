@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 读取日志任务的限制
+const (
+	defaultLogReadLines = 100
+	maxLogReadLines     = 5000
+	maxLogReadBytes     = 512 << 10 // 只读取文件末尾 512KB (转义后仍需放进单条事件)
+)
+
+// LogReadRequest 读取日志任务的参数 (path 留空时读取 Agent 自身的日志)
+type LogReadRequest struct {
+	Path  string `json:"path"`
+	Lines int    `json:"lines"`
+}
+
+// logReadAllowlist 允许读取的文件 (支持通配符)，未配置时只允许 Agent 自身的日志
+func logReadAllowlist(config *Config) []string {
+	if len(config.LogReadAllowlist) > 0 {
+		return config.LogReadAllowlist
+	}
+	return []string{defaultLogPath()}
+}
+
+// isLogReadAllowed 判断文件是否在白名单中 (path 应为解析符号链接后的绝对路径)
+func isLogReadAllowed(path string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		pattern = filepath.Clean(pattern)
+		// 白名单中的具体文件同样解析符号链接，与请求路径使用相同的形式比较
+		if !strings.ContainsAny(pattern, "*?[") {
+			if resolved, err := filepath.EvalSymlinks(pattern); err == nil {
+				pattern = resolved
+			}
+		}
+		candidate := path
+		if runtime.GOOS == "windows" {
+			pattern, candidate = strings.ToLower(pattern), strings.ToLower(candidate)
+		}
+		if ok, _ := filepath.Match(pattern, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handleLogRead 读取白名单内文件的最后 N 行
+func (a *AgentClient) handleLogRead(data string) (string, error) {
+	var req LogReadRequest
+	if strings.TrimSpace(data) != "" {
+		if err := json.Unmarshal([]byte(data), &req); err != nil {
+			return "", fmt.Errorf("无效的参数: %v", err)
+		}
+	}
+	if req.Path == "" {
+		req.Path = defaultLogPath()
+	}
+	if req.Lines <= 0 {
+		req.Lines = defaultLogReadLines
+	}
+	if req.Lines > maxLogReadLines {
+		req.Lines = maxLogReadLines
+	}
+
+	// 解析符号链接和 ..，避免通过白名单目录中的链接读取其他文件
+	path, err := filepath.Abs(req.Path)
+	if err != nil {
+		return "", fmt.Errorf("无效的路径: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else {
+		return "", fmt.Errorf("无法读取 %s: %v", req.Path, err)
+	}
+	if !isLogReadAllowed(path, logReadAllowlist(a.cfg())) {
+		return "", fmt.Errorf("文件不在 logReadAllowlist 中: %s", req.Path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("无法读取 %s: %v", req.Path, err)
+	}
+	defer f.Close()
+
+	logger.Infof("[Agent] 读取日志: %s (最后 %d 行)", path, req.Lines)
+	return tailLines(f, req.Lines, maxLogReadBytes)
+}
+
+// tailLines 返回文件末尾最多 n 行，只读取最后 maxBytes 字节
+func tailLines(f *os.File, n int, maxBytes int64) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s 是目录", f.Name())
+	}
+
+	offset := info.Size() - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes))
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	// 从文件中间开始读取时第一行不完整，丢弃
+	if offset > 0 && len(lines) > 1 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	// 控制字符较多的日志转义后可能膨胀数倍，丢弃最早的行直到结果放得进单条事件
+	output := strings.Join(lines, "\n")
+	for !taskDataFits(output) && len(lines) > 1 {
+		lines = lines[len(lines)/2:]
+		output = strings.Join(lines, "\n")
+	}
+	if !taskDataFits(output) {
+		return "", fmt.Errorf("日志行过长，无法在单条消息中返回")
+	}
+	return output, nil
+}
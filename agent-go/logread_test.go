@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTailLinesFitsEvent 转义后膨胀的日志也会被截断到单条事件大小以内
+func TestTailLinesFitsEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	// 每行 100 个双引号，JSON 转义后大小翻倍
+	line := strings.Repeat(`"`, 100)
+	var b strings.Builder
+	for b.Len() < 2*maxLogReadBytes {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("last line\n")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	output, err := tailLines(f, maxLogReadLines, maxLogReadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !taskDataFits(output) {
+		t.Errorf("结果转义后超出单条事件限制")
+	}
+	if !strings.HasSuffix(output, "last line") {
+		t.Errorf("截断时丢弃了最新的行")
+	}
+
+	a, conn := newAckTestClient(false)
+	a.sendTaskResult(map[string]interface{}{"id": "log", "successful": true, "data": output})
+	if len(conn.frames) != 1 || len(conn.frames[0]) > maxEventPayloadSize {
+		t.Errorf("任务结果帧超出限制")
+	}
+}
+
+// TestSendTaskResultOversized 超出大小限制的结果改为上报错误，而不是发送会被服务端断开的帧
+func TestSendTaskResultOversized(t *testing.T) {
+	a, conn := newAckTestClient(false)
+	a.sendTaskResult(map[string]interface{}{"id": "big", "successful": true, "data": strings.Repeat("x", maxEventPayloadSize)})
+	if len(conn.frames) != 1 {
+		t.Fatalf("发送了 %d 帧", len(conn.frames))
+	}
+	frame := conn.frames[0]
+	if len(frame) > maxEventPayloadSize || !strings.Contains(frame, "任务结果过大") || !strings.Contains(frame, `"successful":false`) {
+		t.Errorf("frame = %.200s", frame)
+	}
+}
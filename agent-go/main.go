@@ -1,40 +1,61 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-const VERSION = "0.1.2"
+// 构建信息 (通过 -ldflags "-X main.VERSION=... -X main.GitCommit=... -X main.BuildTime=..." 注入)
+var (
+	VERSION   = "0.1.2"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
 
 // Agent 事件类型 (与服务端 protocol.js 保持一致)
 const (
-	EventAgentConnect    = "agent:connect"
-	EventAgentHostInfo   = "agent:host_info"
-	EventAgentState      = "agent:state"
-	EventAgentTaskResult = "agent:task_result"
-	EventDashboardAuthOK = "dashboard:auth_ok"
-	EventDashboardAuthFail = "dashboard:auth_fail"
-	EventDashboardTask   = "dashboard:task"
-	EventDashboardPtyInput = "dashboard:pty_input"
+	EventAgentConnect       = "agent:connect"
+	EventAgentHostInfo      = "agent:host_info"
+	EventAgentState         = "agent:state"
+	EventAgentTaskResult    = "agent:task_result"
+	EventAgentDisconnect    = "agent:disconnect"
+	EventDashboardAuthOK    = "dashboard:auth_ok"
+	EventDashboardAuthFail  = "dashboard:auth_fail"
+	EventAgentReauth        = "agent:reauth_required"
+	EventDashboardTask      = "dashboard:task"
+	EventDashboardPtyInput  = "dashboard:pty_input"
 	EventDashboardPtyResize = "dashboard:pty_resize"
-	EventAgentPtyData    = "agent:pty_data"
+	EventDashboardPtyClose  = "dashboard:pty_close"
+	EventAgentPtyData       = "agent:pty_data"
+	EventAgentPtyClosed     = "agent:pty_closed"
 )
 
 // Task Types
@@ -42,15 +63,245 @@ const (
 	TaskTypePtyStart = 12
 )
 
+// Socket.IO 心跳默认值 (服务端握手未提供 pingInterval/pingTimeout 时使用)
+const (
+	defaultPingInterval = 10 * time.Second
+	defaultPingTimeout  = 20 * time.Second
+)
+
+// reauthTimeout 收到重新认证要求后等待 auth_ok 的最长时间，超时则断开走完整重连
+const reauthTimeout = 10 * time.Second
+
+// defaultShutdownTimeout 未配置 shutdownTimeout 时关闭 Agent 的最长等待时间
+const defaultShutdownTimeout = 10 * time.Second
+
+// finalFlushTimeout 退出前发送最后一次状态和断开通知的最长等待时间
+const finalFlushTimeout = 2 * time.Second
+
+// wsWriteTimeout 单次 WebSocket 写入的最长时间，半开连接上写入阻塞时及时失败
+const wsWriteTimeout = 10 * time.Second
+
+// defaultTaskTimeout 服务端未指定超时 (timeout 为 0) 时任务的最长执行时间
+const defaultTaskTimeout = 30 * time.Second
+
+// maxEventPayloadSize 单条事件的最大字节数 (Socket.IO 服务端默认 maxHttpBufferSize 为 1MB)
+const maxEventPayloadSize = 1000000
+
+// maxTaskResultDataSize 任务结果 data 字段编码为 JSON 字符串后的最大字节数
+// data 在事件中以字符串形式发送 (引号、反斜杠和控制字符会被转义)，另外为 id/result_id 等字段和 42/agent 前缀预留余量
+const maxTaskResultDataSize = maxEventPayloadSize - 16*1024
+
+// taskDataFits 判断作为任务结果 data 发送的字符串在转义后是否在单条事件大小限制内
+func taskDataFits(data string) bool {
+	encoded, _ := json.Marshal(data)
+	return len(encoded) <= maxTaskResultDataSize
+}
+
 // Config Agent 配置
 type Config struct {
-	ServerURL        string `json:"serverUrl"`
-	ServerID         string `json:"serverId"`
-	AgentKey         string `json:"agentKey"`
-	ReportInterval   int    `json:"reportInterval"`   // 毫秒
-	HostInfoInterval int    `json:"hostInfoInterval"` // 毫秒
-	ReconnectDelay   int    `json:"reconnectDelay"`   // 毫秒
-	Debug            bool   `json:"debug"`
+	ServerURL         string   `json:"serverUrl"`
+	ServerURLs        []string `json:"serverUrls"`       // 多个 Dashboard 地址 (按顺序故障切换)，设置后忽略 serverUrl
+	FailoverAttempts  int      `json:"failoverAttempts"` // 对当前地址连续失败多少次后切换到下一个
+	ServerID          string   `json:"serverId"`
+	AgentKey          string   `json:"agentKey"`
+	Hostname          string   `json:"hostname"`          // 上报的主机名 (留空使用系统主机名，容器中可指定有意义的名称)
+	AuthHMAC          bool     `json:"authHmac"`          // 服务端提供 nonce 时以 HMAC 签名认证，不发送明文密钥
+	ReportInterval    int      `json:"reportInterval"`    // 毫秒
+	HostInfoInterval  int      `json:"hostInfoInterval"`  // 毫秒
+	MinReportInterval int      `json:"minReportInterval"` // 毫秒，reportInterval 的下限 (低于该值时调整为下限，0 为不限制)
+	ReconnectDelay    int      `json:"reconnectDelay"`    // 毫秒 (指数退避的初始值)
+	MaxReconnectDelay int      `json:"maxReconnectDelay"` // 毫秒 (指数退避上限)
+	EngineIOVersion   int      `json:"engineIoVersion"`   // Engine.IO 协议版本: 4 (Socket.IO v3/v4) 或 3 (Socket.IO v2)
+	Transport         string   `json:"transport"`         // auto / websocket / polling (WebSocket 被拦截时使用 HTTP 长轮询)
+	Debug             bool     `json:"debug"`
+	LogLevel          string   `json:"logLevel"`  // debug / info / warn / error
+	LogFormat         string   `json:"logFormat"` // text / json
+
+	EnableMetricQuery bool `json:"enableMetricQuery"` // 允许 Dashboard 按需查询原始指标
+	ProcessListMax    int  `json:"processListMax"`    // 进程表任务最多返回的进程数
+
+	SpeedtestURL       string `json:"speedtestUrl"`       // 测速下载地址 (返回大文件)，留空则使用已安装的 speedtest CLI
+	SpeedtestUploadURL string `json:"speedtestUploadUrl"` // 测速上传地址 (接受 POST)，留空不测上传
+	SpeedtestCooldown  int    `json:"speedtestCooldown"`  // 秒，两次测速的最小间隔
+
+	AllowDockerControl bool `json:"allowDockerControl"` // 允许 Dashboard 启动/停止/重启容器 (默认关闭)
+
+	AllowSelfUpdate bool     `json:"allowSelfUpdate"` // 允许 Dashboard 下发自更新任务替换本程序 (默认关闭)
+	SelfUpdateHosts []string `json:"selfUpdateHosts"` // 自更新允许的下载主机 (留空不限制，但下载地址必须为 https)
+
+	AllowPTY       bool     `json:"allowPty"`       // 允许 Dashboard 打开 Web 终端 (PTY，默认关闭)
+	ShellPath      string   `json:"shellPath"`      // 终端使用的 Shell (留空自动检测)
+	ShellArgs      []string `json:"shellArgs"`      // Shell 启动参数
+	ShellWorkDir   string   `json:"shellWorkDir"`   // 终端工作目录
+	PTYCommand     string   `json:"ptyCommand"`     // 受限终端: 只运行该命令 (如 "htop")，不提供交互式 Shell
+	PTYRecordDir   string   `json:"ptyRecordDir"`   // 终端会话录像目录 (asciicast 格式，留空不录制)
+	PTYRecordInput bool     `json:"ptyRecordInput"` // 录像同时记录键盘输入 (可能包含密码)
+	PTYIdleTimeout int      `json:"ptyIdleTimeout"` // 秒，终端无输入输出超过该时长自动关闭 (0 为不限制)
+
+	AllowExec     bool     `json:"allowExec"`     // 允许 Dashboard 下发命令执行任务 (默认关闭)
+	ExecAllowlist []string `json:"execAllowlist"` // 允许执行的程序名 (非空时不经过 shell 直接执行)
+	ExecMaxOutput int      `json:"execMaxOutput"` // 命令输出的最大字节数，超出部分截断
+
+	LogReadAllowlist []string `json:"logReadAllowlist"` // 读取日志任务允许读取的文件 (支持通配符)，留空只允许 agent.log
+
+	ExecEnv map[string]string `json:"execEnv"` // 合并到终端和命令执行任务环境中的变量，值中可用 $VAR 引用 Agent 自身的环境变量
+
+	LogErrorWatch []string `json:"logErrorWatch"` // 需要统计日志错误行的容器 (名称或 ID)
+	LogErrorTail  int      `json:"logErrorTail"`  // 每次采样的日志行数 (默认 200，最大 1000)
+	DockerStats   bool     `json:"dockerStats"`   // 采集每个容器的 CPU/内存占用 (docker stats 较慢，默认关闭)
+
+	DockerMaxContainers int `json:"dockerMaxContainers"` // 最多上报的容器数 (优先运行中的容器，0 为不限制)
+
+	UrgentConditions []string `json:"urgentConditions"` // 触发立即上报的条件: fs_readonly, process_down, raid_degraded
+	WatchProcesses   []string `json:"watchProcesses"`   // process_down 监视的进程名
+
+	NetInterfaceExclude  []string `json:"netInterfaceExclude"`  // 不单独上报的网卡 (支持通配符，如 lo, docker0, veth*)
+	IncludeAllInterfaces bool     `json:"includeAllInterfaces"` // 主机信息中同时列出未启用的网卡和回环网卡
+	ListenPorts          []uint32 `json:"listenPorts"`          // 已知的服务端口，用于区分入站/出站连接
+
+	TopProcessCount int `json:"topProcessCount"` // 上报 CPU 占用最高的进程数量 (0 为不采集)
+
+	// 上报字段白名单 (JSON 字段名，如 cpu、mem_used)，为空时上报全部字段
+	StateFields    []string `json:"stateFields"`
+	HostInfoFields []string `json:"hostInfoFields"`
+
+	// 采集开关 (默认全部开启，小内存 VPS 可关闭开销较大的采集项)
+	EnableGPU       bool `json:"enableGpu"`       // GPU 型号/使用率 (nvidia-smi、rocm-smi、PowerShell)，默认 true
+	GPUInterval     int  `json:"gpuInterval"`     // 毫秒，GPU 采样间隔 (不低于 reportInterval)，默认 5000
+	EnableDocker    bool `json:"enableDocker"`    // 容器列表 (docker/podman ps)，默认 true
+	EnablePublicIP  bool `json:"enablePublicIp"`  // 公网 IP 查询 (HTTP 请求)，默认 true
+	EnableGeoIP     bool `json:"enableGeoIp"`     // 根据公网 IP 查询国家代码 (IP 变化时才查询，会把公网 IP 发送给第三方服务)，默认 false
+	EnableConnCount bool `json:"enableConnCount"` // TCP/UDP 连接统计 (遍历所有连接)，默认 true
+	EnableSMART     bool `json:"enableSmart"`     // 磁盘 SMART 健康状态 (smartctl，需要 root)，默认 false
+
+	NTPServer    string `json:"ntpServer"`    // 检查时钟偏差的 NTP 服务器 (host 或 host:port)，留空不检查
+	DNSProbeHost string `json:"dnsProbeHost"` // 探测 DNS 解析延迟的域名，留空不探测
+	DNSResolver  string `json:"dnsResolver"`  // DNS 查询任务使用的服务器 (ip 或 ip:port)，留空使用系统解析器
+
+	DiskExcludeFsTypes []string `json:"diskExcludeFsTypes"` // 不计入磁盘总量的文件系统类型
+	DiskExcludeMounts  []string `json:"diskExcludeMounts"`  // 不计入磁盘总量的挂载点 (支持通配符)
+
+	ClientCertFile string `json:"clientCertFile"` // mTLS 客户端证书 (PEM)
+	ClientKeyFile  string `json:"clientKeyFile"`  // mTLS 客户端私钥 (PEM，留空则从证书文件读取)
+	CACertFile     string `json:"caCertFile"`     // 自定义 CA 证书 (PEM)
+	TLSSkipVerify  bool   `json:"tlsSkipVerify"`  // 跳过服务端证书校验 (仅限测试环境)
+	ProxyURL       string `json:"proxyUrl"`       // 出站代理 (http://、socks5://)，留空则读取 HTTP_PROXY/HTTPS_PROXY
+
+	PublicIPEndpoints   []string `json:"publicIpEndpoints"`   // 公网 IPv4 查询地址 (返回纯文本 IP)
+	PublicIPv6Endpoints []string `json:"publicIpv6Endpoints"` // 公网 IPv6 查询地址
+	GeoIPEndpoint       string   `json:"geoIpEndpoint"`       // IP 归属地查询地址 ({ip} 替换为公网 IP，返回 JSON)
+
+	EventBufferSize   int `json:"eventBufferSize"`   // 断线期间缓存的待补发事件数 (0 为不缓存)
+	StateBufferMaxAge int `json:"stateBufferMaxAge"` // 毫秒，超过该时长的状态采样不再补发
+
+	Alerts []AlertRule `json:"alerts"` // 本地阈值告警，越过/恢复阈值时 POST 到各规则的 webhook
+
+	ShutdownTimeout int `json:"shutdownTimeout"` // 毫秒，收到退出信号后等待关闭完成的最长时间
+
+	NoReport    bool `json:"noReport"`    // 只连接和认证，不上报数据 (用于验证密钥和网络，对应 --no-report)
+	Compression bool `json:"compression"` // 协商 WebSocket permessage-deflate 压缩 (需服务端开启 perMessageDeflate)
+
+	StatusAddr        string `json:"statusAddr"`        // 本地状态接口监听地址 (如 127.0.0.1:9090)，留空不启动
+	PrometheusEnabled bool   `json:"prometheusEnabled"` // 在状态接口上提供 /metrics (Prometheus 文本格式)
+
+	PidFile string `json:"pidFile"` // PID 文件路径，已有实例运行时拒绝启动，留空不启用
+
+	Tags map[string]string `json:"tags"` // 主机标签 (如 role、region、env)，随主机信息上报，供面板分组筛选
+}
+
+// newDefaultConfig 返回带默认值的配置 (配置文件、环境变量和命令行参数在此基础上覆盖)
+func newDefaultConfig() *Config {
+	return &Config{
+		ServerURL:           "http://localhost:3000",
+		LogLevel:            "info",
+		LogFormat:           "text",
+		ReportInterval:      1500,
+		HostInfoInterval:    600000,
+		MinReportInterval:   defaultMinReportInterval,
+		ReconnectDelay:      4000,
+		MaxReconnectDelay:   60000,
+		EngineIOVersion:     engineIOv4,
+		Transport:           transportAuto,
+		FailoverAttempts:    3,
+		ShutdownTimeout:     10000,
+		AuthHMAC:            true,
+		TopProcessCount:     5,
+		ProcessListMax:      defaultProcessListMax,
+		SpeedtestCooldown:   defaultSpeedtestCooldown,
+		EnableGPU:           true,
+		GPUInterval:         5000,
+		EnableDocker:        true,
+		DockerMaxContainers: 50,
+		EnablePublicIP:      true,
+		EnableConnCount:     true,
+		NTPServer:           "pool.ntp.org",
+		DNSProbeHost:        "google.com",
+		ExecMaxOutput:       65536,
+		EventBufferSize:     50,
+		StateBufferMaxAge:   30000,
+		DiskExcludeFsTypes:  []string{"tmpfs", "overlay", "squashfs", "devtmpfs"},
+	}
+}
+
+// defaultConfigPath 默认配置文件路径 (可执行文件所在目录下的 config.json)
+func defaultConfigPath() string {
+	exePath, _ := os.Executable()
+	return filepath.Join(filepath.Dir(exePath), "config.json")
+}
+
+// defaultLogPath Agent 日志文件 (程序所在目录的 agent.log)
+func defaultLogPath() string {
+	exePath, _ := os.Executable()
+	return filepath.Join(filepath.Dir(exePath), "agent.log")
+}
+
+// loadConfigFile 读取配置文件并覆盖到 config 上
+func loadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// tagFlags 可重复的 --tag key=value 参数
+type tagFlags map[string]string
+
+func (t tagFlags) String() string {
+	pairs := make([]string, 0, len(t))
+	for k, v := range t {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (t tagFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("标签格式应为 key=value: %q", value)
+	}
+	t[key] = strings.TrimSpace(val)
+	return nil
+}
+
+// configPathFromArgs 从参数中取出 -c/--config 指定的配置文件路径 (服务模式下 flag 尚未解析时使用)
+func configPathFromArgs(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "-c" || arg == "-config" || arg == "--config":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		case strings.HasPrefix(arg, "-c="), strings.HasPrefix(arg, "-config="), strings.HasPrefix(arg, "--config="):
+			return arg[strings.Index(arg, "=")+1:]
+		}
+	}
+	return ""
 }
 
 // SocketIOMessage Socket.IO 消息格式
@@ -63,16 +314,48 @@ type SocketIOMessage struct {
 
 // AgentClient Agent 客户端
 type AgentClient struct {
-	config        *Config
-	conn          *websocket.Conn
-	authenticated bool
-	collector     *Collector
-	stopChan      chan struct{}
-	mu            sync.Mutex
-	reconnecting  bool
-	ptySessions   map[string]IPty      // taskId -> IPty
-	taskProgress  map[string]*TaskProgress // taskId -> 进度
-	progressMu    sync.RWMutex
+	config           *atomic.Pointer[Config] // 当前配置 (与 collector 共用，重新加载时整体替换)
+	conn             agentConn
+	authenticated    bool
+	collector        *Collector
+	stopChan         chan struct{}
+	stopping         bool // 已开始关闭 (重复的关闭请求直接返回，不能再次 close(stopChan))
+	mu               sync.Mutex
+	reconnecting     bool
+	ptySessions      map[string]IPty          // taskId -> IPty
+	ptyActivity      map[string]time.Time     // taskId -> 最近一次输入/输出时间
+	ptyCloseReasons  map[string]string        // taskId -> 主动关闭的原因
+	taskProgress     map[string]*TaskProgress // taskId -> 进度
+	progressMu       sync.RWMutex
+	tlsConfig        *tls.Config                  // 客户端证书/自定义 CA，证书变更时热更新
+	reconnectAttempt int                          // 连续重连次数 (认证成功后清零)
+	retryAfter       time.Duration                // 服务端要求的下一次重连等待时间 (只生效一次)
+	connStats        connectionStats              // 重连次数与最近一次断开原因
+	dropReason       string                       // 当前连接的断开原因 (连接断开后写入 connStats)
+	pingInterval     time.Duration                // 服务端握手下发的心跳间隔
+	pingTimeout      time.Duration                // 服务端握手下发的心跳超时
+	lastPingTime     time.Time                    // 最近一次收到服务端 ping 的时间
+	pendingEvents    *eventBuffer                 // 断线期间发送失败的事件，认证成功后补发
+	reporting        bool                         // reportLoop 是否在运行 (重新认证时避免重复启动)
+	serverIndex      int                          // 当前使用的 Dashboard 地址 (serverUrls 中的下标)
+	authNonce        string                       // 服务端下发的认证 nonce (为空时发送明文密钥)
+	speedtestRunning bool                         // 是否有测速正在进行
+	lastSpeedtest    time.Time                    // 最近一次测速的开始时间 (冷却计时)
+	compression      *compressionStats            // 当前连接的压缩统计 (未开启压缩时为 nil)
+	serverFailures   int                          // 对当前地址的连续失败次数 (认证成功后清零)
+	ackSeq           int                          // 最近分配的 Socket.IO ack ID
+	ackWaiters       map[int]chan json.RawMessage // ack ID -> 等待确认的通道
+	taskResultAck    bool                         // 服务端在 auth_ok 中声明会确认任务结果 (未确认时才重发)
+
+	// 本地状态接口
+	statusServer  *http.Server
+	startedAt     time.Time
+	lastHostInfo  *HostInfo // 最近一次采集的主机信息
+	lastState     *State    // 最近一次采集的实时状态
+	lastStateTime time.Time
+
+	reloadChan chan struct{} // 配置重新加载后通知 reportLoop 重置定时器
+	pidFile    *pidFile      // 单实例锁，Stop 时释放
 }
 
 // TaskProgress 任务进度
@@ -86,12 +369,6 @@ type TaskProgress struct {
 	IsError    bool   `json:"is_error"`   // 是否出错
 }
 
-// IPty PTY 接口实现抽象
-type IPty interface {
-	io.ReadWriteCloser
-	Resize(cols, rows uint32) error
-}
-
 type PTYResizeData struct {
 	Cols uint32 `json:"cols"`
 	Rows uint32 `json:"rows"`
@@ -99,12 +376,18 @@ type PTYResizeData struct {
 
 // NewAgentClient 创建新的 Agent 客户端
 func NewAgentClient(config *Config) *AgentClient {
+	collector := NewCollector(config)
 	return &AgentClient{
-		config:       config,
-		collector:    NewCollector(),
-		stopChan:     make(chan struct{}),
-		ptySessions:  make(map[string]IPty),
-		taskProgress: make(map[string]*TaskProgress),
+		config:          collector.config,
+		collector:       collector,
+		stopChan:        make(chan struct{}),
+		ptySessions:     make(map[string]IPty),
+		ptyActivity:     make(map[string]time.Time),
+		ptyCloseReasons: make(map[string]string),
+		taskProgress:    make(map[string]*TaskProgress),
+		pendingEvents:   newEventBuffer(config.EventBufferSize),
+		ackWaiters:      make(map[int]chan json.RawMessage),
+		reloadChan:      make(chan struct{}, 1),
 	}
 }
 
@@ -113,17 +396,66 @@ func (a *AgentClient) Start() {
 	fmt.Println("═══════════════════════════════════════════════")
 	fmt.Printf("  API Monitor Agent v%s (Go)\n", VERSION)
 	fmt.Println("═══════════════════════════════════════════════")
-	fmt.Printf("  Server:   %s\n", a.config.ServerURL)
-	fmt.Printf("  ServerID: %s\n", a.config.ServerID)
-	fmt.Printf("  Interval: %dms\n", a.config.ReportInterval)
+	fmt.Printf("  Server:   %s\n", strings.Join(serverURLList(a.cfg()), ", "))
+	fmt.Printf("  ServerID: %s\n", a.cfg().ServerID)
+	fmt.Printf("  Interval: %dms\n", a.cfg().ReportInterval)
 	fmt.Println("═══════════════════════════════════════════════")
 
+	if a.cfg().TLSSkipVerify {
+		logger.Warnf("[TLS] ⚠️ ════════════════════════════════════════════")
+		logger.Warnf("[TLS] ⚠️ 已关闭服务端证书校验 (tlsSkipVerify)，连接可能被中间人劫持！")
+		logger.Warnf("[TLS] ⚠️ 请仅在测试环境中使用")
+		logger.Warnf("[TLS] ⚠️ ════════════════════════════════════════════")
+	}
+
+	// 单实例检查: 同一 serverId 运行两个 Agent 会导致面板状态来回跳动
+	if a.cfg().PidFile != "" {
+		pf, err := acquirePidFile(a.cfg().PidFile)
+		if err != nil {
+			logger.Fatalf("[Agent] %v", err)
+		}
+		a.pidFile = pf
+	}
+
+	// 清理上次自更新留下的旧版本
+	cleanupOldExecutable()
+
+	// 本地状态接口 (预热和连接期间 /healthz 返回 503)
+	a.startedAt = time.Now()
+	a.startStatusServer()
+
+	// 本地阈值告警 (不依赖与 Dashboard 的连接)
+	if len(a.cfg().Alerts) > 0 {
+		go a.alertLoop()
+	}
+
+	// 终端空闲超时检查
+	if a.cfg().PTYIdleTimeout > 0 {
+		go a.watchIdlePTYs()
+	}
+
+	// 加载 TLS 证书，并监视证书文件变更
+	// validateConfig 已经校验过证书，这里失败说明文件在启动期间被改动，拒绝不带证书连接
+	if tlsConfig, err := loadTLSConfig(a.cfg()); err != nil {
+		logger.Fatalf("[TLS] %v", err)
+	} else if tlsConfig != nil {
+		a.tlsConfig = tlsConfig
+		go a.watchCertificates()
+	}
+
+	// 只验证连接和认证时不需要预热采集
+	if a.cfg().NoReport {
+		logger.Infof("[Agent] 已开启 --no-report: 只建立连接和认证，不上报主机信息和实时状态")
+		a.connect()
+		return
+	}
+
 	// 预热数据采集 (同步等待完成，确保 GPU 信息已获取)
-	log.Println("[Agent] 正在预热数据采集...")
-	
+	logger.Infof("[Agent] 正在预热数据采集...")
+
 	// 第一次采集：建立 CPU 使用率基准
 	a.collector.CollectState()
-	
+
 	// 等待 1 秒，让 CPU 采集有足够的时间间隔
 	time.Sleep(1 * time.Second)
 	
@@ -132,13 +464,16 @@ func (a *AgentClient) Start() {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		a.collector.CollectHostInfo()
-		log.Println("[Agent] ✓ 主机信息预热完成")
+		hostInfo := a.collector.CollectHostInfo()
+		a.mu.Lock()
+		a.lastHostInfo = hostInfo
+		a.mu.Unlock()
+		logger.Infof("[Agent] ✓ 主机信息预热完成")
 	}()
 	go func() {
 		defer wg.Done()
 		a.collector.CollectState() // 第二次采集，此时 CPU 数据应该准确
-		log.Println("[Agent] ✓ 实时状态预热完成")
+		logger.Infof("[Agent] ✓ 实时状态预热完成")
 	}()
 	wg.Wait() // 等待预热完成
 
@@ -148,6 +483,7 @@ func (a *AgentClient) Start() {
 
 // connect 连接到服务器
 func (a *AgentClient) connect() {
+	first := true
 	for {
 		select {
 		case <-a.stopChan:
@@ -155,30 +491,98 @@ func (a *AgentClient) connect() {
 		default:
 		}
 
+		if !first {
+			a.mu.Lock()
+			a.connStats.ReconnectCount++
+			a.mu.Unlock()
+		}
+		first = false
+
 		err := a.dial()
 		if err != nil {
-			log.Printf("[Agent] 连接失败: %v", err)
-			time.Sleep(time.Duration(a.config.ReconnectDelay) * time.Millisecond)
+			logger.Warnf("[Agent] 连接失败: %v", err)
+			a.serverFailed()
+			a.waitReconnect()
 			continue
 		}
 
 		// 连接成功，开始消息循环
+		a.mu.Lock()
+		a.connStats.LastConnectedAt = time.Now()
+		a.dropReason = ""
+		a.mu.Unlock()
 		a.messageLoop()
 
-		// 连接断开，等待重连
+		// 连接断开，等待重连 (认证成功过的连接先重试同一服务器)
 		a.mu.Lock()
 		a.authenticated = false
+		if a.dropReason != "" {
+			a.connStats.LastDisconnectReason = a.dropReason
+		}
 		a.mu.Unlock()
+		a.serverFailed()
 
-		log.Println("[Agent] 连接断开，准备重连...")
-		time.Sleep(time.Duration(a.config.ReconnectDelay) * time.Millisecond)
+		logger.Infof("[Agent] 连接断开，准备重连...")
+		a.waitReconnect()
 	}
 }
 
-// dial 建立 WebSocket 连接
-func (a *AgentClient) dial() error {
+// waitReconnect 按指数退避等待下一次重连 (收到停止信号时立即返回)
+func (a *AgentClient) waitReconnect() {
+	a.mu.Lock()
+	attempt := a.reconnectAttempt
+	a.reconnectAttempt++
+	retryAfter := a.retryAfter
+	a.retryAfter = 0
+	a.mu.Unlock()
+
+	delay := reconnectBackoff(
+		time.Duration(a.cfg().ReconnectDelay)*time.Millisecond,
+		time.Duration(a.cfg().MaxReconnectDelay)*time.Millisecond,
+		attempt,
+		rand.Float64(),
+	)
+	if retryAfter > 0 {
+		// 服务端过载时要求的等待时间优先于本地退避 (只对这一次重连生效)
+		delay = retryAfter
+		logger.Infof("[Agent] 服务端要求 %.1f 秒后重连 (第 %d 次)", delay.Seconds(), attempt+1)
+	} else {
+		logger.Infof("[Agent] %.1f 秒后重连 (第 %d 次)", delay.Seconds(), attempt+1)
+	}
+
+	select {
+	case <-a.stopChan:
+	case <-time.After(delay):
+	}
+}
+
+// reconnectBackoff 计算第 attempt 次 (从 0 开始) 重连的等待时间
+// 从 base 开始每次翻倍，不超过 max，再叠加 ±20% 的随机抖动 (r 为 [0,1) 的随机数)，
+// 避免服务端恢复时所有 Agent 同时重连
+func reconnectBackoff(base, max time.Duration, attempt int, r float64) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	if max < base {
+		max = base
+	}
+
+	delay := base
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	jitter := (r*2 - 1) * 0.2 // [-0.2, 0.2)
+	return time.Duration(float64(delay) * (1 + jitter))
+}
+
+// dial 建立 WebSocket (或 HTTP 长轮询) 连接
+func (a *AgentClient) dial() (err error) {
 	// 构建 Socket.IO 握手 URL
-	u, err := url.Parse(a.config.ServerURL)
+	u, err := url.Parse(a.currentServerURL())
 	if err != nil {
 		return fmt.Errorf("无效的服务器地址: %v", err)
 	}
@@ -189,83 +593,178 @@ func (a *AgentClient) dial() error {
 		scheme = "wss"
 	}
 
-	// Socket.IO v4 握手
-	handshakeURL := fmt.Sprintf("%s://%s/socket.io/?EIO=4&transport=polling", u.Scheme, u.Host)
-	resp, err := http.Get(handshakeURL)
+	a.mu.Lock()
+	tlsConfig := a.tlsConfig
+	a.mu.Unlock()
+
+	// 握手请求与 WebSocket 使用相同的 TLS 和代理配置
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:  tlsConfig,
+	}
+	if err := applyProxy(a.cfg(), transport, &dialer); err != nil {
+		return err
+	}
+
+	// permessage-deflate: 服务端支持时由传输层透明压缩，并统计实际发送的字节数
+	var stats *compressionStats
+	if a.cfg().Compression {
+		dialer.EnableCompression = true
+		stats = &compressionStats{}
+		baseDial := (&net.Dialer{Timeout: 10 * time.Second}).DialContext
+		if dialer.NetDial != nil {
+			netDial := dialer.NetDial
+			baseDial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return netDial(network, addr)
+			}
+		}
+		dialer.NetDialContext = countingDialContext(baseDial, &stats.wire)
+	}
+
+	// Socket.IO 握手 (EIO=3 时要求文本 payload，避免服务端返回二进制编码)
+	eio := engineIOVersion(a.cfg())
+	handshakeURL := fmt.Sprintf("%s://%s/socket.io/?EIO=%d&transport=polling", u.Scheme, u.Host, eio)
+	if eio == engineIOv3 {
+		handshakeURL += "&b64=1"
+	}
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+	resp, err := httpClient.Get(handshakeURL)
 	if err != nil {
 		return fmt.Errorf("握手失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	// Socket.IO 响应格式: 0{"sid":"xxx",...}
-	bodyStr := string(body)
-	if len(bodyStr) < 2 {
-		return fmt.Errorf("无效的握手响应")
+	if resp.StatusCode != http.StatusOK {
+		// 服务端过载 (如 503) 时可能通过 Retry-After 头或 {"retryAfter": 秒} 要求推迟重连
+		var hint struct {
+			RetryAfter float64 `json:"retryAfter"`
+		}
+		json.Unmarshal(body, &hint)
+		if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+			hint.RetryAfter = seconds
+		}
+		a.setRetryAfter(hint.RetryAfter)
+		if err := handshakeVersionError(string(body), eio); err != nil {
+			return err
+		}
+		return fmt.Errorf("握手失败: HTTP %d", resp.StatusCode)
+	}
+	// Socket.IO 响应格式: 0{"sid":"xxx",...} (EIO=3 为 96:0{"sid":"xxx",...})
+	openPacket, err := parseHandshakeBody(string(body), eio)
+	if err != nil {
+		return err
 	}
 
 	var handshake struct {
-		SID string `json:"sid"`
+		SID          string  `json:"sid"`
+		PingInterval int     `json:"pingInterval"` // 毫秒
+		PingTimeout  int     `json:"pingTimeout"`  // 毫秒
+		RetryAfter   float64 `json:"retryAfter"`   // 秒，本次连接失败或断开后的重连等待时间
 	}
-	if err := json.Unmarshal([]byte(bodyStr[1:]), &handshake); err != nil {
+	if err := json.Unmarshal([]byte(openPacket), &handshake); err != nil {
 		return fmt.Errorf("解析握手响应失败: %v", err)
 	}
+	a.setRetryAfter(handshake.RetryAfter)
 
-	// 升级到 WebSocket
-	wsURL := fmt.Sprintf("%s://%s/socket.io/?EIO=4&transport=websocket&sid=%s", scheme, u.Host, handshake.SID)
-	log.Printf("[Agent] 正在连接: %s", wsURL)
-
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
+	a.mu.Lock()
+	a.pingInterval = defaultPingInterval
+	if handshake.PingInterval > 0 {
+		a.pingInterval = time.Duration(handshake.PingInterval) * time.Millisecond
 	}
-	conn, _, err := dialer.Dial(wsURL, nil)
-	if err != nil {
-		return fmt.Errorf("WebSocket 连接失败: %v", err)
+	a.pingTimeout = defaultPingTimeout
+	if handshake.PingTimeout > 0 {
+		a.pingTimeout = time.Duration(handshake.PingTimeout) * time.Millisecond
 	}
+	a.lastPingTime = time.Now()
+	a.mu.Unlock()
 
-	a.conn = conn
-
-	// 发送 Socket.IO 升级确认
-	if err := conn.WriteMessage(websocket.TextMessage, []byte("2probe")); err != nil {
-		return err
+	// 升级到 WebSocket，被代理/WAF 拦截时 (transport 为 auto) 在同一 sid 上改用 HTTP 长轮询
+	var conn agentConn
+	mode := a.cfg().Transport
+	if mode != transportPolling {
+		wsURL := fmt.Sprintf("%s://%s/socket.io/?EIO=%d&transport=websocket&sid=%s", scheme, u.Host, eio, handshake.SID)
+		logger.Infof("[Agent] 正在连接: %s", wsURL)
+		wsConn, err := a.upgradeWebSocket(&dialer, wsURL)
+		if err != nil {
+			if mode == transportWebSocket {
+				return err
+			}
+			logger.Warnf("[Agent] %v，改用 HTTP 长轮询", err)
+		} else {
+			conn = wsConn
+			mode = transportWebSocket
+		}
 	}
-
-	// 等待服务器确认
-	_, msg, err := conn.ReadMessage()
-	if err != nil || string(msg) != "3probe" {
-		return fmt.Errorf("升级确认失败")
+	if conn == nil {
+		pollURL := fmt.Sprintf("%s://%s/socket.io/?EIO=%d&transport=polling&sid=%s", u.Scheme, u.Host, eio, handshake.SID)
+		if eio == engineIOv3 {
+			pollURL += "&b64=1"
+		}
+		logger.Infof("[Agent] 正在连接 (长轮询): %s", pollURL)
+		a.mu.Lock()
+		pollTimeout := a.readTimeout() + 10*time.Second
+		a.mu.Unlock()
+		conn = newPollingConn(transport, pollURL, eio, pollTimeout)
+		mode = transportPolling
+		stats = nil // 长轮询不经过 WebSocket 压缩
 	}
 
-	// 发送升级完成
-	if err := conn.WriteMessage(websocket.TextMessage, []byte("5")); err != nil {
-		return err
-	}
+	a.mu.Lock()
+	a.conn = conn
+	a.compression = stats
+	a.connStats.Transport = mode
+	a.mu.Unlock()
+	// 命名空间连接失败时关闭连接 (长轮询的后台请求随之停止)
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	conn.SetReadDeadline(time.Now().Add(a.readTimeout()))
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
 
 	// 连接到 /agent 命名空间
 	if err := conn.WriteMessage(websocket.TextMessage, []byte("40/agent,")); err != nil {
 		return err
 	}
 
-	// 等待命名空间确认 (40/agent,{...})
-	_, nsMsg, err := conn.ReadMessage()
-	if err != nil {
-		return fmt.Errorf("命名空间确认失败: %v", err)
-	}
-	nsStr := string(nsMsg)
-	if !strings.HasPrefix(nsStr, "40/agent") {
-		// 可能是 ping 消息，继续读取
+	// 等待命名空间确认 (40/agent,{...})，期间可能收到 ping 或 EIO=3 默认命名空间的 40
+	var nsStr string
+	for i := 0; i < 5; i++ {
+		_, nsMsg, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("命名空间确认失败: %v", err)
+		}
+		nsStr = string(nsMsg)
 		if nsStr == "2" {
 			conn.WriteMessage(websocket.TextMessage, []byte("3"))
-			_, nsMsg, err = conn.ReadMessage()
-			if err != nil {
-				return fmt.Errorf("命名空间确认失败: %v", err)
-			}
-			nsStr = string(nsMsg)
+			continue
 		}
+		if nsStr == "3" || nsStr == "40" {
+			continue
+		}
+		break
+	}
+
+	if strings.HasPrefix(nsStr, "44/agent") {
+		message, retryAfter := parseConnectError(nsStr)
+		a.setRetryAfter(retryAfter)
+		return fmt.Errorf("服务端拒绝连接: %s", message)
 	}
 
-	log.Printf("[Agent] 命名空间已确认: %s", nsStr)
-	log.Println("[Agent] 已连接，正在认证...")
+	logger.Infof("[Agent] 命名空间已确认: %s", nsStr)
+
+	// 服务端在命名空间确认中下发 nonce 时，认证改为发送 HMAC 签名
+	nonce := parseConnectNonce(nsStr)
+	a.mu.Lock()
+	a.authNonce = nonce
+	a.mu.Unlock()
+	logger.Infof("[Agent] 已连接，正在认证...")
 
 	// 发送认证
 	a.authenticate()
@@ -273,42 +772,174 @@ func (a *AgentClient) dial() error {
 	return nil
 }
 
+// upgradeWebSocket 建立 WebSocket 连接并完成 Engine.IO 升级 (2probe/3probe/5)
+func (a *AgentClient) upgradeWebSocket(dialer *websocket.Dialer, wsURL string) (*websocket.Conn, error) {
+	conn, _, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket 连接失败: %v", err)
+	}
+
+	// 握手阶段的读写也设置超时，避免服务端无响应时卡住
+	a.mu.Lock()
+	conn.SetReadDeadline(time.Now().Add(a.readTimeout()))
+	a.mu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	// 发送 Socket.IO 升级确认
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("2probe")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket 升级失败: %v", err)
+	}
+
+	// 等待服务器确认
+	_, msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "3probe" {
+		conn.Close()
+		return nil, fmt.Errorf("升级确认失败")
+	}
+
+	// 发送升级完成
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("5")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket 升级失败: %v", err)
+	}
+	return conn, nil
+}
+
 // authenticate 发送认证请求
+// 服务端提供了 nonce 且开启 authHmac 时只发送 HMAC-SHA256(agentKey, nonce)，密钥本身不经过网络；
+// 否则发送明文密钥 (兼容旧版服务端)
 func (a *AgentClient) authenticate() {
-	hostname, _ := os.Hostname()
 	authData := map[string]interface{}{
-		"server_id": a.config.ServerID,
-		"key":       a.config.AgentKey,
-		"hostname":  hostname,
+		"server_id": a.cfg().ServerID,
+		"hostname":  GetHostname(a.cfg()),
 		"version":   VERSION,
 	}
+
+	a.mu.Lock()
+	nonce := a.authNonce
+	a.mu.Unlock()
+	if a.cfg().AuthHMAC && nonce != "" {
+		authData["nonce"] = nonce
+		authData["signature"] = signAuthNonce(a.cfg().AgentKey, nonce)
+	} else {
+		authData["key"] = a.cfg().AgentKey
+	}
 	a.emit(EventAgentConnect, authData)
 }
 
+// signAuthNonce 以 Agent 密钥对服务端 nonce 计算 HMAC-SHA256 (十六进制)
+func signAuthNonce(key, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseConnectNonce 从命名空间确认 40/agent,{"sid":"...","nonce":"..."} 中取出认证 nonce
+func parseConnectNonce(msg string) string {
+	idx := strings.IndexByte(msg, ',')
+	if idx < 0 {
+		return ""
+	}
+	var payload struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal([]byte(msg[idx+1:]), &payload); err != nil {
+		return ""
+	}
+	return payload.Nonce
+}
+
 // emit 发送事件
 func (a *AgentClient) emit(event string, data interface{}) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	// Socket.IO 事件格式: 42/namespace,["event", data]
+	msg, err := encodeEvent(-1, event, data)
+	if err != nil {
+		return err
+	}
+
 	if a.conn == nil {
+		a.bufferEvent(event, msg)
 		return fmt.Errorf("未连接")
 	}
-
-	// Socket.IO 事件格式: 42/namespace,["event", data]
-	payload := []interface{}{event, data}
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
+	if err := a.writeFrame(msg); err != nil {
+		a.bufferEvent(event, msg)
 		return err
 	}
+	return nil
+}
 
-	msg := fmt.Sprintf("42/agent,%s", string(jsonData))
+// writeFrame 带写超时发送一帧文本消息 (调用方需持有 a.mu 且 a.conn 非空)
+func (a *AgentClient) writeFrame(msg string) error {
+	if a.compression != nil {
+		a.compression.record(len(msg))
+	}
+	a.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
 	return a.conn.WriteMessage(websocket.TextMessage, []byte(msg))
 }
 
+// readTimeout 读超时: pingInterval + pingTimeout 内没有收到任何帧视为连接已失效
+// (与 Socket.IO 客户端判定心跳超时的方式一致)
+func (a *AgentClient) readTimeout() time.Duration {
+	interval, timeout := a.pingInterval, a.pingTimeout
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	return interval + timeout
+}
+
+// bufferEvent 缓存发送失败的事件 (调用方需持有 a.mu)
+// 认证请求、终端输出和退出通知不缓存：认证每次连接都会重新发送，其余在断线后已无意义
+func (a *AgentClient) bufferEvent(event, msg string) {
+	if a.pendingEvents == nil || event == EventAgentConnect || event == EventAgentPtyData || event == EventAgentDisconnect {
+		return
+	}
+	a.pendingEvents.push(bufferedEvent{Event: event, Message: msg, QueuedAt: time.Now()})
+}
+
+// flushPendingEvents 认证成功后按顺序补发断线期间缓存的事件
+func (a *AgentClient) flushPendingEvents() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pendingEvents == nil || a.conn == nil {
+		return
+	}
+
+	maxAge := time.Duration(a.cfg().StateBufferMaxAge) * time.Millisecond
+	events := a.pendingEvents.drain()
+	sent := 0
+	for i, e := range events {
+		// 高频的状态采样过期后没有补发价值
+		if e.Event == EventAgentState && maxAge > 0 && time.Since(e.QueuedAt) > maxAge {
+			continue
+		}
+		if err := a.writeFrame(e.Message); err != nil {
+			// 连接再次断开，剩余事件放回缓冲区等待下次补发
+			for _, rest := range events[i:] {
+				a.pendingEvents.push(rest)
+			}
+			logger.Warnf("[Agent] 补发缓存事件失败: %v", err)
+			return
+		}
+		sent++
+	}
+	if sent > 0 {
+		logger.Infof("[Agent] 已补发 %d 条断线期间缓存的事件", sent)
+	}
+}
+
 // messageLoop 消息处理循环
 func (a *AgentClient) messageLoop() {
-	// 启动心跳
-	go a.heartbeat()
+	// 启动心跳 (连接断开时随消息循环一起退出)
+	done := make(chan struct{})
+	defer close(done)
+	go a.heartbeat(a.conn, done)
 
 	for {
 		select {
@@ -317,16 +948,23 @@ func (a *AgentClient) messageLoop() {
 		default:
 		}
 
+		// 每收到一帧 (包括 ping/pong) 都顺延读超时，半开连接 (NAT 超时、断网) 会在超时后返回错误并触发重连
+		a.mu.Lock()
+		readTimeout := a.readTimeout()
+		a.mu.Unlock()
+		a.conn.SetReadDeadline(time.Now().Add(readTimeout))
+
 		_, message, err := a.conn.ReadMessage()
 		if err != nil {
-			log.Printf("[Agent] 读取消息失败: %v", err)
+			logger.Warnf("[Agent] 读取消息失败: %v", err)
+			a.noteDisconnect("read error: " + err.Error())
 			return
 		}
 
 		msg := string(message)
 		// 调试日志：显示收到的消息（排除心跳）
 		if msg != "2" && msg != "3" {
-			log.Printf("[Agent] 收到消息: %s", msg)
+			logger.Debugf("[Agent] 收到消息: %s", msg)
 		}
 
 		a.handleMessage(msg)
@@ -343,15 +981,21 @@ func (a *AgentClient) handleMessage(msg string) {
 	// 服务端发送的 ping，需要立即回复 pong
 	if msg == "2" {
 		a.mu.Lock()
+		a.lastPingTime = time.Now()
 		if a.conn != nil {
-			a.conn.WriteMessage(websocket.TextMessage, []byte("3"))
+			a.writeFrame("3")
 		}
 		a.mu.Unlock()
 		return
 	}
 
-	// 心跳响应 (服务端回复的 pong)
+	// 心跳响应 (服务端回复的 pong，EIO=3 时以此判断连接存活)
 	if msg == "3" {
+		if engineIOVersion(a.cfg()) == engineIOv3 {
+			a.mu.Lock()
+			a.lastPingTime = time.Now()
+			a.mu.Unlock()
+		}
 		return
 	}
 
@@ -360,13 +1004,37 @@ func (a *AgentClient) handleMessage(msg string) {
 		return
 	}
 
+	// 命名空间连接错误 (如服务端中间件拒绝认证): 44/agent,{"message": "..."}
+	if strings.HasPrefix(msg, "44/agent") {
+		message, retryAfter := parseConnectError(msg)
+		logger.Warnf("[Agent] 服务端拒绝连接: %s", message)
+		a.setRetryAfter(retryAfter)
+		a.noteDisconnect("connect_error: " + message)
+		a.dropConnection()
+		return
+	}
+
+	// 服务端断开命名空间: 41/agent
+	if strings.HasPrefix(msg, "41/agent") {
+		logger.Warnf("[Agent] 服务端断开了连接")
+		a.noteDisconnect("server_disconnect")
+		a.dropConnection()
+		return
+	}
+
+	// 事件确认: 43/agent,<id>[args...]
+	if id, args, ok := parseAckPacket(msg); ok {
+		a.resolveAck(id, args)
+		return
+	}
+
 	// 事件消息: 42/agent,["event", data]
 	if strings.HasPrefix(msg, "42/agent,") {
 		jsonStr := msg[9:] // 移除 "42/agent,"
 
 		var payload []json.RawMessage
 		if err := json.Unmarshal([]byte(jsonStr), &payload); err != nil {
-			log.Printf("[Agent] 解析消息失败: %v", err)
+			logger.Warnf("[Agent] 解析消息失败: %v", err)
 			return
 		}
 
@@ -386,30 +1054,130 @@ func (a *AgentClient) handleMessage(msg string) {
 	}
 }
 
+// parseConnectError 提取 44/agent,{...} 中的错误信息，无法解析时返回原始内容
+// 同时返回服务端要求的重连等待秒数 (retryAfter 或 data.retryAfter，未提供时为 0)
+func parseConnectError(msg string) (string, float64) {
+	idx := strings.IndexByte(msg, ',')
+	if idx < 0 {
+		return "未知错误", 0
+	}
+	body := msg[idx+1:]
+
+	var data struct {
+		Message    string  `json:"message"`
+		RetryAfter float64 `json:"retryAfter"`
+		Data       struct {
+			RetryAfter float64 `json:"retryAfter"`
+		} `json:"data"` // Socket.IO 中间件 next(err) 时 err.data 的内容
+	}
+	if err := json.Unmarshal([]byte(body), &data); err == nil && data.Message != "" {
+		retryAfter := data.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = data.Data.RetryAfter
+		}
+		return data.Message, retryAfter
+	}
+	// 旧版本 Socket.IO 直接发送字符串
+	var text string
+	if err := json.Unmarshal([]byte(body), &text); err == nil && text != "" {
+		return text, 0
+	}
+	return body, 0
+}
+
+// maxServerRetryAfter 服务端要求的重连等待时间上限 (防止异常的服务端让 Agent 长期离线)
+const maxServerRetryAfter = 15 * time.Minute
+
+// clampRetryAfter 将服务端给出的秒数转换为等待时间，限制在 (0, maxServerRetryAfter] 内，无效值返回 0
+func clampRetryAfter(seconds float64) time.Duration {
+	if seconds <= 0 || math.IsNaN(seconds) {
+		return 0
+	}
+	if seconds >= maxServerRetryAfter.Seconds() {
+		return maxServerRetryAfter
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// setRetryAfter 记录服务端要求的重连等待时间，下一次 waitReconnect 使用
+func (a *AgentClient) setRetryAfter(seconds float64) {
+	delay := clampRetryAfter(seconds)
+	if delay <= 0 {
+		return
+	}
+	a.mu.Lock()
+	a.retryAfter = delay
+	a.mu.Unlock()
+}
+
+// dropConnection 关闭当前连接，messageLoop 退出后由 connect 按退避策略重连
+func (a *AgentClient) dropConnection() {
+	a.mu.Lock()
+	a.authenticated = false
+	conn := a.conn
+	a.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
 // handleEvent 处理事件
 func (a *AgentClient) handleEvent(event string, data json.RawMessage) {
 	switch event {
 	case EventDashboardAuthOK:
-		log.Println("[Agent] ✅ 认证成功")
+		logger.Infof("[Agent] ✅ 认证成功")
+		var authOK struct {
+			TaskResultAck bool `json:"task_result_ack"`
+		}
+		json.Unmarshal(data, &authOK)
 		a.mu.Lock()
 		a.authenticated = true
+		a.taskResultAck = authOK.TaskResultAck
+		a.reconnectAttempt = 0 // 会话已建立，重连退避从初始值重新开始
+		a.retryAfter = 0       // 握手时的重连提示只针对本次连接失败
+		a.serverFailures = 0   // 认证成功后固定使用当前服务器，直到连接断开后再次连续失败
 		a.mu.Unlock()
 
+		// --no-report: 保持在线并响应心跳和任务，但不上报数据
+		if a.cfg().NoReport {
+			break
+		}
+
 		// 稍微延迟后再发送数据，避免与 ping/pong 竞争
 		go func() {
 			time.Sleep(100 * time.Millisecond)
+			// 补发断线期间缓存的事件
+			a.flushPendingEvents()
 			// 发送主机信息
 			a.reportHostInfo()
 			// 启动上报循环
 			a.reportLoop()
 		}()
 
+	case EventAgentReauth:
+		// 服务端要求重新认证 (如密钥轮换)：暂停上报，在现有连接上重新发送认证请求
+		logger.Infof("[Agent] 服务端要求重新认证，在当前连接上重新认证")
+		var reauth struct {
+			Nonce string `json:"nonce"` // 新的认证 nonce (可选，nonce 只能使用一次)
+		}
+		json.Unmarshal(data, &reauth)
+		a.mu.Lock()
+		a.authenticated = false
+		if reauth.Nonce != "" {
+			a.authNonce = reauth.Nonce
+		}
+		conn := a.conn
+		a.mu.Unlock()
+
+		a.authenticate()
+		go a.watchReauth(conn)
+
 	case EventDashboardAuthFail:
 		var failData struct {
 			Reason string `json:"reason"`
 		}
 		json.Unmarshal(data, &failData)
-		log.Printf("[Agent] ❌ 认证失败: %s", failData.Reason)
+		logger.Warnf("[Agent] ❌ 认证失败: %s", failData.Reason)
 		os.Exit(1)
 
 	case EventDashboardTask:
@@ -430,6 +1198,9 @@ func (a *AgentClient) handleEvent(event string, data json.RawMessage) {
 		if err := json.Unmarshal(data, &input); err == nil {
 			a.mu.Lock()
 			pty, ok := a.ptySessions[input.ID]
+			if ok {
+				a.ptyActivity[input.ID] = time.Now()
+			}
 			a.mu.Unlock()
 			if ok {
 				pty.Write([]byte(input.Data))
@@ -450,16 +1221,113 @@ func (a *AgentClient) handleEvent(event string, data json.RawMessage) {
 				pty.Resize(resize.Cols, resize.Rows)
 			}
 		}
+
+	case EventDashboardPtyClose:
+		var closeReq struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &closeReq); err == nil {
+			a.closePTYSession(closeReq.ID, "closed")
+		}
 	}
 }
 
+// StopWithTimeout 在 shutdownTimeout 内关闭 Agent，超时则放弃等待 (避免被 systemd 等服务管理器的停止超时强杀)
+// 返回 false 表示关闭未在时限内完成
+func (a *AgentClient) StopWithTimeout(reason string) bool {
+	timeout := time.Duration(a.cfg().ShutdownTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.StopWithReason(reason)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		logger.Warnf("[Agent] %.0f 秒内未完成关闭，强制退出", timeout.Seconds())
+		return false
+	}
+}
+
+// shutdownReason 退出信号对应的原因 (随 agent:disconnect 发送给服务端)
+// SIGTERM 通常来自服务管理器的停止/重启，SIGINT 来自终端 Ctrl+C
+func shutdownReason(sig os.Signal) string {
+	switch sig {
+	case syscall.SIGTERM:
+		return "terminate"
+	case os.Interrupt:
+		return "interrupt"
+	default:
+		return sig.String()
+	}
+}
+
+// flushFinalState 已认证时发送最后一次状态和 agent:disconnect，最多等待 finalFlushTimeout
+func (a *AgentClient) flushFinalState(reason string) {
+	a.mu.Lock()
+	auth := a.authenticated && a.conn != nil
+	a.mu.Unlock()
+	if !auth {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if !a.cfg().NoReport {
+			state := a.collector.CollectState()
+			if err := a.emit(EventAgentState, filterFields(state, a.cfg().StateFields)); err != nil {
+				logger.Warnf("[Agent] 最后一次状态上报失败: %v", err)
+			}
+		}
+		a.emit(EventAgentDisconnect, map[string]interface{}{"reason": reason})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(finalFlushTimeout):
+		logger.Warnf("[Agent] 退出前上报超时，直接关闭连接")
+	}
+}
+
+// watchReauth 重新认证超时未成功时关闭连接，由 connect 走完整重连流程
+func (a *AgentClient) watchReauth(conn agentConn) {
+	select {
+	case <-a.stopChan:
+		return
+	case <-time.After(reauthTimeout):
+	}
+
+	a.mu.Lock()
+	// 已认证成功或连接已更换，无需处理
+	done := a.authenticated || a.conn != conn
+	a.mu.Unlock()
+	if done || conn == nil {
+		return
+	}
+
+	logger.Warnf("[Agent] 重新认证 %.0f 秒内未完成，断开重连", reauthTimeout.Seconds())
+	a.noteDisconnect("reauth_timeout")
+	conn.Close() // 使 messageLoop 的 ReadMessage 返回
+}
+
 // reportHostInfo 上报主机信息
 func (a *AgentClient) reportHostInfo() {
 	hostInfo := a.collector.CollectHostInfo()
-	if err := a.emit(EventAgentHostInfo, hostInfo); err != nil {
-		log.Printf("[Agent] 上报主机信息失败: %v", err)
-	} else if a.config.Debug {
-		log.Println("[Agent] 已上报主机信息")
+	a.mu.Lock()
+	a.lastHostInfo = hostInfo
+	a.mu.Unlock()
+
+	if err := a.emit(EventAgentHostInfo, filterFields(hostInfo, a.cfg().HostInfoFields)); err != nil {
+		logger.Warnf("[Agent] 上报主机信息失败: %v", err)
+	} else {
+		logger.Debugf("[Agent] 已上报主机信息")
 	}
 }
 
@@ -474,56 +1342,148 @@ func (a *AgentClient) reportState() {
 	}
 
 	state := a.collector.CollectState()
-	if err := a.emit(EventAgentState, state); err != nil {
-		log.Printf("[Agent] 状态上报失败: %v", err)
-	} else if a.config.Debug {
-		log.Printf("[Agent] 状态上报: CPU=%.1f%%, MEM=%.1fGB, GPU=%.1f%%, Power=%.1fW",
+	a.mu.Lock()
+	a.lastState = state
+	a.lastStateTime = time.Now()
+	a.mu.Unlock()
+
+	if err := a.emit(EventAgentState, filterFields(state, a.cfg().StateFields)); err != nil {
+		logger.Warnf("[Agent] 状态上报失败: %v", err)
+	} else {
+		logger.Debugf("[Agent] 状态上报: CPU=%.1f%%, MEM=%.1fGB, GPU=%.1f%%, Power=%.1fW",
 			state.CPU, float64(state.MemUsed)/1024/1024/1024, state.GPU, state.GPUPower)
 	}
 }
 
+// reportUrgentState 检查紧急条件，新触发时立即上报一次带 urgent 标记的状态
+func (a *AgentClient) reportUrgentState() {
+	reasons := a.collector.CheckUrgentConditions()
+	if len(reasons) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	auth := a.authenticated
+	a.mu.Unlock()
+	if !auth {
+		return
+	}
+
+	state := a.collector.CollectState()
+	state.Urgent = true
+	state.UrgentReasons = reasons
+	if err := a.emit(EventAgentState, filterFields(state, a.cfg().StateFields)); err != nil {
+		logger.Warnf("[Agent] 紧急状态上报失败: %v", err)
+	} else {
+		logger.Warnf("[Agent] ⚠️ 紧急状态已上报: %s", strings.Join(reasons, ", "))
+	}
+}
+
 // reportLoop 定时上报循环
 func (a *AgentClient) reportLoop() {
+	// 重新认证时旧的上报循环可能仍在运行，只保留一个
+	a.mu.Lock()
+	if a.reporting {
+		a.mu.Unlock()
+		return
+	}
+	a.reporting = true
+	a.mu.Unlock()
+
 	// 立即上报一次
 	a.reportState()
 
-	stateTicker := time.NewTicker(time.Duration(a.config.ReportInterval) * time.Millisecond)
-	hostInfoTicker := time.NewTicker(time.Duration(a.config.HostInfoInterval) * time.Millisecond)
+	stateInterval, hostInfoInterval := a.reportIntervals()
+	stateTicker := time.NewTicker(stateInterval)
+	hostInfoTicker := time.NewTicker(hostInfoInterval)
 
 	defer stateTicker.Stop()
 	defer hostInfoTicker.Stop()
 
+	// 紧急条件检查 (未配置时不启用)
+	var urgentChan <-chan time.Time
+	if len(a.cfg().UrgentConditions) > 0 {
+		a.collector.CheckUrgentConditions() // 建立基准
+		urgentTicker := time.NewTicker(1 * time.Second)
+		defer urgentTicker.Stop()
+		urgentChan = urgentTicker.C
+	}
+
 	for {
 		select {
 		case <-a.stopChan:
+			a.mu.Lock()
+			a.reporting = false
+			a.mu.Unlock()
 			return
 		case <-stateTicker.C:
 			a.reportState()
 		case <-hostInfoTicker.C:
 			a.reportHostInfo()
+		case <-urgentChan:
+			a.reportUrgentState()
+		case <-a.reloadChan:
+			stateInterval, hostInfoInterval := a.reportIntervals()
+			stateTicker.Reset(stateInterval)
+			hostInfoTicker.Reset(hostInfoInterval)
 		}
 
+		// 检查认证状态与清除 reporting 标记需在同一临界区内，
+		// 否则重新认证成功时可能出现两个循环都不运行的情况
 		a.mu.Lock()
-		auth := a.authenticated
-		a.mu.Unlock()
-		if !auth {
+		if !a.authenticated {
+			a.reporting = false
+			a.mu.Unlock()
 			return
 		}
+		a.mu.Unlock()
 	}
 }
 
-// heartbeat 心跳监控 - 只处理停止信号，ping响应在handleMessage中处理
-func (a *AgentClient) heartbeat() {
-	// Socket.IO 中只有服务端发送 ping (2)，客户端只需响应 pong (3)
-	// 我们在 handleMessage 中已经处理了 ping 响应
-	// 这个 goroutine 只是为了监听 stopChan
-	// 这下应该不会错了(应该
-	<-a.stopChan
+// heartbeat 心跳监控 - ping 响应在 handleMessage 中处理
+// Socket.IO v3/v4 中只有服务端发送 ping (2)，客户端只需响应 pong (3)；EIO=3 时由客户端每个 pingInterval 发送 ping
+// 这里按服务端下发的 pingInterval 检查，超过 pingInterval + pingTimeout 未收到 ping 视为连接失效
+func (a *AgentClient) heartbeat(conn agentConn, done <-chan struct{}) {
+	a.mu.Lock()
+	interval, timeout := a.pingInterval, a.pingTimeout
+	a.mu.Unlock()
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			elapsed := time.Since(a.lastPingTime)
+			// EIO=3 由客户端发送 ping，服务端回复的 pong 会刷新 lastPingTime
+			if engineIOVersion(a.cfg()) == engineIOv3 && a.conn == conn {
+				a.writeFrame("2")
+			}
+			a.mu.Unlock()
+			if elapsed > interval+timeout {
+				logger.Warnf("[Agent] %.0f 秒未收到服务端心跳，断开重连", elapsed.Seconds())
+				a.noteDisconnect("ping_timeout")
+				conn.Close() // 使 messageLoop 的 ReadMessage 返回
+				return
+			}
+		}
+	}
 }
 
 // handleTask 处理任务
 func (a *AgentClient) handleTask(id string, taskType int, data string, timeout int) {
-	log.Printf("[Agent] 收到任务: %s (type=%d)", id, taskType)
+	logger.Infof("[Agent] 收到任务: %s (type=%d)", id, taskType)
 
 	result := map[string]interface{}{
 		"id":         id,
@@ -535,9 +1495,19 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 
 	startTime := time.Now()
 
+	// 任务超时 (秒)，耗时较长的任务类型通过 ctx 响应取消
+	ctx, cancel := context.WithTimeout(context.Background(), taskTimeout(timeout))
+	defer cancel()
+	delaySet := false // 探测类任务在 delay 中返回测得的延迟，而不是任务耗时
+	restart := false  // 结果送达后重启 Agent
+
 	switch taskType {
-	case 1: // COMMAND - 执行命令
-		output, err := a.executeCommand(data, timeout)
+	case 1: // COMMAND - 执行命令 (需要开启 allowExec)
+		if !a.cfg().AllowExec {
+			result["data"] = "命令执行未启用 (配置 allowExec)"
+			break
+		}
+		output, err := a.executeCommand(ctx, data)
 		if err != nil {
 			result["data"] = err.Error()
 		} else {
@@ -549,8 +1519,12 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 		result["successful"] = true
 	case 7: // KEEPALIVE
 		result["successful"] = true
-	case 10: // DOCKER_ACTION
-		output, err := a.handleDockerAction(data)
+	case 10: // DOCKER_ACTION (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
+		output, err := a.handleDockerAction(ctx, data)
 		if err != nil {
 			result["data"] = err.Error()
 		} else {
@@ -558,7 +1532,7 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["data"] = output
 		}
 	case 11: // DOCKER_CHECK_UPDATE
-		output, err := a.handleDockerCheckUpdate(data)
+		output, err := a.handleDockerCheckUpdate(ctx, data)
 		if err != nil {
 			result["data"] = err.Error()
 		} else {
@@ -573,7 +1547,11 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 14: // DOCKER_IMAGE_ACTION - 镜像操作
+	case 14: // DOCKER_IMAGE_ACTION - 镜像操作 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
 		output, err := a.handleDockerImageAction(data)
 		if err != nil {
 			result["data"] = err.Error()
@@ -589,7 +1567,11 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 16: // DOCKER_NETWORK_ACTION - 网络操作
+	case 16: // DOCKER_NETWORK_ACTION - 网络操作 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
 		output, err := a.handleDockerNetworkAction(data)
 		if err != nil {
 			result["data"] = err.Error()
@@ -605,7 +1587,11 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 18: // DOCKER_VOLUME_ACTION - Volume 操作
+	case 18: // DOCKER_VOLUME_ACTION - Volume 操作 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
 		output, err := a.handleDockerVolumeAction(data)
 		if err != nil {
 			result["data"] = err.Error()
@@ -614,7 +1600,7 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["data"] = output
 		}
 	case 19: // DOCKER_LOGS - 容器日志
-		output, err := a.handleDockerLogs(data)
+		output, err := a.handleDockerLogs(ctx, data)
 		if err != nil {
 			result["data"] = err.Error()
 		} else {
@@ -637,7 +1623,11 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 22: // DOCKER_COMPOSE_ACTION - Compose 操作
+	case 22: // DOCKER_COMPOSE_ACTION - Compose 操作 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
 		output, err := a.handleDockerComposeAction(data)
 		if err != nil {
 			result["data"] = err.Error()
@@ -645,7 +1635,11 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 23: // DOCKER_CREATE_CONTAINER - 创建容器
+	case 23: // DOCKER_CREATE_CONTAINER - 创建容器 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
 		output, err := a.handleDockerCreateContainer(data)
 		if err != nil {
 			result["data"] = err.Error()
@@ -653,92 +1647,253 @@ func (a *AgentClient) handleTask(id string, taskType int, data string, timeout i
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 24: // DOCKER_UPDATE_CONTAINER - 容器一键更新
-		go a.handleDockerContainerUpdate(id, data)
+	case 24: // DOCKER_UPDATE_CONTAINER - 容器一键更新 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
+		go a.handleDockerContainerUpdate(id, data)
+		result["successful"] = true
+		result["data"] = "容器更新任务已启动"
+		return // 异步任务，通过进度事件反馈
+	case 25: // DOCKER_RENAME_CONTAINER - 容器重命名 (需要开启 allowDockerControl)
+		if !a.cfg().AllowDockerControl {
+			result["data"] = dockerControlDisabled
+			break
+		}
+		output, err := a.handleDockerRenameContainer(ctx, data)
+		if err != nil {
+			result["data"] = err.Error()
+		} else {
+			result["successful"] = true
+			result["data"] = output
+		}
+	case 26: // DOCKER_TASK_PROGRESS - 查询任务进度
+		output, err := a.getTaskProgress(data)
+		if err != nil {
+			result["data"] = err.Error()
+		} else {
+			result["successful"] = true
+			result["data"] = output
+		}
+	case 27: // METRIC_QUERY - 按需查询原始指标
+		output, err := a.handleMetricQuery(data)
+		if err != nil {
+			result["data"] = err.Error()
+		} else {
+			result["successful"] = true
+			result["data"] = output
+		}
+	case 28: // PING - TCP (host:port) / ICMP (host) 可达性探测
+		rtt, err := probeReachability(ctx, strings.TrimSpace(data))
+		if err != nil {
+			// DNS 解析失败、连接失败都作为不可达结果返回
+			result["data"] = err.Error()
+		} else {
+			result["successful"] = true
+			result["data"] = strconv.FormatFloat(rtt, 'f', 2, 64)
+			result["delay"] = rtt
+			delaySet = true
+		}
+	case 29: // SELF_UPDATE - 下载指定二进制并替换自身 (需要开启 allowSelfUpdate)
+		if !a.cfg().AllowSelfUpdate {
+			result["data"] = "自更新未启用 (配置 allowSelfUpdate)"
+			break
+		}
+		go a.handleSelfUpdate(id, data)
+		return // 异步任务，通过进度事件反馈
+	case 30: // PROCESS_LIST - 完整进程表 (需要开启 enableMetricQuery)
+		output, err := a.handleProcessList(ctx, data)
+		if err != nil {
+			result["data"] = err.Error()
+		} else {
+			result["successful"] = true
+			result["data"] = output
+		}
+	case 31: // HTTP_CHECK - 探测 HTTP/HTTPS 地址 (状态码、响应时间、响应体开头)
+		check, err := runHTTPCheck(ctx, a.cfg(), data)
+		if err != nil {
+			result["data"] = err.Error()
+			break
+		}
+		output, _ := json.Marshal(check)
+		result["successful"] = check.ErrorType == ""
+		result["data"] = string(output)
+		result["delay"] = check.ResponseTime
+		delaySet = true
+	case 32: // SPEEDTEST - 带宽测速 (只能由任务触发，带冷却时间)
+		speed, err := a.handleSpeedtest(ctx)
+		if err != nil {
+			result["data"] = err.Error()
+			break
+		}
+		output, _ := json.Marshal(speed)
 		result["successful"] = true
-		result["data"] = "容器更新任务已启动"
-		return // 异步任务，通过进度事件反馈
-	case 25: // DOCKER_RENAME_CONTAINER - 容器重命名
-		output, err := a.handleDockerRenameContainer(data)
+		result["data"] = string(output)
+	case 33: // RESTART - 重启 Agent (先确认任务结果送达，再重启)
+		if a.isStopping() {
+			result["data"] = "Agent 正在关闭或重启"
+			break
+		}
+		result["successful"] = true
+		result["data"] = "Agent 正在重启"
+		restart = true
+	case 34: // LOG_READ - 读取日志文件末尾 N 行 (限 logReadAllowlist 内的文件)
+		output, err := a.handleLogRead(data)
 		if err != nil {
 			result["data"] = err.Error()
 		} else {
 			result["successful"] = true
 			result["data"] = output
 		}
-	case 26: // DOCKER_TASK_PROGRESS - 查询任务进度
-		output, err := a.getTaskProgress(data)
+	case 35: // TRACEROUTE - 路由跟踪 (调用系统 traceroute/tracert，需要开启 allowExec)
+		if !a.cfg().AllowExec {
+			result["data"] = "命令执行未启用 (配置 allowExec)"
+			break
+		}
+		trace, err := runTraceroute(ctx, data)
 		if err != nil {
 			result["data"] = err.Error()
-		} else {
-			result["successful"] = true
-			result["data"] = output
+			break
+		}
+		output, _ := json.Marshal(trace)
+		result["successful"] = !trace.TimedOut
+		result["data"] = string(output)
+	case 36: // DNS_LOOKUP - 按 dnsResolver 解析域名 (A/AAAA/MX/TXT/CNAME)
+		lookup, err := runDNSLookup(ctx, a.cfg(), data)
+		if err != nil {
+			result["data"] = err.Error()
+			break
 		}
+		output, _ := json.Marshal(lookup)
+		result["successful"] = lookup.ErrorType == ""
+		result["data"] = string(output)
+		result["delay"] = lookup.ResolveTime
+		delaySet = true
 	case 5: // UPGRADE
 		go a.handleUpgrade(id)
 		result["successful"] = true
 		result["data"] = "正在通过后台进程执行升级..."
-	case TaskTypePtyStart: // 启动 PTY
+	case TaskTypePtyStart: // 启动 PTY (需要开启 allowPty)
+		if !a.cfg().AllowPTY {
+			result["data"] = "Web 终端未启用 (配置 allowPty)"
+			break
+		}
 		go a.handlePTYTask(id, data)
 		return // PTY 任务是长连接，不立刻返回结果
 	default:
 		result["data"] = fmt.Sprintf("不支持的任务类型: %d", taskType)
 	}
 
-	result["delay"] = time.Since(startTime).Milliseconds()
+	// 未响应 ctx 的任务类型如果在超时后仍成功完成，保留成功结果
+	if ctx.Err() == context.DeadlineExceeded && result["successful"] != true {
+		result["reason"] = "timeout"
+		logger.Warnf("[Agent] 任务超时: %s (%v)", id, taskTimeout(timeout))
+	}
+
+	if !delaySet {
+		result["delay"] = time.Since(startTime).Milliseconds()
+	}
+
+	a.sendTaskResult(result)
+	logger.Infof("[Agent] 任务完成: %s", id)
 
-	a.emit(EventAgentTaskResult, result)
-	log.Printf("[Agent] 任务完成: %s", id)
+	if restart {
+		a.restartAgent()
+	}
+}
+
+// taskTimeout 将服务端下发的超时 (秒) 转换为时长，0 或负数时使用默认值
+func taskTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultTaskTimeout
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 // executeCommand 执行命令并返回输出
-func (a *AgentClient) executeCommand(command string, timeout int) (string, error) {
-	if command == "" {
+func (a *AgentClient) executeCommand(ctx context.Context, command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
 		return "", fmt.Errorf("命令不能为空")
 	}
 
-	log.Printf("[Agent] 执行命令: %s", command)
+	logger.Infof("[Agent] 执行命令: %s", command)
 
+	// 超时由 ctx 控制，到期后进程会被杀死
 	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", command)
+	if len(a.cfg().ExecAllowlist) > 0 {
+		// 配置了白名单时不经过 shell，避免通过 ; | && 等拼接执行其他程序
+		args := strings.Fields(command)
+		if !isExecAllowed(args[0], a.cfg().ExecAllowlist) {
+			return "", fmt.Errorf("命令不在白名单中: %s", args[0])
+		}
+		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
 	} else {
-		cmd = exec.Command("sh", "-c", command)
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
 	}
-
-	// 设置超时
-	timeoutDuration := 60 * time.Second
-	if timeout > 0 {
-		timeoutDuration = time.Duration(timeout) * time.Second
+	if len(a.cfg().ExecEnv) > 0 {
+		cmd.Env = buildExecEnv(os.Environ(), a.cfg().ExecEnv)
 	}
+	hideWindow(cmd)
 
-	// 使用 context 实现超时
-	done := make(chan error, 1)
-	var output []byte
-	var cmdErr error
-
-	go func() {
-		output, cmdErr = cmd.CombinedOutput()
-		done <- cmdErr
-	}()
+	output, err := cmd.CombinedOutput()
+	output = truncateOutput(output, a.cfg().ExecMaxOutput)
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("命令执行超时")
+	}
+	if err != nil {
+		// 命令执行失败但有输出，返回输出内容
+		if len(output) > 0 {
+			return string(output), fmt.Errorf("命令执行失败: %v\n%s", err, string(output))
+		}
+		return "", fmt.Errorf("命令执行失败: %v", err)
+	}
+	return string(output), nil
+}
 
-	select {
-	case <-time.After(timeoutDuration):
-		// 超时，杀死进程
-		if cmd.Process != nil {
-			cmd.Process.Kill()
+// isExecAllowed 检查程序是否在白名单中 (Windows 下忽略大小写和 .exe 后缀)
+// 白名单中的程序名只匹配不带路径的命令 (由 PATH 查找)；带路径的命令只能与白名单中完全相同的路径匹配，
+// 避免 /tmp/x/df 借用 df 的名字执行任意程序
+func isExecAllowed(program string, allowlist []string) bool {
+	hasPath := containsPathSeparator(program)
+	for _, allowed := range allowlist {
+		if containsPathSeparator(allowed) != hasPath {
+			continue
 		}
-		return "", fmt.Errorf("命令执行超时 (%d秒)", timeout)
-	case err := <-done:
-		if err != nil {
-			// 命令执行失败但有输出，返回输出内容
-			if len(output) > 0 {
-				return string(output), fmt.Errorf("命令执行失败: %v\n%s", err, string(output))
+		if hasPath {
+			allowed, program := filepath.Clean(allowed), filepath.Clean(program)
+			if allowed == program || (runtime.GOOS == "windows" && strings.EqualFold(allowed, program)) {
+				return true
 			}
-			return "", fmt.Errorf("命令执行失败: %v", err)
+			continue
 		}
-		return string(output), nil
+		if allowed == program {
+			return true
+		}
+		if runtime.GOOS == "windows" && strings.TrimSuffix(strings.ToLower(allowed), ".exe") == strings.TrimSuffix(strings.ToLower(program), ".exe") {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPathSeparator 程序名是否带有路径 (Windows 下包括 \ 和盘符)
+func containsPathSeparator(program string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.ContainsAny(program, `/\:`)
+	}
+	return strings.ContainsRune(program, '/')
+}
+
+// truncateOutput 将输出截断到 maxSize 字节 (maxSize <= 0 时不截断)
+func truncateOutput(output []byte, maxSize int) []byte {
+	if maxSize <= 0 || len(output) <= maxSize {
+		return output
 	}
+	truncated := append([]byte{}, output[:maxSize]...)
+	return append(truncated, []byte(fmt.Sprintf("\n... (输出已截断，共 %d 字节)", len(output)))...)
 }
 
 // DockerActionRequest Docker 操作请求
@@ -749,7 +1904,7 @@ type DockerActionRequest struct {
 }
 
 // handleDockerAction 处理 Docker 操作
-func (a *AgentClient) handleDockerAction(data string) (string, error) {
+func (a *AgentClient) handleDockerAction(ctx context.Context, data string) (string, error) {
 	var req DockerActionRequest
 	if err := json.Unmarshal([]byte(data), &req); err != nil {
 		return "", fmt.Errorf("解析请求失败: %v", err)
@@ -759,24 +1914,29 @@ func (a *AgentClient) handleDockerAction(data string) (string, error) {
 		return "", fmt.Errorf("缺少容器 ID")
 	}
 
+	// 只允许操作当前已存在的容器，防止把任意参数传给 docker 命令
+	if err := validateKnownContainer(ctx, req.ContainerID); err != nil {
+		return "", err
+	}
+
 	var cmd *exec.Cmd
 	var actionDesc string
 
 	switch req.Action {
 	case "start":
-		cmd = exec.Command("docker", "start", req.ContainerID)
+		cmd = dockerCommandContext(ctx, "start", req.ContainerID)
 		actionDesc = "启动"
 	case "stop":
-		cmd = exec.Command("docker", "stop", req.ContainerID)
+		cmd = dockerCommandContext(ctx, "stop", req.ContainerID)
 		actionDesc = "停止"
 	case "restart":
-		cmd = exec.Command("docker", "restart", req.ContainerID)
+		cmd = dockerCommandContext(ctx, "restart", req.ContainerID)
 		actionDesc = "重启"
 	case "pause":
-		cmd = exec.Command("docker", "pause", req.ContainerID)
+		cmd = dockerCommandContext(ctx, "pause", req.ContainerID)
 		actionDesc = "暂停"
 	case "unpause":
-		cmd = exec.Command("docker", "unpause", req.ContainerID)
+		cmd = dockerCommandContext(ctx, "unpause", req.ContainerID)
 		actionDesc = "恢复"
 	case "update":
 		// 更新流程: pull 新镜像 -> stop -> rm -> run
@@ -786,20 +1946,20 @@ func (a *AgentClient) handleDockerAction(data string) (string, error) {
 		image := req.Image
 		if image == "" {
 			// 获取容器的镜像
-			inspectCmd := exec.Command("docker", "inspect", "--format", "{{.Config.Image}}", req.ContainerID)
+			inspectCmd := dockerCommand("inspect", "--format", "{{.Config.Image}}", req.ContainerID)
 			output, err := inspectCmd.Output()
 			if err != nil {
 				return "", fmt.Errorf("获取容器镜像失败: %v", err)
 			}
 			image = strings.TrimSpace(string(output))
 		}
-		cmd = exec.Command("docker", "pull", image)
+		cmd = dockerCommand("pull", image)
 		actionDesc = "拉取镜像"
 	default:
 		return "", fmt.Errorf("不支持的操作: %s", req.Action)
 	}
 
-	log.Printf("[Docker] %s容器: %s", actionDesc, req.ContainerID)
+	logger.Infof("[Docker] %s容器: %s", actionDesc, req.ContainerID)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -809,10 +1969,45 @@ func (a *AgentClient) handleDockerAction(data string) (string, error) {
 	return fmt.Sprintf("%s成功", actionDesc), nil
 }
 
+// dockerControlDisabled 未开启 allowDockerControl 时改变容器/镜像/网络/Volume 状态的任务返回的结果
+const dockerControlDisabled = "容器操作未启用 (配置 allowDockerControl)"
+
+// validateKnownContainer 检查容器 ID (或 ID 前缀) / 名称是否在当前容器列表中
+func validateKnownContainer(ctx context.Context, container string) error {
+	cmd := dockerCommandContext(ctx, "ps", "-a", "--no-trunc", "--format", "{{.ID}}\t{{.Names}}")
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("获取容器列表失败: %v", err)
+	}
+
+	if !matchKnownContainer(string(output), container) {
+		return fmt.Errorf("容器不存在: %s", container)
+	}
+	return nil
+}
+
+// matchKnownContainer 在 "ID\t名称" 格式的容器列表中匹配完整名称或 ID 前缀
+func matchKnownContainer(list, container string) bool {
+	name := strings.TrimPrefix(container, "/")
+	for _, line := range strings.Split(strings.TrimSpace(list), "\n") {
+		id, names, _ := strings.Cut(line, "\t")
+		if id != "" && strings.HasPrefix(id, container) {
+			return true
+		}
+		for _, n := range strings.Split(names, ",") {
+			if n != "" && n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // handleDockerUpdate 处理 Docker 容器更新
 func (a *AgentClient) handleDockerUpdate(req DockerActionRequest) (string, error) {
 	// 1. 获取容器信息
-	inspectCmd := exec.Command("docker", "inspect", "--format",
+	inspectCmd := dockerCommand("inspect", "--format",
 		"{{.Config.Image}}|{{.HostConfig.RestartPolicy.Name}}|{{json .HostConfig.PortBindings}}|{{json .Config.Env}}|{{json .HostConfig.Binds}}|{{.Name}}",
 		req.ContainerID)
 	output, err := inspectCmd.Output()
@@ -828,21 +2023,21 @@ func (a *AgentClient) handleDockerUpdate(req DockerActionRequest) (string, error
 	image := parts[0]
 	containerName := strings.TrimPrefix(parts[5], "/")
 
-	log.Printf("[Docker] 更新容器: %s (镜像: %s)", containerName, image)
+	logger.Infof("[Docker] 更新容器: %s (镜像: %s)", containerName, image)
 
 	// 2. 拉取最新镜像
-	pullCmd := exec.Command("docker", "pull", image)
+	pullCmd := dockerCommand("pull", image)
 	if pullOutput, err := pullCmd.CombinedOutput(); err != nil {
 		return "", fmt.Errorf("拉取镜像失败: %s", string(pullOutput))
 	}
 
 	// 3. 停止旧容器
-	stopCmd := exec.Command("docker", "stop", req.ContainerID)
+	stopCmd := dockerCommand("stop", req.ContainerID)
 	stopCmd.Run()
 
 	// 4. 重命名旧容器 (备份)
 	backupName := containerName + "_backup_" + time.Now().Format("20060102150405")
-	renameCmd := exec.Command("docker", "rename", req.ContainerID, backupName)
+	renameCmd := dockerCommand("rename", req.ContainerID, backupName)
 	renameCmd.Run()
 
 	// 5. 使用相同配置启动新容器
@@ -855,17 +2050,17 @@ func (a *AgentClient) handleDockerUpdate(req DockerActionRequest) (string, error
 	}
 
 	runArgs = append(runArgs, image)
-	
-	runCmd := exec.Command("docker", runArgs...)
+
+	runCmd := dockerCommand(runArgs...)
 	if runOutput, err := runCmd.CombinedOutput(); err != nil {
 		// 恢复旧容器
-		exec.Command("docker", "rename", backupName, containerName).Run()
-		exec.Command("docker", "start", containerName).Run()
+		dockerCommand("rename", backupName, containerName).Run()
+		dockerCommand("start", containerName).Run()
 		return "", fmt.Errorf("启动新容器失败: %s", string(runOutput))
 	}
 
 	// 6. 删除备份容器
-	exec.Command("docker", "rm", backupName).Run()
+	dockerCommand("rm", backupName).Run()
 
 	return fmt.Sprintf("容器 %s 更新成功", containerName), nil
 }
@@ -887,7 +2082,7 @@ type DockerImageUpdateStatus struct {
 }
 
 // handleDockerCheckUpdate 处理 Docker 镜像更新检测
-func (a *AgentClient) handleDockerCheckUpdate(data string) (string, error) {
+func (a *AgentClient) handleDockerCheckUpdate(ctx context.Context, data string) (string, error) {
 	var req DockerCheckUpdateRequest
 	if data != "" {
 		json.Unmarshal([]byte(data), &req)
@@ -897,10 +2092,13 @@ func (a *AgentClient) handleDockerCheckUpdate(data string) (string, error) {
 
 	if req.ContainerID != "" {
 		// 检查指定容器
+		if err := validateKnownContainer(ctx, req.ContainerID); err != nil {
+			return "", err
+		}
 		containers = []string{req.ContainerID}
 	} else {
 		// 获取所有运行中的容器
-		cmd := exec.Command("docker", "ps", "-q")
+		cmd := dockerCommand("ps", "-q")
 		output, err := cmd.Output()
 		if err != nil {
 			return "", fmt.Errorf("获取容器列表失败: %v", err)
@@ -935,7 +2133,7 @@ func (a *AgentClient) checkContainerImageUpdate(containerID string) DockerImageU
 	}
 
 	// 1. 获取容器信息 (Name 和 Image)
-	inspectCmd := exec.Command("docker", "inspect", "--format",
+	inspectCmd := dockerCommand("inspect", "--format",
 		"{{.Name}}|{{.Config.Image}}",
 		containerID)
 	output, err := inspectCmd.Output()
@@ -955,7 +2153,7 @@ func (a *AgentClient) checkContainerImageUpdate(containerID string) DockerImageU
 
 	// 2. 从镜像获取本地 Digest
 	localDigest := ""
-	imgInspect := exec.Command("docker", "image", "inspect", "--format",
+	imgInspect := dockerCommand("image", "inspect", "--format",
 		"{{index .RepoDigests 0}}", status.Image)
 	imgOutput, err := imgInspect.Output()
 	if err == nil && strings.TrimSpace(string(imgOutput)) != "" && strings.TrimSpace(string(imgOutput)) != "<no value>" {
@@ -1048,7 +2246,7 @@ func getRemoteDigest(registry, repo, tag string) (string, error) {
 			return digest, nil
 		}
 		lastErr = err
-		log.Printf("[Docker] 尝试 %s 失败: %v, 切换下一个", host, err)
+		logger.Warnf("[Docker] 尝试 %s 失败: %v, 切换下一个", host, err)
 	}
 
 	return "", fmt.Errorf("所有镜像源均失败: %v", lastErr)
@@ -1140,8 +2338,8 @@ func getBearerToken(wwwAuth, repo string, client *http.Client) (string, error) {
 
 	// 构建 token URL
 	tokenURL := fmt.Sprintf("%s?service=%s&scope=repository:%s:pull", realm, service, repo)
-	log.Printf("[Docker] Token URL: %s", tokenURL)
-	
+	logger.Debugf("[Docker] Token URL: %s", tokenURL)
+
 	resp, err := client.Get(tokenURL)
 	if err != nil {
 		return "", fmt.Errorf("token 请求失败: %v", err)
@@ -1181,7 +2379,7 @@ type DockerImage struct {
 
 // handleDockerImages 列出 Docker 镜像
 func (a *AgentClient) handleDockerImages(data string) (string, error) {
-	cmd := exec.Command("docker", "images", "--format", "{{.ID}}|{{.Repository}}|{{.Tag}}|{{.Size}}|{{.CreatedSince}}")
+	cmd := dockerCommand("images", "--format", "{{.ID}}|{{.Repository}}|{{.Tag}}|{{.Size}}|{{.CreatedSince}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("获取镜像列表失败: %v", err)
@@ -1230,16 +2428,16 @@ func (a *AgentClient) handleDockerImageAction(data string) (string, error) {
 		if req.Image == "" {
 			return "", fmt.Errorf("缺少镜像名")
 		}
-		cmd = exec.Command("docker", "pull", req.Image)
+		cmd = dockerCommand("pull", req.Image)
 		actionDesc = "拉取镜像"
 	case "remove":
 		if req.Image == "" {
 			return "", fmt.Errorf("缺少镜像 ID")
 		}
-		cmd = exec.Command("docker", "rmi", req.Image)
+		cmd = dockerCommand("rmi", req.Image)
 		actionDesc = "删除镜像"
 	case "prune":
-		cmd = exec.Command("docker", "image", "prune", "-f")
+		cmd = dockerCommand("image", "prune", "-f")
 		actionDesc = "清理未使用镜像"
 	default:
 		return "", fmt.Errorf("不支持的操作: %s", req.Action)
@@ -1267,7 +2465,7 @@ type DockerNetwork struct {
 
 // handleDockerNetworks 列出 Docker 网络
 func (a *AgentClient) handleDockerNetworks(data string) (string, error) {
-	cmd := exec.Command("docker", "network", "ls", "--format", "{{.ID}}|{{.Name}}|{{.Driver}}|{{.Scope}}")
+	cmd := dockerCommand("network", "ls", "--format", "{{.ID}}|{{.Name}}|{{.Driver}}|{{.Scope}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("获取网络列表失败: %v", err)
@@ -1289,7 +2487,7 @@ func (a *AgentClient) handleDockerNetworks(data string) (string, error) {
 			}
 
 			// 获取网络详情 (子网和网关)
-			inspectCmd := exec.Command("docker", "network", "inspect", parts[0], "--format", "{{range .IPAM.Config}}{{.Subnet}}|{{.Gateway}}{{end}}")
+			inspectCmd := dockerCommand("network", "inspect", parts[0], "--format", "{{range .IPAM.Config}}{{.Subnet}}|{{.Gateway}}{{end}}")
 			inspectOut, _ := inspectCmd.Output()
 			if inspectParts := strings.SplitN(strings.TrimSpace(string(inspectOut)), "|", 2); len(inspectParts) >= 2 {
 				network.Subnet = inspectParts[0]
@@ -1340,25 +2538,25 @@ func (a *AgentClient) handleDockerNetworkAction(data string) (string, error) {
 			args = append(args, "--gateway", req.Gateway)
 		}
 		args = append(args, req.Name)
-		cmd = exec.Command("docker", args...)
+		cmd = dockerCommand(args...)
 		actionDesc = "创建网络"
 	case "remove":
 		if req.Name == "" {
 			return "", fmt.Errorf("缺少网络名")
 		}
-		cmd = exec.Command("docker", "network", "rm", req.Name)
+		cmd = dockerCommand("network", "rm", req.Name)
 		actionDesc = "删除网络"
 	case "connect":
 		if req.Name == "" || req.Container == "" {
 			return "", fmt.Errorf("缺少网络名或容器 ID")
 		}
-		cmd = exec.Command("docker", "network", "connect", req.Name, req.Container)
+		cmd = dockerCommand("network", "connect", req.Name, req.Container)
 		actionDesc = "连接容器到网络"
 	case "disconnect":
 		if req.Name == "" || req.Container == "" {
 			return "", fmt.Errorf("缺少网络名或容器 ID")
 		}
-		cmd = exec.Command("docker", "network", "disconnect", req.Name, req.Container)
+		cmd = dockerCommand("network", "disconnect", req.Name, req.Container)
 		actionDesc = "断开容器与网络"
 	default:
 		return "", fmt.Errorf("不支持的操作: %s", req.Action)
@@ -1384,7 +2582,7 @@ type DockerVolume struct {
 
 // handleDockerVolumes 列出 Docker Volumes
 func (a *AgentClient) handleDockerVolumes(data string) (string, error) {
-	cmd := exec.Command("docker", "volume", "ls", "--format", "{{.Name}}|{{.Driver}}|{{.Mountpoint}}")
+	cmd := dockerCommand("volume", "ls", "--format", "{{.Name}}|{{.Driver}}|{{.Mountpoint}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("获取 Volume 列表失败: %v", err)
@@ -1437,16 +2635,16 @@ func (a *AgentClient) handleDockerVolumeAction(data string) (string, error) {
 			args = append(args, "--driver", req.Driver)
 		}
 		args = append(args, req.Name)
-		cmd = exec.Command("docker", args...)
+		cmd = dockerCommand(args...)
 		actionDesc = "创建 Volume"
 	case "remove":
 		if req.Name == "" {
 			return "", fmt.Errorf("缺少 Volume 名")
 		}
-		cmd = exec.Command("docker", "volume", "rm", req.Name)
+		cmd = dockerCommand("volume", "rm", req.Name)
 		actionDesc = "删除 Volume"
 	case "prune":
-		cmd = exec.Command("docker", "volume", "prune", "-f")
+		cmd = dockerCommand("volume", "prune", "-f")
 		actionDesc = "清理未使用 Volume"
 	default:
 		return "", fmt.Errorf("不支持的操作: %s", req.Action)
@@ -1470,7 +2668,7 @@ type DockerLogsRequest struct {
 }
 
 // handleDockerLogs 获取容器日志
-func (a *AgentClient) handleDockerLogs(data string) (string, error) {
+func (a *AgentClient) handleDockerLogs(ctx context.Context, data string) (string, error) {
 	var req DockerLogsRequest
 	if err := json.Unmarshal([]byte(data), &req); err != nil {
 		return "", fmt.Errorf("解析请求失败: %v", err)
@@ -1479,6 +2677,9 @@ func (a *AgentClient) handleDockerLogs(data string) (string, error) {
 	if req.ContainerID == "" {
 		return "", fmt.Errorf("缺少容器 ID")
 	}
+	if err := validateKnownContainer(ctx, req.ContainerID); err != nil {
+		return "", err
+	}
 
 	args := []string{"logs"}
 	if req.Tail > 0 {
@@ -1491,7 +2692,7 @@ func (a *AgentClient) handleDockerLogs(data string) (string, error) {
 	}
 	args = append(args, req.ContainerID)
 
-	cmd := exec.Command("docker", args...)
+	cmd := dockerCommand(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("获取日志失败: %s", string(output))
@@ -1516,7 +2717,7 @@ type DockerContainerStats struct {
 // handleDockerStats 获取容器资源统计
 func (a *AgentClient) handleDockerStats(data string) (string, error) {
 	// 获取所有运行中容器的资源统计 (非阻塞模式)
-	cmd := exec.Command("docker", "stats", "--no-stream", "--format",
+	cmd := dockerCommand("stats", "--no-stream", "--format",
 		"{{.ID}}|{{.Name}}|{{.CPUPerc}}|{{.MemUsage}}|{{.MemPerc}}|{{.NetIO}}|{{.BlockIO}}")
 	output, err := cmd.Output()
 	if err != nil {
@@ -1559,7 +2760,7 @@ type DockerComposeProject struct {
 // handleDockerComposeList 列出 Docker Compose 项目
 func (a *AgentClient) handleDockerComposeList(data string) (string, error) {
 	// 使用 docker compose ls 命令列出所有项目
-	cmd := exec.Command("docker", "compose", "ls", "--format", "json")
+	cmd := dockerCommand("compose", "ls", "--format", "json")
 	output, err := cmd.Output()
 	if err != nil {
 		// 尝试使用 docker-compose (旧版)
@@ -1617,7 +2818,7 @@ func (a *AgentClient) handleDockerComposeAction(data string) (string, error) {
 		return "", fmt.Errorf("不支持的操作: %s", req.Action)
 	}
 
-	cmd := exec.Command("docker", args...)
+	cmd := dockerCommand(args...)
 	if req.ConfigDir != "" {
 		cmd.Dir = req.ConfigDir
 	}
@@ -1700,7 +2901,7 @@ func (a *AgentClient) handleDockerCreateContainer(data string) (string, error) {
 	// 最后添加镜像名
 	args = append(args, req.Image)
 
-	cmd := exec.Command("docker", args...)
+	cmd := dockerCommand(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("创建容器失败: %s", string(output))
@@ -1710,42 +2911,111 @@ func (a *AgentClient) handleDockerCreateContainer(data string) (string, error) {
 	return fmt.Sprintf("容器创建成功\nID: %s", containerId), nil
 }
 
+// ==================== 指标查询 ====================
+
+// MetricQueryRequest 指标查询请求
+type MetricQueryRequest struct {
+	Metric string `json:"metric"` // 指标名，如 mem, disk:/var, net:eth0, proc:1234
+}
+
+// MetricQueryResult 指标查询结果
+type MetricQueryResult struct {
+	Metric    string      `json:"metric"`
+	Data      interface{} `json:"data"`
+	Truncated bool        `json:"truncated"` // 列表结果超出消息大小限制被截断
+}
+
+// handleMetricQuery 按需查询 gopsutil 原始指标
+func (a *AgentClient) handleMetricQuery(data string) (string, error) {
+	if !a.cfg().EnableMetricQuery {
+		return "", fmt.Errorf("指标查询未启用 (enableMetricQuery)")
+	}
+
+	var req MetricQueryRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		return "", fmt.Errorf("解析请求失败: %v", err)
+	}
+	if req.Metric == "" {
+		return "", fmt.Errorf("缺少指标名")
+	}
+
+	metric, err := a.collector.QueryMetric(req.Metric)
+	if err != nil {
+		return "", fmt.Errorf("查询指标失败: %v", err)
+	}
+
+	return encodeMetricQueryResult(MetricQueryResult{Metric: req.Metric, Data: metric})
+}
+
+// encodeMetricQueryResult 编码指标查询结果
+func encodeMetricQueryResult(result MetricQueryResult) (string, error) {
+	metric := result.Data
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	// 列表结果 (如完整进程列表) 超出大小限制时二分截断
+	// 结果以字符串形式放在任务结果的 data 中，按转义后的大小判断
+	if !taskDataFits(string(jsonResult)) {
+		value := reflect.ValueOf(metric)
+		if value.Kind() != reflect.Slice {
+			return "", fmt.Errorf("结果过大 (%d 字节)，请缩小查询范围", len(jsonResult))
+		}
+		lo, hi := 0, value.Len()
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			result.Data = value.Slice(0, mid).Interface()
+			if out, _ := json.Marshal(result); taskDataFits(string(out)) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		result.Data = value.Slice(0, lo).Interface()
+		result.Truncated = true
+		jsonResult, _ = json.Marshal(result)
+	}
+
+	return string(jsonResult), nil
+}
+
 // handleUpgrade 执行 Agent 自我升级
 func (a *AgentClient) handleUpgrade(taskId string) {
 	// 稍微延迟，确保 Ack 消息先发送出去
 	time.Sleep(1 * time.Second)
 
-	log.Printf("[Upgrade] 开始执行升级流程...")
+	logger.Infof("[Upgrade] 开始执行升级流程...")
 
 	var cmd *exec.Cmd
 
 	if runtime.GOOS == "windows" {
 		// Windows: 使用 PowerShell 下载并执行脚本
-		installUrl := fmt.Sprintf("%s/api/server/agent/install/win/%s", a.config.ServerURL, a.config.ServerID)
+		installUrl := fmt.Sprintf("%s/api/server/agent/install/win/%s", a.currentServerURL(), a.cfg().ServerID)
 		psCommand := fmt.Sprintf("irm %s | iex", installUrl)
-		
+
 		// 使用 Start-Process 启动一个独立的 PowerShell 窗口执行升级，确保不会因为 Agent 停止而被杀掉
 		// 注意：服务中运行已经有 System 权限，不需要 (也不能) 使用 RunAs，否则 Session 0 会失败
 		cmd = exec.Command("powershell", "-Command", "Start-Process", "powershell", "-ArgumentList", fmt.Sprintf("'-NoProfile -ExecutionPolicy Bypass -Command \"%s\"'", psCommand), "-WindowStyle", "Hidden")
 	} else {
 		// Linux/MacOS: 使用 curl | bash
-		installUrl := fmt.Sprintf("%s/api/server/agent/install/linux/%s", a.config.ServerURL, a.config.ServerID)
+		installUrl := fmt.Sprintf("%s/api/server/agent/install/linux/%s", a.currentServerURL(), a.cfg().ServerID)
 		shellCommand := fmt.Sprintf("curl -fsSL %s | sudo bash", installUrl)
-		
+
 		// 使用 nohup 后台执行
 		cmd = exec.Command("sh", "-c", fmt.Sprintf("nohup sh -c '%s' > /tmp/agent_upgrade.log 2>&1 &", shellCommand))
 	}
 
 	if err := cmd.Start(); err != nil {
-		log.Printf("[Upgrade] 启动升级进程失败: %v", err)
+		logger.Warnf("[Upgrade] 启动升级进程失败: %v", err)
 	} else {
-		log.Printf("[Upgrade] 升级进程已启动，Agent 即将重启...")
+		logger.Infof("[Upgrade] 升级进程已启动，Agent 即将重启...")
 	}
 }
 
 // handlePTYTask 处理 PTY 任务
 func (a *AgentClient) handlePTYTask(taskId string, data string) {
-	log.Printf("[Agent] 启动 PTY 会话: %s", taskId)
+	logger.Infof("[Agent] 启动 PTY 会话: %s", taskId)
 
 	// 解析初始尺寸
 	var resize PTYResizeData
@@ -1761,24 +3031,44 @@ func (a *AgentClient) handlePTYTask(taskId string, data string) {
 	}
 
 	// 启动 PTY
-	pty, err := StartPTY(resize.Cols, resize.Rows)
+	pty, err := StartPTY(resize.Cols, resize.Rows, ptyOptionsFromConfig(a.cfg()))
 	if err != nil {
-		log.Printf("[Agent] 启动 PTY 失败: %v", err)
+		logger.Warnf("[Agent] 启动 PTY 失败: %v", err)
 		return
 	}
 
+	// 会话录像 (录像失败不影响终端本身)
+	if a.cfg().PTYRecordDir != "" {
+		if recorder, err := newRecordingPty(pty, a.cfg().PTYRecordDir, taskId, resize.Cols, resize.Rows, a.cfg().PTYRecordInput); err != nil {
+			logger.Warnf("[Agent] PTY 会话录像失败: %v", err)
+		} else {
+			pty = recorder
+		}
+	}
+
 	// 注册会话
 	a.mu.Lock()
 	a.ptySessions[taskId] = pty
+	a.ptyActivity[taskId] = time.Now()
 	a.mu.Unlock()
 
 	// 清理函数
 	defer func() {
 		a.mu.Lock()
+		reason, ok := a.ptyCloseReasons[taskId]
+		if !ok {
+			reason = "exited" // Shell 自行退出
+		}
 		delete(a.ptySessions, taskId)
+		delete(a.ptyActivity, taskId)
+		delete(a.ptyCloseReasons, taskId)
 		a.mu.Unlock()
 		pty.Close()
-		log.Printf("[Agent] PTY 会话已关闭: %s", taskId)
+		a.emit(EventAgentPtyClosed, map[string]interface{}{
+			"id":     taskId,
+			"reason": reason,
+		})
+		logger.Infof("[Agent] PTY 会话已关闭: %s (%s)", taskId, reason)
 	}()
 
 	// 读取 PTY 输出并发送到服务器
@@ -1786,9 +3076,10 @@ func (a *AgentClient) handlePTYTask(taskId string, data string) {
 	for {
 		n, err := pty.Read(buf)
 		if n > 0 {
-			if a.config.Debug {
-				log.Printf("[Agent] PTY 读取到数据: %d 字节", n)
-			}
+			a.mu.Lock()
+			a.ptyActivity[taskId] = time.Now()
+			a.mu.Unlock()
+			logger.Debugf("[Agent] PTY 读取到数据: %d 字节", n)
 			// 发送实时数据
 			a.emit(EventAgentPtyData, map[string]interface{}{
 				"id":   taskId,
@@ -1797,15 +3088,94 @@ func (a *AgentClient) handlePTYTask(taskId string, data string) {
 		}
 		if err != nil {
 			if err != io.EOF {
-				log.Printf("[Agent] PTY 读取错误: %v", err)
+				logger.Warnf("[Agent] PTY 读取错误: %v", err)
 			}
 			break
 		}
 	}
 }
 
+// closePTYSession 主动关闭终端会话，reason 会随 agent:pty_closed 事件上报
+// 关闭后 handlePTYTask 的读取循环结束，由其负责清理会话
+func (a *AgentClient) closePTYSession(id, reason string) {
+	a.mu.Lock()
+	pty, ok := a.ptySessions[id]
+	if ok {
+		a.ptyCloseReasons[id] = reason
+	}
+	a.mu.Unlock()
+	if ok {
+		pty.Close()
+	}
+}
+
+// watchIdlePTYs 定期关闭超过 ptyIdleTimeout 没有输入输出的终端会话
+func (a *AgentClient) watchIdlePTYs() {
+	idleTimeout := time.Duration(a.cfg().PTYIdleTimeout) * time.Second
+	interval := idleTimeout / 4
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		var idle []string
+		a.mu.Lock()
+		for id, last := range a.ptyActivity {
+			if time.Since(last) > idleTimeout {
+				idle = append(idle, id)
+			}
+		}
+		a.mu.Unlock()
+
+		for _, id := range idle {
+			logger.Warnf("[Agent] PTY 会话空闲超时，正在关闭: %s", id)
+			a.closePTYSession(id, "idle_timeout")
+		}
+	}
+}
+
 // Stop 停止 Agent
 func (a *AgentClient) Stop() {
+	a.StopWithReason("shutdown")
+}
+
+// StopWithReason 关闭 Agent，关闭连接前先尽力上报最后一次状态并通知服务端退出原因，
+// 面板可以立即显示离线，而不是等心跳超时
+func (a *AgentClient) StopWithReason(reason string) {
+	a.stop(reason)
+}
+
+// isStopping 是否已经开始关闭
+func (a *AgentClient) isStopping() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopping
+}
+
+// stop 执行关闭流程，已有关闭 (信号、重启任务或自更新) 在进行时返回 false
+// 重启和自更新只有在本次调用完成关闭后才能替换进程
+func (a *AgentClient) stop(reason string) bool {
+	a.mu.Lock()
+	if a.stopping {
+		a.mu.Unlock()
+		return false
+	}
+	a.stopping = true
+	a.mu.Unlock()
+
+	a.flushFinalState(reason)
 	close(a.stopChan)
 
 	a.mu.Lock()
@@ -1819,7 +3189,11 @@ func (a *AgentClient) Stop() {
 	}
 	a.mu.Unlock()
 
-	log.Println("[Agent] 已关闭")
+	a.stopStatusServer()
+	a.pidFile.release()
+
+	logger.Infof("[Agent] 已关闭")
+	return true
 }
 
 // ==================== 主程序 ====================
@@ -1832,60 +3206,52 @@ func main() {
 	}
 
 	// 检查服务管理命令
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "install":
-			if err := InstallService(); err != nil {
-				fmt.Println("❌ 安装失败:", err)
-				os.Exit(1)
-			}
-			return
-		case "uninstall", "remove":
-			if err := UninstallService(); err != nil {
-				fmt.Println("❌ 卸载失败:", err)
-				os.Exit(1)
-			}
-			return
-		case "start":
-			if err := StartService(); err != nil {
-				fmt.Println("❌ 启动失败:", err)
-				os.Exit(1)
-			}
-			return
-		case "stop":
-			if err := StopService(); err != nil {
-				fmt.Println("❌ 停止失败:", err)
-				os.Exit(1)
-			}
-			return
-		case "service":
-			// 直接以服务模式运行（由 Windows SCM 调用）
-			RunAsService()
-			return
-		case "help", "-h", "--help":
-			printUsage()
-			return
-		}
+	if len(os.Args) > 1 && runServiceCommand(os.Args[1]) {
+		return
+	}
+
+	// validate 子命令等价于 --check，其余参数照常解析
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Args = append([]string{os.Args[0], "--check"}, os.Args[2:]...)
 	}
 
 	// 命令行参数
 	serverURL := flag.String("s", "", "Dashboard 地址")
 	serverID := flag.String("id", "", "主机 ID")
 	agentKey := flag.String("k", "", "Agent 密钥")
-	interval := flag.Int("i", 1500, "上报间隔 (毫秒)")
+	interval := flag.Int("i", 0, "上报间隔 (毫秒，默认 1500)")
 	debug := flag.Bool("d", false, "调试模式")
 	background := flag.Bool("b", false, "后台模式 (隐藏控制台窗口)")
+	once := flag.Bool("once", false, "采集一次并以 JSON 输出到标准输出后退出 (不连接服务器)")
+	noReport := flag.Bool("no-report", false, "只建立连接并完成认证，不上报主机信息和实时状态 (仍响应心跳和任务)")
+	configFile := flag.String("config", "", "配置文件路径 (默认为程序同目录下的 config.json)")
+	flag.StringVar(configFile, "c", "", "配置文件路径 (默认为程序同目录下的 config.json)")
+	showVersion := flag.Bool("version", false, "显示版本信息后退出")
+	flag.BoolVar(showVersion, "v", false, "显示版本信息后退出")
+	check := flag.Bool("check", false, "校验配置并输出合并后的有效配置后退出 (不连接服务器)")
+	tags := tagFlags{}
+	flag.Var(tags, "tag", "主机标签 key=value，可重复指定，覆盖配置文件中的同名标签")
 	flag.Parse()
 
-	// 初始化日志文件 (无论是否后台模式)
-	exePath, _ := os.Executable()
-	logPath := filepath.Join(filepath.Dir(exePath), "agent.log")
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
+	if *showVersion {
+		printVersion()
+		return
+	}
+
+	// 初始化日志文件 (无论是否后台模式；校验配置时只输出到标准错误，不写日志文件)
+	logPath := defaultLogPath()
+	if *check {
+		log.SetOutput(os.Stderr)
+	} else if logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666); err == nil {
 		// 同时输出到文件和控制台 (如果是服务模式，控制台不可见，但这没关系)
-		log.SetOutput(io.MultiWriter(os.Stdout, logFile))
-		log.Println("==================================================")
-		log.Printf("[Agent] 启动时间: %s", time.Now().Format(time.RFC3339))
+		// 单次模式下标准输出只保留 JSON 结果，日志改为输出到标准错误
+		console := io.Writer(os.Stdout)
+		if *once {
+			console = os.Stderr
+		}
+		log.SetOutput(io.MultiWriter(console, logFile))
+		logger.Infof("==================================================")
+		logger.Infof("[Agent] 启动时间: %s", time.Now().Format(time.RFC3339))
 	} else {
 		fmt.Printf("无法创建日志文件: %v\n", err)
 	}
@@ -1896,54 +3262,95 @@ func main() {
 	}
 
 	// 加载配置
-	config := &Config{
-		ServerURL:        "http://localhost:3000",
-		ReportInterval:   1500,
-		HostInfoInterval: 600000,
-		ReconnectDelay:   4000,
-	}
+	config := newDefaultConfig()
 
-	// 从配置文件加载（使用可执行文件所在目录）
-	configPath := filepath.Join(filepath.Dir(exePath), "config.json")
-	if data, err := os.ReadFile(configPath); err == nil {
-		json.Unmarshal(data, config)
-		log.Println("[Config] 已加载配置文件:", configPath)
+	// 从配置文件加载 (显式指定的文件必须存在且格式正确，默认文件不存在时忽略)
+	configPath := defaultConfigPath()
+	if *configFile != "" {
+		if abs, err := filepath.Abs(*configFile); err == nil {
+			configPath = abs
+		} else {
+			configPath = *configFile
+		}
+		if err := loadConfigFile(configPath, config); err != nil {
+			logger.Fatalf("[Config] 加载配置文件失败: %v", err)
+		}
+		logger.Infof("[Config] 已加载配置文件: %v", configPath)
+	} else if err := loadConfigFile(configPath, config); err == nil {
+		logger.Infof("[Config] 已加载配置文件: %v", configPath)
+	} else if !os.IsNotExist(err) {
+		logger.Warnf("[Config] %v", err)
 	}
 
-	// 环境变量覆盖
-	if env := os.Getenv("API_MONITOR_SERVER"); env != "" {
-		config.ServerURL = env
-	}
-	if env := os.Getenv("API_MONITOR_SERVER_ID"); env != "" {
-		config.ServerID = env
-	}
-	if env := os.Getenv("API_MONITOR_KEY"); env != "" {
-		config.AgentKey = env
-	}
+	// 环境变量和命令行参数覆盖 (启动和重新加载配置时使用同一套规则)
+	// 通过环境变量或 -s 指定地址时只使用该地址，不再读取配置文件中的 serverUrls
+	applyOverrides := func(config *Config) {
+		if env := os.Getenv("API_MONITOR_SERVER"); env != "" {
+			config.ServerURL = env
+			config.ServerURLs = nil
+		}
+		if env := os.Getenv("API_MONITOR_SERVER_ID"); env != "" {
+			config.ServerID = env
+		}
+		if env := os.Getenv("API_MONITOR_KEY"); env != "" {
+			config.AgentKey = env
+		}
+		if env := os.Getenv("API_MONITOR_HOSTNAME"); env != "" {
+			config.Hostname = env
+		}
 
-	// 命令行参数覆盖
-	if *serverURL != "" {
-		config.ServerURL = *serverURL
-	}
-	if *serverID != "" {
-		config.ServerID = *serverID
-	}
-	if *agentKey != "" {
-		config.AgentKey = *agentKey
+		if *serverURL != "" {
+			config.ServerURL = *serverURL
+			config.ServerURLs = nil
+		}
+		if *serverID != "" {
+			config.ServerID = *serverID
+		}
+		if *agentKey != "" {
+			config.AgentKey = *agentKey
+		}
+		if *interval > 0 {
+			config.ReportInterval = *interval
+		}
+		if *debug {
+			config.Debug = true
+		}
+		if *noReport {
+			config.NoReport = true
+		}
+		if len(tags) > 0 {
+			merged := make(map[string]string, len(config.Tags)+len(tags))
+			for k, v := range config.Tags {
+				merged[k] = v
+			}
+			for k, v := range tags {
+				merged[k] = v
+			}
+			config.Tags = merged
+		}
 	}
-	if *interval > 0 {
-		config.ReportInterval = *interval
+	applyOverrides(config)
+	logger.Configure(config)
+
+	// 单次模式: 只采集并输出，不需要 serverId/agentKey
+	if *once {
+		if err := runOnce(config); err != nil {
+			logger.Fatalf("[Agent] 采集结果输出失败: %v", err)
+		}
+		return
 	}
-	if *debug {
-		config.Debug = true
+
+	// 校验模式: 输出合并后的有效配置和校验结果，不连接服务器
+	if *check {
+		os.Exit(runConfigCheck(config, configPath))
 	}
 
 	// 验证配置
-	if config.ServerID == "" {
-		log.Fatal("[Config] 错误: 缺少 serverId，使用 --id 指定")
-	}
-	if config.AgentKey == "" {
-		log.Fatal("[Config] 错误: 缺少 agentKey，使用 -k 指定")
+	if errs := validateConfig(config); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Errorf("[Config] 错误: %v", err)
+		}
+		os.Exit(1)
 	}
 
 	// 创建并启动 Agent
@@ -1953,11 +3360,35 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP: 重新读取配置文件，热更新上报间隔、日志和采集开关 (不断开连接)
+	reloadChan := make(chan os.Signal, 1)
+	notifyReload(reloadChan)
+
 	go func() {
-		<-sigChan
-		log.Println("\n[Agent] 收到退出信号...")
-		agent.Stop()
-		os.Exit(0)
+		for {
+			select {
+			case <-reloadChan:
+				logger.Infof("[Config] 收到 SIGHUP，重新加载配置: %s", configPath)
+				next := newDefaultConfig()
+				if err := loadConfigFile(configPath, next); err != nil {
+					logger.Errorf("[Config] 重新加载失败，保留当前配置: %v", err)
+					continue
+				}
+				applyOverrides(next)
+				agent.ReloadConfig(next)
+			case sig := <-sigChan:
+				reason := shutdownReason(sig)
+				logger.Infof("[Agent] 收到 %s 信号，正在退出 (%s)...", sig, reason)
+				// 退出过程中再次收到信号时立即退出
+				go func() {
+					sig := <-sigChan
+					logger.Warnf("[Agent] 再次收到 %s 信号，立即退出", sig)
+					os.Exit(1)
+				}()
+				agent.StopWithTimeout(reason)
+				os.Exit(0)
+			}
+		}
 	}()
 
 	agent.Start()
@@ -1971,6 +3402,72 @@ func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 }
 
+// runServiceCommand 执行服务管理子命令，返回 false 表示不是子命令 (继续按普通参数解析)
+func runServiceCommand(command string) bool {
+	var err error
+	var action string
+	switch command {
+	case "install":
+		// install -c <path>: 服务启动时使用指定的配置文件
+		configPath := configPathFromArgs(os.Args[2:])
+		if configPath != "" {
+			if abs, absErr := filepath.Abs(configPath); absErr == nil {
+				configPath = abs
+			}
+			if err = loadConfigFile(configPath, newDefaultConfig()); err != nil {
+				fmt.Printf("❌ 配置文件无效: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		action, err = "安装", InstallService(configPath)
+	case "uninstall", "remove":
+		action, err = "卸载", UninstallService()
+	case "start":
+		action, err = "启动", StartService()
+	case "stop":
+		action, err = "停止", StopService()
+	case "service", "--service", "-service":
+		// 直接以服务模式运行（由 Windows SCM 调用）
+		RunAsService()
+		return true
+	case "help", "-h", "--help":
+		printUsage()
+		return true
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Printf("❌ %s失败: %v\n", action, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// runOnce 采集一次主机信息和实时状态，以格式化 JSON 输出到标准输出
+func runOnce(config *Config) error {
+	collector := NewCollector(config)
+
+	// 与 Start 的预热一致: 第一次采集建立 CPU 使用率基准，间隔 1 秒后再采集
+	collector.CollectState()
+	time.Sleep(1 * time.Second)
+
+	output := struct {
+		HostInfo *HostInfo `json:"host_info"`
+		State    *State    `json:"state"`
+	}{
+		HostInfo: collector.CollectHostInfo(),
+		State:    collector.CollectState(),
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
 // printUsage 打印使用帮助
 func printUsage() {
 	fmt.Println("═══════════════════════════════════════════════")
@@ -1980,9 +3477,12 @@ func printUsage() {
 	fmt.Println("使用方法:")
 	fmt.Println("  api-monitor-agent [命令] [选项]")
 	fmt.Println()
-	fmt.Println("服务管理命令 (需要管理员权限):")
-	fmt.Println("  install     安装为 Windows 服务 (开机自启)")
-	fmt.Println("  uninstall   卸载 Windows 服务")
+	fmt.Println("命令:")
+	fmt.Println("  validate    校验配置 (配置文件 + 环境变量 + 命令行参数)，输出有效配置后退出")
+	fmt.Println()
+	fmt.Println("服务管理命令 (需要管理员/root 权限):")
+	fmt.Println("  install     安装为系统服务 (Windows 服务 / Linux systemd / macOS launchd，开机自启)")
+	fmt.Println("  uninstall   卸载系统服务")
 	fmt.Println("  start       启动服务")
 	fmt.Println("  stop        停止服务")
 	fmt.Println()
@@ -1993,15 +3493,31 @@ func printUsage() {
 	fmt.Println("  -i <ms>     上报间隔 (毫秒, 默认 1500)")
 	fmt.Println("  -d          调试模式")
 	fmt.Println("  -b          后台模式 (隐藏控制台窗口, Windows)")
+	fmt.Println("  --once      采集一次并输出 JSON 后退出 (用于验证采集，不连接服务器)")
+	fmt.Println("  -c, --config <path>  配置文件路径")
+	fmt.Println("  --check     校验配置并输出合并后的有效配置 (等同于 validate 子命令)")
+	fmt.Println("  -v, --version  显示版本信息后退出")
 	fmt.Println()
 	fmt.Println("配置文件:")
-	fmt.Println("  将 config.json 放在程序同目录下")
+	fmt.Println("  默认读取程序同目录下的 config.json，可用 -c <path> 指定")
+	fmt.Println("  安装服务时同样可指定: api-monitor-agent install -c /etc/api-monitor/config.json")
 	fmt.Println()
 	fmt.Println("示例:")
 	fmt.Println("  api-monitor-agent install           # 安装为 Windows 服务 (推荐)")
 	fmt.Println("  api-monitor-agent start             # 启动服务")
 	fmt.Println("  api-monitor-agent -b                # 后台模式运行 (隐藏窗口)")
 	fmt.Println("  api-monitor-agent -s https://xxx -id abc -k key123")
+	fmt.Println("  api-monitor-agent --once > metrics.json  # 输出一次采集结果")
+	fmt.Println("  api-monitor-agent validate -c config.json  # 部署前校验配置")
+}
+
+// printVersion 输出版本与构建信息
+func printVersion() {
+	fmt.Printf("API Monitor Agent v%s\n", VERSION)
+	fmt.Printf("  Git Commit: %s\n", GitCommit)
+	fmt.Printf("  Build Time: %s\n", BuildTime)
+	fmt.Printf("  Go Version: %s\n", runtime.Version())
+	fmt.Printf("  OS/Arch:    %s/%s\n", runtime.GOOS, runtime.GOARCH)
 }
 
 // ==================== 容器一键更新与进度跟踪 ====================
@@ -2051,7 +3567,7 @@ func (a *AgentClient) getTaskProgress(data string) (string, error) {
 }
 
 // handleDockerRenameContainer 处理容器重命名
-func (a *AgentClient) handleDockerRenameContainer(data string) (string, error) {
+func (a *AgentClient) handleDockerRenameContainer(ctx context.Context, data string) (string, error) {
 	var req DockerRenameRequest
 	if err := json.Unmarshal([]byte(data), &req); err != nil {
 		return "", fmt.Errorf("解析请求失败: %v", err)
@@ -2060,8 +3576,11 @@ func (a *AgentClient) handleDockerRenameContainer(data string) (string, error) {
 	if req.ContainerID == "" || req.NewName == "" {
 		return "", fmt.Errorf("缺少必要参数")
 	}
+	if err := validateKnownContainer(ctx, req.ContainerID); err != nil {
+		return "", err
+	}
 
-	cmd := exec.Command("docker", "rename", req.ContainerID, req.NewName)
+	cmd := dockerCommand("rename", req.ContainerID, req.NewName)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("重命名失败: %s - %v", string(output), err)
@@ -2078,6 +3597,15 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 		return
 	}
 
+	if req.ContainerID == "" {
+		a.sendTaskError(taskID, "缺少容器 ID")
+		return
+	}
+	if err := validateKnownContainer(context.Background(), req.ContainerID); err != nil {
+		a.sendTaskError(taskID, err.Error())
+		return
+	}
+
 	progress := &TaskProgress{
 		TaskID:     taskID,
 		Name:       "更新容器: " + req.ContainerName,
@@ -2091,7 +3619,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	progress.Message = "获取容器配置..."
 	a.updateProgress(taskID, progress)
 
-	inspectCmd := exec.Command("docker", "inspect", "--format", "{{json .}}", req.ContainerID)
+	inspectCmd := dockerCommand("inspect", "--format", "{{json .}}", req.ContainerID)
 	inspectOutput, err := inspectCmd.Output()
 	if err != nil {
 		a.finishWithError(taskID, progress, "获取容器配置失败: "+err.Error())
@@ -2123,7 +3651,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	progress.Message = "正在拉取镜像: " + imageName
 	a.updateProgress(taskID, progress)
 
-	pullCmd := exec.Command("docker", "pull", imageName)
+	pullCmd := dockerCommand("pull", imageName)
 	pullOutput, err := pullCmd.CombinedOutput()
 	if err != nil {
 		a.finishWithError(taskID, progress, "拉取镜像失败: "+string(pullOutput))
@@ -2140,7 +3668,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	progress.Message = "正在停止容器..."
 	a.updateProgress(taskID, progress)
 
-	stopCmd := exec.Command("docker", "stop", req.ContainerID)
+	stopCmd := dockerCommand("stop", req.ContainerID)
 	if _, err := stopCmd.CombinedOutput(); err != nil {
 		a.finishWithError(taskID, progress, "停止容器失败: "+err.Error())
 		return
@@ -2152,7 +3680,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	a.updateProgress(taskID, progress)
 
 	backupName := req.ContainerName + "-backup-" + time.Now().Format("20060102-150405")
-	renameCmd := exec.Command("docker", "rename", req.ContainerID, backupName)
+	renameCmd := dockerCommand("rename", req.ContainerID, backupName)
 	if _, err := renameCmd.CombinedOutput(); err != nil {
 		a.finishWithError(taskID, progress, "备份容器失败: "+err.Error())
 		return
@@ -2165,12 +3693,12 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 
 	// 构建 docker run 命令
 	runArgs := a.buildDockerRunArgs(containerInfo, imageName, req.ContainerName)
-	runCmd := exec.Command("docker", runArgs...)
+	runCmd := dockerCommand(runArgs...)
 	runOutput, err := runCmd.CombinedOutput()
 	if err != nil {
 		// 创建失败，恢复旧容器
-		exec.Command("docker", "rename", backupName, req.ContainerName).Run()
-		exec.Command("docker", "start", req.ContainerName).Run()
+		dockerCommand("rename", backupName, req.ContainerName).Run()
+		dockerCommand("start", req.ContainerName).Run()
 		a.finishWithError(taskID, progress, "创建新容器失败: "+string(runOutput))
 		return
 	}
@@ -2180,7 +3708,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	progress.Message = "正在清理旧容器..."
 	a.updateProgress(taskID, progress)
 
-	exec.Command("docker", "rm", backupName).Run()
+	dockerCommand("rm", backupName).Run()
 
 	// 完成
 	progress.Percentage = 100
@@ -2190,7 +3718,7 @@ func (a *AgentClient) handleDockerContainerUpdate(taskID string, data string) {
 	a.updateProgress(taskID, progress)
 
 	// 发送最终结果
-	a.emit(EventAgentTaskResult, map[string]interface{}{
+	a.sendTaskResult(map[string]interface{}{
 		"id":         taskID,
 		"successful": true,
 		"data":       "容器更新完成",
@@ -2269,7 +3797,7 @@ func (a *AgentClient) finishWithError(taskID string, progress *TaskProgress, err
 	progress.IsError = true
 	a.updateProgress(taskID, progress)
 
-	a.emit(EventAgentTaskResult, map[string]interface{}{
+	a.sendTaskResult(map[string]interface{}{
 		"id":         taskID,
 		"successful": false,
 		"data":       errMsg,
@@ -2278,7 +3806,7 @@ func (a *AgentClient) finishWithError(taskID string, progress *TaskProgress, err
 
 // sendTaskError 发送任务错误
 func (a *AgentClient) sendTaskError(taskID string, errMsg string) {
-	a.emit(EventAgentTaskResult, map[string]interface{}{
+	a.sendTaskResult(map[string]interface{}{
 		"id":         taskID,
 		"successful": false,
 		"data":       errMsg,
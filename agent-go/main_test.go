@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsExecAllowed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("路径规则按 Unix 编写")
+	}
+	tests := []struct {
+		name      string
+		program   string
+		allowlist []string
+		want      bool
+	}{
+		{"bare name", "df", []string{"df"}, true},
+		{"not listed", "rm", []string{"df"}, false},
+		{"path sharing an allowed name", "/tmp/x/df", []string{"df"}, false},
+		{"relative path sharing an allowed name", "./df", []string{"df"}, false},
+		{"exact absolute path", "/bin/df", []string{"/bin/df"}, true},
+		{"absolute path with dot segments", "/bin/../bin/df", []string{"/bin/df"}, true},
+		{"other absolute path", "/tmp/x/df", []string{"/bin/df"}, false},
+		{"bare name against absolute entry", "df", []string{"/bin/df"}, false},
+		{"empty allowlist", "df", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isExecAllowed(tt.program, tt.allowlist); got != tt.want {
+				t.Errorf("isExecAllowed(%q, %q) = %v, want %v", tt.program, tt.allowlist, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReconnectBackoff 指数退避、上限和 ±20% 抖动
+func TestReconnectBackoff(t *testing.T) {
+	base, max := time.Second, 30*time.Second
+	tests := []struct {
+		attempt int
+		r       float64
+		want    time.Duration
+	}{
+		{0, 0.5, time.Second},
+		{1, 0.5, 2 * time.Second},
+		{3, 0.5, 8 * time.Second},
+		{4, 0.5, 16 * time.Second},
+		{5, 0.5, 30 * time.Second}, // 32s 超过上限
+		{100, 0.5, 30 * time.Second},
+		{0, 0, 800 * time.Millisecond},     // 抖动下限 -20%
+		{0, 1, 1200 * time.Millisecond},    // 抖动上限 +20%
+		{100, 0, 24 * time.Second},         // 上限之后仍然有抖动，避免同时重连
+		{2, 0.75, 4400 * time.Millisecond}, // 4s * 1.1
+	}
+	for _, tt := range tests {
+		if got := reconnectBackoff(base, max, tt.attempt, tt.r); got != tt.want {
+			t.Errorf("reconnectBackoff(attempt=%d, r=%v) = %v, want %v", tt.attempt, tt.r, got, tt.want)
+		}
+	}
+
+	// 未配置或配置错误时的兜底
+	if got := reconnectBackoff(0, 0, 3, 0.5); got != time.Second {
+		t.Errorf("reconnectBackoff(base=0, max=0) = %v, want 1s", got)
+	}
+	if got := reconnectBackoff(5*time.Second, time.Second, 3, 0.5); got != 5*time.Second {
+		t.Errorf("reconnectBackoff(max < base) = %v, want 5s", got)
+	}
+}
+
+// fakeConn 记录写入的帧和是否被关闭的 agentConn
+type fakeConn struct {
+	mu     sync.Mutex
+	frames []string
+	closed bool
+}
+
+func (f *fakeConn) ReadMessage() (int, []byte, error) { return 0, nil, io.EOF }
+func (f *fakeConn) WriteMessage(_ int, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = append(f.frames, string(data))
+	return nil
+}
+func (f *fakeConn) SetReadDeadline(time.Time) error  { return nil }
+func (f *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+func (f *fakeConn) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// TestParseConnectError 各种 44/agent 帧中的错误信息和 retryAfter
+func TestParseConnectError(t *testing.T) {
+	tests := []struct {
+		frame      string
+		message    string
+		retryAfter float64
+	}{
+		{`44/agent,{"message":"invalid key"}`, "invalid key", 0},
+		{`44/agent,{"message":"rate limited","retryAfter":30}`, "rate limited", 30},
+		{`44/agent,{"message":"rate limited","data":{"retryAfter":12.5}}`, "rate limited", 12.5},
+		{`44/agent,{"message":"both","retryAfter":5,"data":{"retryAfter":60}}`, "both", 5},
+		{`44/agent,"Not authorized"`, "Not authorized", 0},
+		{`44/agent,not json`, "not json", 0},
+		{`44/agent`, "未知错误", 0},
+	}
+	for _, tt := range tests {
+		message, retryAfter := parseConnectError(tt.frame)
+		if message != tt.message || retryAfter != tt.retryAfter {
+			t.Errorf("parseConnectError(%q) = %q, %v, want %q, %v", tt.frame, message, retryAfter, tt.message, tt.retryAfter)
+		}
+	}
+}
+
+// TestHandleMessageNamespaceErrors 服务端拒绝 (44) 或断开 (41) 命名空间时关闭连接并记录原因
+func TestHandleMessageNamespaceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		frame      string
+		reason     string
+		retryAfter time.Duration
+	}{
+		{"connect_error", `44/agent,{"message":"invalid key"}`, "connect_error: invalid key", 0},
+		{"connect_error retryAfter", `44/agent,{"message":"busy","data":{"retryAfter":30}}`, "connect_error: busy", 30 * time.Second},
+		{"connect_error retryAfter 上限", `44/agent,{"message":"busy","retryAfter":86400}`, "connect_error: busy", maxServerRetryAfter},
+		{"disconnect", `41/agent`, "server_disconnect", 0},
+		{"disconnect 带逗号", `41/agent,`, "server_disconnect", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newDefaultConfig()
+			config.ServerID = "test"
+			config.AgentKey = "test"
+			a := NewAgentClient(config)
+			conn := &fakeConn{}
+			a.conn = conn
+			a.authenticated = true
+
+			a.handleMessage(tt.frame)
+
+			if !conn.closed {
+				t.Errorf("连接未关闭")
+			}
+			if a.authenticated {
+				t.Errorf("authenticated 仍为 true")
+			}
+			if a.dropReason != tt.reason {
+				t.Errorf("dropReason = %q, want %q", a.dropReason, tt.reason)
+			}
+			if a.retryAfter != tt.retryAfter {
+				t.Errorf("retryAfter = %v, want %v", a.retryAfter, tt.retryAfter)
+			}
+		})
+	}
+
+	// 命名空间确认不影响连接
+	a := NewAgentClient(newDefaultConfig())
+	conn := &fakeConn{}
+	a.conn = conn
+	a.handleMessage(`40/agent,{"sid":"abc"}`)
+	if conn.closed || a.dropReason != "" {
+		t.Errorf("40/agent 关闭了连接: closed = %v, dropReason = %q", conn.closed, a.dropReason)
+	}
+}
+
+// TestDockerCommandUsesPodman 只安装 podman 时容器控制任务使用 podman
+func TestDockerCommandUsesPodman(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 Unix 可执行文件模拟 podman 命令")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "args.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + logFile + "\nprintf 'abc123def456\\tweb\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "podman"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	if err := validateKnownContainer(context.Background(), "web"); err != nil {
+		t.Fatalf("validateKnownContainer() error = %v", err)
+	}
+	if err := validateKnownContainer(context.Background(), "db"); err == nil {
+		t.Errorf("validateKnownContainer(db) 未返回错误")
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("podman 未被调用: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "ps -a") {
+		t.Errorf("podman 参数 = %q", data)
+	}
+
+	// 都没有安装时使用 docker，执行时报告未找到命令
+	t.Setenv("PATH", t.TempDir())
+	if cmd := dockerCommand("ps"); filepath.Base(cmd.Path) != "docker" {
+		t.Errorf("dockerCommand().Path = %q, want docker", cmd.Path)
+	}
+}
+
+// TestStopWithReasonTwice 重复或并发的关闭请求 (重启任务、SIGTERM、自更新) 不会重复 close(stopChan)
+func TestStopWithReasonTwice(t *testing.T) {
+	a := NewAgentClient(newDefaultConfig())
+
+	var wg sync.WaitGroup
+	for _, reason := range []string{"restart", "SIGTERM", "shutdown"} {
+		wg.Add(1)
+		go func(reason string) {
+			defer wg.Done()
+			a.StopWithReason(reason)
+		}(reason)
+	}
+	wg.Wait()
+	a.StopWithReason("restart")
+
+	if !a.isStopping() {
+		t.Errorf("isStopping() = false")
+	}
+	if a.stop("restart") {
+		t.Errorf("关闭后 stop() 返回 true，重启会再次替换进程")
+	}
+	select {
+	case <-a.stopChan:
+	default:
+		t.Errorf("stopChan 未关闭")
+	}
+}
+
+// TestPermissionDefaults 会改变主机状态的权限开关默认全部关闭
+func TestPermissionDefaults(t *testing.T) {
+	config := newDefaultConfig()
+	if config.AllowExec || config.AllowPTY || config.AllowDockerControl || config.AllowSelfUpdate {
+		t.Errorf("默认权限 allowExec=%v allowPty=%v allowDockerControl=%v allowSelfUpdate=%v, want 全部关闭",
+			config.AllowExec, config.AllowPTY, config.AllowDockerControl, config.AllowSelfUpdate)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus 指标导出 (/metrics)
+// 每次抓取时以最近一次上报的 State 填充一个独立的 Registry，不额外采集；
+// 按设备区分的指标使用 GaugeVec，每次抓取重新生成，已消失的设备 (网卡、GPU、Swap 等) 不会残留
+
+// metricsBuilder 在一次抓取的 Registry 中注册并填充指标
+type metricsBuilder struct {
+	reg  *prometheus.Registry
+	vecs map[string]*prometheus.GaugeVec
+}
+
+func newMetricsBuilder() *metricsBuilder {
+	return &metricsBuilder{reg: prometheus.NewRegistry(), vecs: make(map[string]*prometheus.GaugeVec)}
+}
+
+// gauge 注册一个无标签的 gauge 并设置当前值
+func (m *metricsBuilder) gauge(name, help string, value float64) {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	g.Set(value)
+	m.reg.MustRegister(g)
+}
+
+// counter 注册一个取自快照的计数器 (计数在 Agent 其他位置累加，这里只负责导出)
+func (m *metricsBuilder) counter(name, help string, value float64) {
+	m.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 { return value }))
+}
+
+// vec 设置 GaugeVec 中一组标签的值，labels 为 key/value 交替的列表
+// 同名的 GaugeVec 在第一次使用时注册，之后的调用必须使用相同的标签名
+func (m *metricsBuilder) vec(name, help string, value float64, labels ...string) {
+	names := make([]string, 0, len(labels)/2)
+	values := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		names = append(names, labels[i])
+		values = append(values, labels[i+1])
+	}
+
+	v := m.vecs[name]
+	if v == nil {
+		v = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, names)
+		m.reg.MustRegister(v)
+		m.vecs[name] = v
+	}
+	v.WithLabelValues(values...).Set(value)
+}
+
+// boolGauge 布尔值转换为 1/0
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// buildMetricsRegistry 将主机信息和实时状态转换为 Prometheus 指标
+func buildMetricsRegistry(up bool, conn connectionStats, hostInfo *HostInfo, state *State) *prometheus.Registry {
+	m := newMetricsBuilder()
+
+	upValue := 0.0
+	if up {
+		upValue = 1
+	}
+	m.gauge("apimonitor_up", "Whether the agent is connected and authenticated to the dashboard.", upValue)
+	m.vec("apimonitor_agent_info", "Agent build information.", 1, "version", VERSION, "commit", GitCommit)
+	m.counter("apimonitor_reconnects_total", "Number of reconnect attempts since the agent started.", float64(conn.ReconnectCount))
+	if !conn.LastConnectedAt.IsZero() {
+		m.gauge("apimonitor_last_connected_timestamp_seconds", "Unix time of the last established connection.", float64(conn.LastConnectedAt.Unix()))
+	}
+
+	if hostInfo != nil {
+		m.gauge("apimonitor_cpu_cores", "Number of logical CPU cores.", float64(hostInfo.Cores))
+		m.gauge("apimonitor_cpu_base_frequency_mhz", "Nominal CPU frequency in MHz.", hostInfo.CPUFreqBaseMhz)
+		m.gauge("apimonitor_mem_total_bytes", "Total physical memory in bytes.", float64(hostInfo.MemTotal))
+		m.gauge("apimonitor_swap_total_bytes", "Total swap in bytes.", float64(hostInfo.SwapTotal))
+		m.gauge("apimonitor_disk_total_bytes", "Total disk capacity in bytes.", float64(hostInfo.DiskTotal))
+		m.gauge("apimonitor_disk_inodes_total", "Total inodes across reported filesystems.", float64(hostInfo.InodesTotal))
+		m.gauge("apimonitor_boot_time_seconds", "Host boot time as unix timestamp.", float64(hostInfo.BootTime))
+	}
+
+	if state == nil {
+		return m.reg
+	}
+
+	m.gauge("apimonitor_cpu_percent", "CPU usage percent.", state.CPU)
+	for i, v := range state.CPUPerCore {
+		m.vec("apimonitor_cpu_core_percent", "Per-core CPU usage percent.", v, "core", strconv.Itoa(i))
+	}
+	m.gauge("apimonitor_cpu_steal_percent", "Percent of CPU time stolen by the hypervisor.", state.CPUSteal)
+	m.gauge("apimonitor_cpu_iowait_percent", "Percent of CPU time spent waiting for IO.", state.CPUIOWait)
+	m.gauge("apimonitor_cpu_frequency_mhz", "Current average CPU frequency in MHz.", state.CPUFreqMhz)
+	m.gauge("apimonitor_mem_used_bytes", "Used memory in bytes.", float64(state.MemUsed))
+	m.gauge("apimonitor_mem_available_bytes", "Available memory in bytes, including reclaimable cache.", float64(state.MemAvailable))
+	m.gauge("apimonitor_mem_cached_bytes", "Page cache in bytes.", float64(state.MemCached))
+	m.gauge("apimonitor_mem_buffers_bytes", "Buffer memory in bytes.", float64(state.MemBuffers))
+	m.gauge("apimonitor_swap_used_bytes", "Used swap in bytes.", float64(state.SwapUsed))
+	for _, d := range state.SwapDevices {
+		m.vec("apimonitor_swap_device_used_bytes", "Per-device swap used in bytes.", float64(d.Used), "device", d.Name)
+		m.vec("apimonitor_swap_device_free_bytes", "Per-device swap free in bytes.", float64(d.Free), "device", d.Name)
+	}
+	m.gauge("apimonitor_disk_used_bytes", "Used disk space in bytes.", float64(state.DiskUsed))
+	m.gauge("apimonitor_disk_inodes_used", "Used inodes across reported filesystems.", float64(state.InodesUsed))
+	for _, d := range state.DiskHealth {
+		m.vec("apimonitor_disk_smart_passed", "SMART overall health (1 passed, 0 failed).", boolGauge(d.Health == "PASSED"), "device", d.Device, "model", d.Model)
+		m.vec("apimonitor_disk_temperature_celsius", "Disk temperature reported by SMART.", d.Temperature, "device", d.Device)
+		m.vec("apimonitor_disk_power_on_hours", "Disk power-on hours reported by SMART.", float64(d.PowerOnHours), "device", d.Device)
+	}
+	m.gauge("apimonitor_disk_read_speed_bytes", "Disk read speed in bytes per second.", float64(state.DiskReadSpeed))
+	m.gauge("apimonitor_disk_write_speed_bytes", "Disk write speed in bytes per second.", float64(state.DiskWriteSpeed))
+
+	m.gauge("apimonitor_net_in_speed_bytes", "Inbound network speed in bytes per second.", float64(state.NetInSpeed))
+	m.gauge("apimonitor_net_out_speed_bytes", "Outbound network speed in bytes per second.", float64(state.NetOutSpeed))
+	m.counter("apimonitor_net_in_transfer_bytes_total", "Total inbound network traffic in bytes.", float64(state.NetInTransfer))
+	m.counter("apimonitor_net_out_transfer_bytes_total", "Total outbound network traffic in bytes.", float64(state.NetOutTransfer))
+	for _, iface := range state.Interfaces {
+		m.vec("apimonitor_interface_in_speed_bytes", "Per-interface inbound speed in bytes per second.", float64(iface.InSpeed), "interface", iface.Name)
+		m.vec("apimonitor_interface_out_speed_bytes", "Per-interface outbound speed in bytes per second.", float64(iface.OutSpeed), "interface", iface.Name)
+	}
+
+	m.gauge("apimonitor_uptime_seconds", "Host uptime in seconds.", float64(state.Uptime))
+	m.gauge("apimonitor_load1", "1-minute load average.", state.Load1)
+	m.gauge("apimonitor_load5", "5-minute load average.", state.Load5)
+	m.gauge("apimonitor_load15", "15-minute load average.", state.Load15)
+	m.gauge("apimonitor_load1_per_core", "1-minute load average divided by logical cores.", state.Load1PerCore)
+	m.gauge("apimonitor_load5_per_core", "5-minute load average divided by logical cores.", state.Load5PerCore)
+	m.gauge("apimonitor_load15_per_core", "15-minute load average divided by logical cores.", state.Load15PerCore)
+	m.gauge("apimonitor_context_switches_per_second", "Context switches per second.", float64(state.ContextSwitches))
+	m.gauge("apimonitor_interrupts_per_second", "Interrupts per second.", float64(state.Interrupts))
+	m.gauge("apimonitor_tcp_connections", "Number of TCP connections.", float64(state.TcpConnCount))
+	for status, count := range state.TcpStates {
+		m.vec("apimonitor_tcp_connections_by_state", "Number of TCP connections by state.", float64(count), "state", status)
+	}
+	m.gauge("apimonitor_udp_connections", "Number of UDP sockets.", float64(state.UdpConnCount))
+	m.gauge("apimonitor_processes", "Number of processes.", float64(state.ProcessCount))
+	m.gauge("apimonitor_processes_running", "Number of running processes.", float64(state.ProcessRunning))
+	m.gauge("apimonitor_processes_zombie", "Number of zombie processes.", float64(state.ProcessZombie))
+	m.gauge("apimonitor_open_fds", "Number of open file descriptors.", float64(state.OpenFDs))
+	for resource, value := range map[string]float64{"cpu": state.PSICPU, "memory": state.PSIMem, "io": state.PSIIO} {
+		m.vec("apimonitor_pressure_avg10", "Percent of the last 10s some tasks were stalled on the resource (PSI).", value, "resource", resource)
+	}
+	m.gauge("apimonitor_clock_offset_ms", "Local clock offset from the NTP server in milliseconds.", state.ClockOffsetMs)
+	m.gauge("apimonitor_time_synced", "Whether the local clock is within 1s of the NTP server (1/0).", boolGauge(state.TimeSynced))
+	if state.DNSResolveOK {
+		m.gauge("apimonitor_dns_latency_ms", "Time to resolve dnsProbeHost in milliseconds.", state.DNSLatencyMs)
+	}
+	m.gauge("apimonitor_dns_resolve_ok", "Whether the last DNS probe succeeded (1/0).", boolGauge(state.DNSResolveOK))
+	if state.Battery != nil {
+		m.gauge("apimonitor_battery_percent", "Remaining battery charge percent.", state.Battery.Percent)
+		m.gauge("apimonitor_battery_charging", "Whether the battery is charging (1/0).", boolGauge(state.Battery.Charging))
+		m.gauge("apimonitor_battery_seconds_left", "Estimated battery runtime in seconds while discharging (0 if unknown).", float64(state.Battery.SecondsLeft))
+	}
+
+	m.gauge("apimonitor_gpu_percent", "Aggregated GPU utilization percent.", state.GPU)
+	m.gauge("apimonitor_gpu_mem_used_bytes", "Aggregated GPU memory used in bytes.", float64(state.GPUMemUsed))
+	m.gauge("apimonitor_gpu_mem_total_bytes", "Aggregated GPU memory in bytes.", float64(state.GPUMemTotal))
+	m.gauge("apimonitor_gpu_power_watts", "Aggregated GPU power draw in watts.", state.GPUPower)
+	m.gauge("apimonitor_gpu_temperature_celsius", "Average GPU temperature in celsius.", state.GPUTemp)
+	m.gauge("apimonitor_gpu_fan_percent", "Average GPU fan speed percent.", state.GPUFan)
+	for _, gpu := range state.GPUs {
+		idx := strconv.Itoa(gpu.Index)
+		m.vec("apimonitor_gpu_device_percent", "Per-GPU utilization percent.", gpu.Utilization, "gpu", idx, "name", gpu.Name)
+		m.vec("apimonitor_gpu_device_mem_used_bytes", "Per-GPU memory used in bytes.", float64(gpu.MemUsed), "gpu", idx, "name", gpu.Name)
+		m.vec("apimonitor_gpu_device_power_watts", "Per-GPU power draw in watts.", gpu.Power, "gpu", idx, "name", gpu.Name)
+		m.vec("apimonitor_gpu_device_temperature_celsius", "Per-GPU temperature in celsius.", gpu.Temperature, "gpu", idx, "name", gpu.Name)
+		m.vec("apimonitor_gpu_device_fan_percent", "Per-GPU fan speed percent.", gpu.FanSpeed, "gpu", idx, "name", gpu.Name)
+	}
+	m.gauge("apimonitor_system_power_watts", "System/CPU package power draw in watts.", state.SystemPower)
+
+	if state.Docker.Installed {
+		m.vec("apimonitor_docker_containers", "Number of containers by state.", float64(state.Docker.Running), "state", "running")
+		m.vec("apimonitor_docker_containers", "Number of containers by state.", float64(state.Docker.Stopped), "state", "stopped")
+	}
+	return m.reg
+}
+
+// handleMetrics 输出 Prometheus 指标
+func (a *AgentClient) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	connected, authenticated := a.isHealthy()
+	connStats := a.connectionStats()
+
+	a.mu.Lock()
+	hostInfo, state := a.lastHostInfo, a.lastState
+	a.mu.Unlock()
+
+	reg := buildMetricsRegistry(connected && authenticated, connStats, hostInfo, state)
+	// 个别标签值无效 (如非 UTF-8 的网卡名) 时跳过对应指标，不让整次抓取失败
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+}
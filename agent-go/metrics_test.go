@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TestWritePrometheusMetricsFamiliesContiguous 同一指标族的样本必须连续出现，且 HELP/TYPE 只出现一次
+func TestWritePrometheusMetricsFamiliesContiguous(t *testing.T) {
+	state := &State{
+		SwapDevices: []SwapDeviceStat{{Name: "/dev/sda2", Used: 1, Free: 2}, {Name: "/swapfile", Used: 3, Free: 4}},
+		DiskHealth:  []DiskHealthStat{{Device: "/dev/sda", Health: "PASSED"}, {Device: "/dev/sdb", Health: "FAILED"}},
+		Interfaces:  []InterfaceStat{{Name: "eth0", InSpeed: 1}, {Name: "eth1", OutSpeed: 2}},
+		GPUs:        []GPUStat{{Index: 0, Name: "A"}, {Index: 1, Name: "B"}},
+		TcpStates:   map[string]int{"ESTABLISHED": 3, "LISTEN": 2},
+	}
+	state.Docker.Installed = true
+
+	rec := httptest.NewRecorder()
+	reg := buildMetricsRegistry(true, connectionStats{}, &HostInfo{Cores: 4}, state)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	seen := map[string]bool{}
+	helps := map[string]int{}
+	current := ""
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# HELP ") {
+			helps[strings.Fields(line)[2]]++
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+		}
+		if name != current {
+			if seen[name] {
+				t.Errorf("指标族 %s 的样本不连续", name)
+			}
+			seen[name] = true
+			current = name
+		}
+	}
+
+	for name, n := range helps {
+		if n != 1 {
+			t.Errorf("指标族 %s 的 HELP 出现了 %d 次", name, n)
+		}
+	}
+	for _, name := range []string{"apimonitor_swap_device_used_bytes", "apimonitor_swap_device_free_bytes", "apimonitor_gpu_device_percent", "apimonitor_docker_containers"} {
+		if !seen[name] {
+			t.Errorf("缺少指标 %s", name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "net"
+
+// NetInterface 本机网卡及其地址
+type NetInterface struct {
+	Name  string   `json:"name"`
+	MAC   string   `json:"mac"`
+	IPv4  []string `json:"ipv4"` // CIDR 格式，如 192.168.1.10/24
+	IPv6  []string `json:"ipv6"`
+	Up    bool     `json:"up"`
+	Flags string   `json:"flags"`
+}
+
+// collectNetInterfaces 列出本机网卡的 MAC 和 IPv4/IPv6 地址
+// 默认跳过未启用的网卡和回环网卡，includeAll 为 true 时全部列出
+func collectNetInterfaces(includeAll bool) []NetInterface {
+	result := []NetInterface{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return result
+	}
+
+	for _, iface := range ifaces {
+		if !includeAll && !includeNetInterface(iface.Flags) {
+			continue
+		}
+
+		ni := NetInterface{
+			Name:  iface.Name,
+			MAC:   iface.HardwareAddr.String(),
+			IPv4:  []string{},
+			IPv6:  []string{},
+			Up:    iface.Flags&net.FlagUp != 0,
+			Flags: iface.Flags.String(),
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			ni.IPv4, ni.IPv6 = splitInterfaceAddrs(addrs)
+		}
+		result = append(result, ni)
+	}
+	return result
+}
+
+// includeNetInterface 默认只保留已启用的非回环网卡
+func includeNetInterface(flags net.Flags) bool {
+	return flags&net.FlagUp != 0 && flags&net.FlagLoopback == 0
+}
+
+// splitInterfaceAddrs 按地址族拆分网卡地址
+func splitInterfaceAddrs(addrs []net.Addr) (ipv4, ipv6 []string) {
+	ipv4, ipv6 = []string{}, []string{}
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPNet:
+			ip = v.IP
+		case *net.IPAddr:
+			ip = v.IP
+		default:
+			continue
+		}
+		if ip.To4() != nil {
+			ipv4 = append(ipv4, addr.String())
+		} else {
+			ipv6 = append(ipv6, addr.String())
+		}
+	}
+	return ipv4, ipv6
+}
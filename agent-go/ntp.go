@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// 时钟同步检查
+const (
+	ntpInterval        = 5 * time.Minute         // 查询间隔 (公共 NTP 池对频繁查询会限流)
+	ntpTimeout         = 3 * time.Second         // 单次查询超时
+	clockSyncThreshold = 1000 * time.Millisecond // 偏差不超过该值视为已同步
+)
+
+// ntpEpochOffset NTP 纪元 (1900-01-01) 与 Unix 纪元 (1970-01-01) 相差的秒数
+const ntpEpochOffset = 2208988800
+
+// ntpTimestamp 将 NTP 64 位时间戳 (秒 + 2^-32 秒的小数部分) 转换为 time.Time
+func ntpTimestamp(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpochOffset, nsec)
+}
+
+// parseSNTPResponse 按 RFC 4330 计算本机时钟相对服务器的偏差 (正数表示本机落后)
+// t1 为请求发出时间，t4 为收到响应的时间
+func parseSNTPResponse(resp []byte, t1, t4 time.Time) (time.Duration, error) {
+	if len(resp) < 48 {
+		return 0, fmt.Errorf("响应长度不足: %d", len(resp))
+	}
+	leap := resp[0] >> 6
+	mode := resp[0] & 0x07
+	stratum := resp[1]
+	if mode != 4 {
+		return 0, fmt.Errorf("非服务器响应 (mode=%d)", mode)
+	}
+	// stratum 0 为 Kiss-o'-Death (如 RATE 限流)，leap=3 表示服务器自身未同步
+	if stratum == 0 || leap == 3 {
+		return 0, fmt.Errorf("服务器不可用 (stratum=%d, leap=%d)", stratum, leap)
+	}
+
+	t2 := ntpTimestamp(resp[32:40]) // 服务器收到请求的时间
+	t3 := ntpTimestamp(resp[40:48]) // 服务器发出响应的时间
+	return (t2.Sub(t1) + t3.Sub(t4)) / 2, nil
+}
+
+// querySNTP 向 NTP 服务器发送一次 SNTP 请求，返回本机时钟偏差
+func querySNTP(server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // LI=0, VN=4, Mode=3 (客户端)
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	t4 := time.Now()
+	return parseSNTPResponse(resp[:n], t1, t4)
+}
+
+// collectClockState 时钟偏差与同步状态 (带节流缓存，每 ntpInterval 查询一次)
+func (c *Collector) collectClockState() func(*State) {
+	c.mu.Lock()
+	if time.Since(c.lastNTPTime) < ntpInterval {
+		offset, synced := c.lastClockOffset, c.lastTimeSynced
+		c.mu.Unlock()
+		return func(s *State) { applyClockState(s, offset, synced) }
+	}
+	c.lastNTPTime = time.Now()
+	c.mu.Unlock()
+
+	var offset float64
+	synced := false
+	if d, err := querySNTP(c.cfg().NTPServer); err == nil {
+		offset = float64(d) / float64(time.Millisecond)
+		synced = math.Abs(offset) <= float64(clockSyncThreshold/time.Millisecond)
+		if !synced {
+			logger.Warnf("[NTP] 本机时钟与 %s 偏差 %.0fms", c.cfg().NTPServer, offset)
+		}
+	} else {
+		logger.Debugf("[NTP] 查询 %s 失败: %v", c.cfg().NTPServer, err)
+	}
+
+	c.mu.Lock()
+	c.lastClockOffset = offset
+	c.lastTimeSynced = synced
+	c.mu.Unlock()
+	return func(s *State) { applyClockState(s, offset, synced) }
+}
+
+func applyClockState(s *State, offset float64, synced bool) {
+	s.ClockOffsetMs = offset
+	s.TimeSynced = synced
+}
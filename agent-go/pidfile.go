@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// pidFile 单实例锁: 持有期间文件保持打开，Unix 上同时持有 flock
+type pidFile struct {
+	path string
+	file *os.File
+}
+
+// acquirePidFile 写入当前进程 PID，已有存活实例持有该文件时返回错误
+func acquirePidFile(path string) (*pidFile, error) {
+	f, err := openLockedPidFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %v", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %v", err)
+	}
+	return &pidFile{path: path, file: f}, nil
+}
+
+// openLockedPidFile 打开并锁定 PID 文件
+// 锁定后确认路径仍指向同一个文件: 上一个实例退出时可能在我们打开之后删除了它，
+// 此时锁住的是已删除的文件，必须重新打开，否则之后启动的实例会创建新文件并同时运行
+func openLockedPidFile(path string) (*os.File, error) {
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("打开 PID 文件失败: %v", err)
+		}
+		if err := lockPidFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		opened, err := f.Stat()
+		if err == nil {
+			if current, statErr := os.Stat(path); statErr == nil && os.SameFile(opened, current) {
+				return f, nil
+			}
+		}
+		f.Close()
+		if attempt >= 2 {
+			return nil, fmt.Errorf("锁定 PID 文件失败: 文件在锁定期间被删除或替换")
+		}
+	}
+}
+
+// readPid 读取 PID 文件中记录的进程号，内容无效时返回 0
+func readPid(f *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// release 删除 PID 文件并释放锁
+// 必须在持有锁时删除: 先关闭会让等待中的新实例锁住这个文件，随后又被我们删掉
+func (p *pidFile) release() {
+	if p == nil {
+		return
+	}
+	if err := os.Remove(p.path); err != nil {
+		// Windows 不能删除仍然打开的文件 (也没有 flock)，关闭后再删除
+		p.file.Close()
+		os.Remove(p.path)
+		return
+	}
+	p.file.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockPidFile 以非阻塞方式获取排他 flock，进程退出 (包括崩溃) 时由内核自动释放，
+// 因此残留的 PID 文件不会阻止下次启动
+func lockPidFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return fmt.Errorf("已有 Agent 实例在运行 (PID %d)", readPid(f))
+		}
+		return fmt.Errorf("锁定 PID 文件失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestPidFileLockAndRelease 第二个实例无法获取锁；释放后文件被删除，新实例可以重新获取
+func TestPidFileLockAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+
+	first, err := acquirePidFile(path)
+	if err != nil {
+		t.Fatalf("acquirePidFile() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("PID 文件内容 = %q", data)
+	}
+
+	if _, err := acquirePidFile(path); err == nil || !strings.Contains(err.Error(), "已有 Agent 实例在运行") {
+		t.Fatalf("第二次 acquirePidFile() error = %v, want 已有实例", err)
+	}
+
+	// 模拟在释放前已经打开了同一个文件的新实例: 删除时仍持有锁，它无法抢先锁住这个文件
+	waiting, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waiting.Close()
+
+	first.release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("release() 后 PID 文件仍然存在: %v", err)
+	}
+
+	second, err := acquirePidFile(path)
+	if err != nil {
+		t.Fatalf("释放后 acquirePidFile() error = %v", err)
+	}
+	second.release()
+}
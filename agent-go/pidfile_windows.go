@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockPidFile Windows 没有 flock，检查文件中记录的进程是否仍然存在
+// (os.FindProcess 在 Windows 上会打开进程句柄，进程不存在时返回错误)
+func lockPidFile(f *os.File) error {
+	pid := readPid(f)
+	if pid == 0 || pid == os.Getpid() {
+		return nil
+	}
+	if p, err := os.FindProcess(pid); err == nil {
+		p.Release()
+		return fmt.Errorf("已有 Agent 实例在运行 (PID %d)", pid)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// pingTimePattern 匹配系统 ping 输出中的延迟 (如 "time=12.3 ms"、"时间<1ms")
+var pingTimePattern = regexp.MustCompile(`[=<]\s*([\d.]+)\s*ms`)
+
+// probeReachability 探测目标可达性，返回往返延迟 (毫秒)
+// target 为 host:port 时使用 TCP 连接，只有主机名/IP 时调用系统 ping (ICMP 权限由系统 ping 处理)
+func probeReachability(ctx context.Context, target string) (float64, error) {
+	if target == "" {
+		return 0, fmt.Errorf("目标地址不能为空")
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		// 没有端口 (或是裸 IPv6 地址)，使用 ICMP
+		return icmpPing(ctx, target)
+	}
+
+	// 先单独解析域名，便于区分 DNS 失败和连接失败
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return 0, fmt.Errorf("DNS 解析失败: %v", err)
+	}
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return 0, fmt.Errorf("TCP 连接失败: %v", err)
+	}
+	rtt := time.Since(start)
+	conn.Close()
+
+	return float64(rtt.Microseconds()) / 1000, nil
+}
+
+// icmpPing 调用系统 ping 发送一个 ICMP 包并解析延迟
+func icmpPing(ctx context.Context, host string) (float64, error) {
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return 0, fmt.Errorf("DNS 解析失败: %v", err)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "ping", "-n", "1", host)
+	} else {
+		cmd = exec.CommandContext(ctx, "ping", "-c", "1", host)
+	}
+	hideWindow(cmd)
+
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 0, fmt.Errorf("ping 超时")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ping 失败: %v", err)
+	}
+
+	match := pingTimePattern.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("无法解析 ping 输出")
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// defaultProcessListMax 进程表默认最多返回的进程数
+const defaultProcessListMax = 500
+
+// maxCmdlineLength 单个进程命令行的最大长度，超出部分截断
+const maxCmdlineLength = 1024
+
+// ProcessDetail 进程表中的一项
+type ProcessDetail struct {
+	PID      int32   `json:"pid"`
+	PPID     int32   `json:"ppid"`
+	Name     string  `json:"name"`
+	Cmdline  string  `json:"cmdline"`
+	CPU      float64 `json:"cpu"` // 进程启动以来的平均 CPU 使用率 (单核为 100)
+	RSS      uint64  `json:"rss"` // 常驻内存 (bytes)
+	State    string  `json:"state"`
+	Username string  `json:"username"`
+}
+
+// ProcessListRequest 进程表任务参数 (data 可为空)
+type ProcessListRequest struct {
+	Limit int `json:"limit"` // 最多返回的进程数，不超过 processListMax
+}
+
+// ProcessListResult 进程表任务结果
+type ProcessListResult struct {
+	Total     int             `json:"total"`     // 系统进程总数
+	Truncated bool            `json:"truncated"` // 超出数量上限、结果过大或任务超时，只返回了部分进程
+	Processes []ProcessDetail `json:"processes"` // 按 CPU 使用率降序
+}
+
+// collectProcessTable 采集完整进程表，按 CPU 使用率降序取前 limit 个
+// ctx 超时后停止遍历，返回已采集的部分
+func collectProcessTable(ctx context.Context, limit int) (*ProcessListResult, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProcessListResult{Total: len(procs)}
+	list := make([]ProcessDetail, 0, len(procs))
+	for _, p := range procs {
+		if ctx.Err() != nil {
+			result.Truncated = true
+			break
+		}
+
+		detail := ProcessDetail{PID: p.Pid}
+		detail.PPID, _ = p.PpidWithContext(ctx)
+		detail.Name, _ = p.NameWithContext(ctx)
+		if cmdline, err := p.CmdlineWithContext(ctx); err == nil {
+			if len(cmdline) > maxCmdlineLength {
+				cmdline = cmdline[:maxCmdlineLength]
+			}
+			detail.Cmdline = cmdline
+		}
+		detail.CPU, _ = p.CPUPercentWithContext(ctx)
+		if memInfo, err := p.MemoryInfoWithContext(ctx); err == nil {
+			detail.RSS = memInfo.RSS
+		}
+		if status, err := p.StatusWithContext(ctx); err == nil {
+			detail.State = strings.Join(status, ",")
+		}
+		detail.Username, _ = p.UsernameWithContext(ctx)
+		list = append(list, detail)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CPU > list[j].CPU })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+		result.Truncated = true
+	}
+	result.Processes = list
+	return result, nil
+}
+
+// handleProcessList 按需返回完整进程表 (需要开启 enableMetricQuery)
+func (a *AgentClient) handleProcessList(ctx context.Context, data string) (string, error) {
+	if !a.cfg().EnableMetricQuery {
+		return "", fmt.Errorf("指标查询未启用 (enableMetricQuery)")
+	}
+
+	limit := a.cfg().ProcessListMax
+	if limit <= 0 {
+		limit = defaultProcessListMax
+	}
+	var req ProcessListRequest
+	if strings.TrimSpace(data) != "" {
+		if err := json.Unmarshal([]byte(data), &req); err != nil {
+			return "", fmt.Errorf("解析请求失败: %v", err)
+		}
+	}
+	if req.Limit > 0 && req.Limit < limit {
+		limit = req.Limit
+	}
+
+	result, err := collectProcessTable(ctx, limit)
+	if err != nil {
+		return "", fmt.Errorf("采集进程表失败: %v", err)
+	}
+
+	return encodeProcessList(result)
+}
+
+// encodeProcessList 编码进程表，超出单条事件大小限制时截断
+func encodeProcessList(result *ProcessListResult) (string, error) {
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	// 命令行较长时仍可能超出单条事件大小限制，逐次减半直到放得下
+	for !taskDataFits(string(jsonResult)) && len(result.Processes) > 1 {
+		result.Processes = result.Processes[:len(result.Processes)/2]
+		result.Truncated = true
+		jsonResult, _ = json.Marshal(result)
+	}
+	return string(jsonResult), nil
+}
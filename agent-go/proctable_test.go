@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// assertTaskResultFrameFits 以任务结果上报 data，检查最终的事件帧没有超出限制且没有被替换为错误
+func assertTaskResultFrameFits(t *testing.T, data string) {
+	t.Helper()
+	a, conn := newAckTestClient(false)
+	a.sendTaskResult(map[string]interface{}{"id": "task", "successful": true, "data": data})
+	if len(conn.frames) != 1 {
+		t.Fatalf("发送了 %d 帧", len(conn.frames))
+	}
+	if frame := conn.frames[0]; len(frame) > maxEventPayloadSize || strings.Contains(frame, "任务结果过大") {
+		t.Errorf("任务结果帧 %d 字节，超出限制 %d", len(frame), maxEventPayloadSize)
+	}
+}
+
+// TestEncodeProcessListFitsEvent 命令行中的引号在二次转义后膨胀，按最终帧大小截断
+func TestEncodeProcessListFitsEvent(t *testing.T) {
+	cmdline := strings.Repeat(`"`, 500)
+	result := &ProcessListResult{Total: 2000}
+	for i := 0; i < 2000; i++ {
+		result.Processes = append(result.Processes, ProcessDetail{PID: int32(i), Name: "app", Cmdline: cmdline})
+	}
+
+	output, err := encodeProcessList(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Truncated || len(result.Processes) == 2000 {
+		t.Errorf("未截断: Truncated = %v, %d 个进程", result.Truncated, len(result.Processes))
+	}
+	assertTaskResultFrameFits(t, output)
+}
+
+// TestEncodeMetricQueryResultFitsEvent 列表指标按转义后的大小二分截断
+func TestEncodeMetricQueryResultFitsEvent(t *testing.T) {
+	items := make([]string, 3000)
+	for i := range items {
+		items[i] = strings.Repeat(`"`, 300)
+	}
+
+	output, err := encodeMetricQueryResult(MetricQueryResult{Metric: "test", Data: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"truncated":true`) {
+		t.Errorf("未标记 truncated")
+	}
+	assertTaskResultFrameFits(t, output)
+
+	if _, err := encodeMetricQueryResult(MetricQueryResult{Metric: "test", Data: strings.Repeat(`"`, maxEventPayloadSize/2)}); err == nil {
+		t.Errorf("过大的非列表结果未返回错误")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/proxy"
+)
+
+// applyProxy 为握手 HTTP 请求和 WebSocket 连接配置代理
+// 优先使用配置中的 proxyUrl (http/https/socks5/socks5h)，未配置时回退到 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+func applyProxy(config *Config, transport *http.Transport, dialer *websocket.Dialer) error {
+	if config.ProxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		dialer.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+
+	u, socksDialer, err := parseProxyURL(config.ProxyURL)
+	if err != nil {
+		return err
+	}
+	if socksDialer != nil {
+		transport.DialContext = socksDialContext(socksDialer)
+		dialer.NetDial = socksDialer.Dial
+	} else {
+		transport.Proxy = http.ProxyURL(u)
+		dialer.Proxy = http.ProxyURL(u)
+	}
+	return nil
+}
+
+// applyTransportProxy 只为 HTTP 客户端配置代理 (规则同 applyProxy)
+func applyTransportProxy(config *Config, transport *http.Transport) error {
+	if config.ProxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+
+	u, socksDialer, err := parseProxyURL(config.ProxyURL)
+	if err != nil {
+		return err
+	}
+	if socksDialer != nil {
+		transport.DialContext = socksDialContext(socksDialer)
+	} else {
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return nil
+}
+
+// parseProxyURL 解析代理地址，SOCKS5 代理同时返回对应的 Dialer
+func parseProxyURL(proxyURL string) (*url.URL, proxy.Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的代理地址: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return u, nil, nil
+	case "socks5", "socks5h":
+		socksDialer, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建 SOCKS5 代理失败: %v", err)
+		}
+		return u, socksDialer, nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+}
+
+// socksDialContext 将 SOCKS5 Dialer 适配为 http.Transport 的 DialContext
+func socksDialContext(d proxy.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.Dial(network, addr)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readPSIAvg10 读取 /proc/pressure/<resource> 中 some 行的 avg10 (最近 10 秒内有任务因该资源停顿的时间占比 %)
+// 内核未开启 PSI (CONFIG_PSI，4.20 起) 时返回 0
+func readPSIAvg10(resource string) float64 {
+	data, err := os.ReadFile("/proc/pressure/" + resource)
+	if err != nil {
+		return 0
+	}
+	return parsePSIAvg10(string(data))
+}
+
+// parsePSIAvg10 解析 PSI 文件内容，如 "some avg10=1.23 avg60=0.50 avg300=0.10 total=12345"
+func parsePSIAvg10(content string) float64 {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg10="); ok {
+				avg, _ := strconv.ParseFloat(value, 64)
+				return avg
+			}
+		}
+	}
+	return 0
+}
+
+// collectPSIState CPU/内存/IO 压力 (仅 Linux，直接读取文件，开销很小无需节流)
+func (c *Collector) collectPSIState() func(*State) {
+	cpu, mem, io := readPSIAvg10("cpu"), readPSIAvg10("memory"), readPSIAvg10("io")
+	return func(s *State) {
+		s.PSICPU = cpu
+		s.PSIMem = mem
+		s.PSIIO = io
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// IPty PTY 接口实现抽象 (pty_unix.go / pty_windows.go 中的 StartPTY 返回该接口)
+type IPty interface {
+	Read(b []byte) (int, error)
+	Write(b []byte) (int, error)
+	Resize(cols, rows uint32) error
+	Close() error
+}
+
+// PTYOptions 终端启动参数 (留空时使用各平台的自动检测)
+type PTYOptions struct {
+	Shell   string            // Shell 路径或名称
+	Args    []string          // Shell 参数
+	WorkDir string            // 工作目录
+	Command []string          // 受限模式: 直接运行的固定命令及参数 (非空时忽略 Shell/Args)，命令退出即结束会话
+	Env     map[string]string // 合并到环境中的变量 (execEnv)，优先于 TERM，受限模式的覆盖项除外
+}
+
+// ptyOptionsFromConfig 从配置中读取终端启动参数
+func ptyOptionsFromConfig(config *Config) PTYOptions {
+	return PTYOptions{
+		Shell:   config.ShellPath,
+		Args:    config.ShellArgs,
+		WorkDir: config.ShellWorkDir,
+		Command: strings.Fields(config.PTYCommand),
+		Env:     config.ExecEnv,
+	}
+}
+
+// restrictedPTYEnv 受限模式下覆盖的环境变量，阻止 less/top 等程序通过 ! 等按键启动 Shell
+var restrictedPTYEnv = []string{"SHELL=/bin/false", "LESSSECURE=1", "PAGER=cat", "EDITOR=/bin/false", "VISUAL=/bin/false"}
@@ -3,19 +3,25 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 
 	opty "github.com/creack/pty"
 )
 
 type UnixPty struct {
-	tty *os.File
-	cmd *exec.Cmd
+	tty       *os.File
+	cmd       *exec.Cmd
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// 编译期检查 UnixPty 实现了 IPty
+var _ IPty = (*UnixPty)(nil)
+
 func (p *UnixPty) Read(b []byte) (int, error) {
 	return p.tty.Read(b)
 }
@@ -24,19 +30,24 @@ func (p *UnixPty) Write(b []byte) (int, error) {
 	return p.tty.Write(b)
 }
 
+// Close 关闭终端并杀掉整个进程组 (可重复调用，空闲超时和读取循环都可能触发关闭)
 func (p *UnixPty) Close() error {
-	if err := p.tty.Close(); err != nil {
-		return err
-	}
-	// 杀掉子进程
-	if p.cmd.Process != nil {
-		pgid, err := syscall.Getpgid(p.cmd.Process.Pid)
-		if err == nil {
-			syscall.Kill(-pgid, syscall.SIGKILL)
+	p.closeOnce.Do(func() {
+		ttyErr := p.tty.Close()
+		// 即使关闭 tty 失败也要杀掉子进程，避免遗留 Shell
+		if p.cmd.Process != nil {
+			pgid, err := syscall.Getpgid(p.cmd.Process.Pid)
+			if err == nil {
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			}
+			p.cmd.Process.Kill()
 		}
-		p.cmd.Process.Kill()
-	}
-	return p.cmd.Wait()
+		p.closeErr = p.cmd.Wait()
+		if ttyErr != nil {
+			p.closeErr = ttyErr
+		}
+	})
+	return p.closeErr
 }
 
 func (p *UnixPty) Resize(cols, rows uint32) error {
@@ -46,14 +57,27 @@ func (p *UnixPty) Resize(cols, rows uint32) error {
 	})
 }
 
-func StartPTY(cols, rows uint32) (IPty, error) {
+func StartPTY(cols, rows uint32, opts PTYOptions) (IPty, error) {
+	if len(opts.Command) > 0 {
+		return startCommandPTY(cols, rows, opts)
+	}
+
 	var shellPath string
-	shells := []string{"zsh", "fish", "bash", "sh"}
-	for _, sh := range shells {
-		path, err := exec.LookPath(sh)
-		if err == nil && path != "" {
-			shellPath = path
-			break
+	if opts.Shell != "" {
+		// 配置了 Shell 时不再回退到自动检测，避免意外启动不受限制的 Shell
+		path, err := exec.LookPath(opts.Shell)
+		if err != nil {
+			return nil, fmt.Errorf("找不到配置的 Shell %s: %v", opts.Shell, err)
+		}
+		shellPath = path
+	} else {
+		shells := []string{"zsh", "fish", "bash", "sh"}
+		for _, sh := range shells {
+			path, err := exec.LookPath(sh)
+			if err == nil && path != "" {
+				shellPath = path
+				break
+			}
 		}
 	}
 
@@ -61,11 +85,36 @@ func StartPTY(cols, rows uint32) (IPty, error) {
 		shellPath = "/bin/sh"
 	}
 
-	log.Printf("[PTY] 启动 Unix 终端: %s, 尺寸: %dx%d", shellPath, cols, rows)
+	logger.Infof("[PTY] 启动 Unix 终端: %s, 尺寸: %dx%d", shellPath, cols, rows)
+
+	cmd := exec.Command(shellPath, opts.Args...)
+	cmd.Env = buildExecEnv(append(os.Environ(), "TERM=xterm-256color"), opts.Env)
+	cmd.Dir = opts.WorkDir // 为空时使用 Agent 的当前目录
+
+	tty, err := opty.StartWithSize(cmd, &opty.Winsize{
+		Cols: uint16(cols),
+		Rows: uint16(rows),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnixPty{tty: tty, cmd: cmd}, nil
+}
+
+// startCommandPTY 受限模式: 不经过 Shell 直接运行配置的命令，输入只会到达该程序
+func startCommandPTY(cols, rows uint32, opts PTYOptions) (IPty, error) {
+	path, err := exec.LookPath(opts.Command[0])
+	if err != nil {
+		return nil, fmt.Errorf("找不到配置的终端命令 %s: %v", opts.Command[0], err)
+	}
+
+	logger.Infof("[PTY] 启动受限终端: %s %v, 尺寸: %dx%d", path, opts.Command[1:], cols, rows)
+
+	cmd := exec.Command(path, opts.Command[1:]...)
+	cmd.Env = append(buildExecEnv(append(os.Environ(), "TERM=xterm-256color"), opts.Env), restrictedPTYEnv...)
+	cmd.Dir = opts.WorkDir
 
-	cmd := exec.Command(shellPath)
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
-	
 	tty, err := opty.StartWithSize(cmd, &opty.Winsize{
 		Cols: uint16(cols),
 		Rows: uint16(rows),
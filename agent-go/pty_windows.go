@@ -3,18 +3,25 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
+	"syscall"
 
 	"github.com/UserExistsError/conpty"
 )
 
 type WindowsPty struct {
-	tty *conpty.ConPty
+	tty       *conpty.ConPty
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// 编译期检查 WindowsPty 实现了 IPty
+var _ IPty = (*WindowsPty)(nil)
+
 func (p *WindowsPty) Read(b []byte) (int, error) {
 	return p.tty.Read(b)
 }
@@ -23,29 +30,61 @@ func (p *WindowsPty) Write(b []byte) (int, error) {
 	return p.tty.Write(b)
 }
 
+// Close 关闭 ConPTY (会结束其中的 Shell 进程)，可重复调用
 func (p *WindowsPty) Close() error {
-	return p.tty.Close()
+	p.closeOnce.Do(func() {
+		p.closeErr = p.tty.Close()
+	})
+	return p.closeErr
 }
 
 func (p *WindowsPty) Resize(cols, rows uint32) error {
 	return p.tty.Resize(int(cols), int(rows))
 }
 
-func StartPTY(cols, rows uint32) (IPty, error) {
-	shellPath, err := exec.LookPath("powershell.exe")
-	if err != nil || shellPath == "" {
-		shellPath = "cmd.exe"
+func StartPTY(cols, rows uint32, opts PTYOptions) (IPty, error) {
+	// 受限模式: 直接运行配置的命令，不启动 Shell
+	if len(opts.Command) > 0 {
+		opts.Shell = opts.Command[0]
+		opts.Args = opts.Command[1:]
 	}
 
-	// 使用可执行文件所在目录作为工作目录
-	exePath, _ := os.Executable()
-	workDir := filepath.Dir(exePath)
+	var shellPath string
+	if opts.Shell != "" {
+		// 配置了 Shell 时不再回退到自动检测，避免意外启动不受限制的 Shell
+		path, err := exec.LookPath(opts.Shell)
+		if err != nil {
+			return nil, fmt.Errorf("找不到配置的 Shell %s: %v", opts.Shell, err)
+		}
+		shellPath = path
+	} else {
+		path, err := exec.LookPath("powershell.exe")
+		if err != nil || path == "" {
+			path = "cmd.exe"
+		}
+		shellPath = path
+	}
 
-	log.Printf("[PTY] 启动 Windows 终端: %s, 尺寸: %dx%d, 工作目录: %s", shellPath, cols, rows, workDir)
+	// 未配置工作目录时使用可执行文件所在目录
+	workDir := opts.WorkDir
+	if workDir == "" {
+		exePath, _ := os.Executable()
+		workDir = filepath.Dir(exePath)
+	}
 
-	tty, err := conpty.Start(shellPath, 
-		conpty.ConPtyWorkDir(workDir),
-	)
+	// ConPTY 接收完整的命令行，需要按 Windows 规则转义
+	commandLine := syscall.EscapeArg(shellPath)
+	for _, arg := range opts.Args {
+		commandLine += " " + syscall.EscapeArg(arg)
+	}
+
+	logger.Infof("[PTY] 启动 Windows 终端: %s, 尺寸: %dx%d, 工作目录: %s", commandLine, cols, rows, workDir)
+
+	ptyOpts := []conpty.ConPtyOption{conpty.ConPtyWorkDir(workDir)}
+	if len(opts.Env) > 0 {
+		ptyOpts = append(ptyOpts, conpty.ConPtyEnv(buildExecEnv(os.Environ(), opts.Env)))
+	}
+	tty, err := conpty.Start(commandLine, ptyOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 终端录像定期刷盘的间隔
+const ptyRecordFlushInterval = 5 * time.Second
+
+// unsafeFileChars 会话 ID 中不能用于文件名的字符
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// recordingPty 包装 IPty，将终端输出 (可选输入) 以 asciicast v2 格式写入文件
+// 录像与是否转发到 Dashboard 无关，读写失败只记录日志，不影响会话本身
+type recordingPty struct {
+	IPty
+	mu          sync.Mutex
+	file        *os.File
+	w           *bufio.Writer
+	start       time.Time
+	recordInput bool
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// ptyRecordPath 录像文件路径: <dir>/<会话ID>-<开始时间>.cast
+func ptyRecordPath(dir, id string, start time.Time) string {
+	name := fmt.Sprintf("%s-%s.cast", unsafeFileChars.ReplaceAllString(id, "_"), start.Format("20060102-150405"))
+	return filepath.Join(dir, name)
+}
+
+// newRecordingPty 创建录像文件并写入 asciicast 头部 (文件权限 0600，录像可能包含密码等敏感信息)
+func newRecordingPty(pty IPty, dir, id string, cols, rows uint32, recordInput bool) (*recordingPty, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建录像目录失败: %v", err)
+	}
+	start := time.Now()
+	path := ptyRecordPath(dir, id, start)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建录像文件失败: %v", err)
+	}
+
+	r := &recordingPty{
+		IPty:        pty,
+		file:        file,
+		w:           bufio.NewWriter(file),
+		start:       start,
+		recordInput: recordInput,
+		done:        make(chan struct{}),
+	}
+	header, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     cols,
+		"height":    rows,
+		"timestamp": start.Unix(),
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	})
+	r.w.Write(append(header, '\n'))
+
+	logger.Infof("[PTY] 会话 %s 录像写入 %s", id, path)
+	go r.flushLoop()
+	return r, nil
+}
+
+// writeEvent 追加一条 [秒数, 类型, 数据] 记录
+func (r *recordingPty) writeEvent(kind, data string) {
+	line, _ := json.Marshal([]interface{}{time.Since(r.start).Seconds(), kind, data})
+	r.mu.Lock()
+	r.w.Write(append(line, '\n'))
+	r.mu.Unlock()
+}
+
+func (r *recordingPty) Read(b []byte) (int, error) {
+	n, err := r.IPty.Read(b)
+	if n > 0 {
+		r.writeEvent("o", string(b[:n]))
+	}
+	return n, err
+}
+
+func (r *recordingPty) Write(b []byte) (int, error) {
+	if r.recordInput && len(b) > 0 {
+		r.writeEvent("i", string(b))
+	}
+	return r.IPty.Write(b)
+}
+
+func (r *recordingPty) Resize(cols, rows uint32) error {
+	r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+	return r.IPty.Resize(cols, rows)
+}
+
+// Close 关闭终端并将剩余内容写入录像文件 (可重复调用)
+func (r *recordingPty) Close() error {
+	err := r.IPty.Close()
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if ferr := r.w.Flush(); ferr != nil {
+			logger.Warnf("[PTY] 写入录像失败: %v", ferr)
+		}
+		r.file.Close()
+	})
+	return err
+}
+
+// flushLoop 定期刷盘，Agent 异常退出时最多丢失一个刷盘间隔的内容
+func (r *recordingPty) flushLoop() {
+	ticker := time.NewTicker(ptyRecordFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if err := r.w.Flush(); err != nil {
+				logger.Warnf("[PTY] 写入录像失败: %v", err)
+			}
+			r.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 默认的公网 IP 查询地址 (返回纯文本 IP)
+var (
+	defaultPublicIPEndpoints = []string{
+		"http://ip.sb",
+		"https://api.ipify.org",
+		"https://icanhazip.com",
+	}
+	defaultPublicIPv6Endpoints = []string{
+		"https://api6.ipify.org",
+		"https://ipv6.icanhazip.com",
+	}
+)
+
+// newPublicIPClient 创建公网 IP 查询共用的 HTTP 客户端 (复用连接，并与 Dashboard 连接使用相同的代理)
+// network 为 tcp4/tcp6 时强制使用对应的地址族，双栈主机才能分别查到 IPv4 和 IPv6 地址
+// (经过 SOCKS5 代理时由代理决定出口地址族)
+func newPublicIPClient(config *Config, network string) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		MaxIdleConns:        4,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+	if err := applyTransportProxy(config, transport); err != nil {
+		logger.Warnf("[Collector] 公网 IP 查询代理配置无效，改用环境变量: %v", err)
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	return &http.Client{Timeout: 5 * time.Second, Transport: transport}
+}
+
+// getPublicIP 获取公网 IPv4 (兼容旧版，结果写入 HostInfo.IP)
+func (c *Collector) getPublicIP() string {
+	endpoints := c.cfg().PublicIPEndpoints
+	if len(endpoints) == 0 {
+		endpoints = defaultPublicIPEndpoints
+	}
+	return queryPublicIP(c.httpClient, endpoints, false)
+}
+
+// getPublicIPv6 获取公网 IPv6 (没有 IPv6 连接时返回空)
+func (c *Collector) getPublicIPv6() string {
+	endpoints := c.cfg().PublicIPv6Endpoints
+	if len(endpoints) == 0 {
+		endpoints = defaultPublicIPv6Endpoints
+	}
+	return queryPublicIP(c.httpClientV6, endpoints, true)
+}
+
+// queryPublicIP 依次请求查询地址，返回第一个有效的 IP (wantIPv6 指定需要的地址族)
+func queryPublicIP(client *http.Client, endpoints []string, wantIPv6 bool) string {
+	for _, endpoint := range endpoints {
+		resp, err := client.Get(endpoint)
+		if err != nil {
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		ip := net.ParseIP(strings.TrimSpace(string(body)))
+		if ip == nil {
+			continue
+		}
+		if isIPv6 := ip.To4() == nil; isIPv6 == wantIPv6 {
+			return ip.String()
+		}
+	}
+
+	return ""
+}
+
+// defaultGeoIPEndpoint 默认的 IP 归属地查询地址 ({ip} 会被替换为公网 IP)
+// 查询会把公网 IP 发给第三方，默认地址使用 https 避免在传输途中泄露
+const defaultGeoIPEndpoint = "https://ipapi.co/{ip}/json/"
+
+// lookupCountryCode 查询公网 IP 的国家代码，IP 未变化时直接使用缓存 (调用方持有 c.hostInfoMu)
+// 查询失败时返回上次的结果，下一个主机信息周期再重试
+func (c *Collector) lookupCountryCode(ip string) string {
+	if ip == "" {
+		return c.countryCode
+	}
+	if ip == c.countryCodeIP {
+		return c.countryCode
+	}
+
+	endpoint := c.cfg().GeoIPEndpoint
+	if endpoint == "" {
+		endpoint = defaultGeoIPEndpoint
+	}
+	resp, err := c.httpClient.Get(strings.ReplaceAll(endpoint, "{ip}", ip))
+	if err != nil {
+		logger.Debugf("[Collector] 查询 IP 归属地失败: %v", err)
+		return c.countryCode
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		logger.Debugf("[Collector] 查询 IP 归属地失败: HTTP %d", resp.StatusCode)
+		return c.countryCode
+	}
+
+	code := parseCountryCode(body)
+	if code == "" {
+		return c.countryCode
+	}
+	c.countryCode = code
+	c.countryCodeIP = ip
+	return code
+}
+
+// parseCountryCode 从归属地查询的 JSON 响应中取出两位国家代码
+// 兼容 ip-api.com (countryCode)、ipapi.co (country_code) 和 ipinfo.io (country)
+func parseCountryCode(body []byte) string {
+	var resp struct {
+		CountryCode  string `json:"countryCode"`
+		CountryCode2 string `json:"country_code"`
+		Country      string `json:"country"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ""
+	}
+	for _, code := range []string{resp.CountryCode, resp.CountryCode2, resp.Country} {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) == 2 {
+			return code
+		}
+	}
+	return ""
+}
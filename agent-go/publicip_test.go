@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGeoIPDefaults 归属地查询默认关闭，默认地址使用 https
+func TestGeoIPDefaults(t *testing.T) {
+	if newDefaultConfig().EnableGeoIP {
+		t.Errorf("enableGeoIp 默认开启")
+	}
+	if !strings.HasPrefix(defaultGeoIPEndpoint, "https://") {
+		t.Errorf("defaultGeoIPEndpoint = %q, want https", defaultGeoIPEndpoint)
+	}
+}
+
+// TestLookupCountryCode 替换 {ip} 查询国家代码，IP 不变时使用缓存
+func TestLookupCountryCode(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"ip":"203.0.113.7","country_code":"jp"}`))
+	}))
+	defer server.Close()
+
+	config := newDefaultConfig()
+	config.GeoIPEndpoint = server.URL + "/{ip}/json/"
+	c := NewCollector(config)
+
+	for i := 0; i < 2; i++ {
+		if got := c.lookupCountryCode("203.0.113.7"); got != "JP" {
+			t.Errorf("lookupCountryCode() = %q, want JP", got)
+		}
+	}
+	if len(paths) != 1 || paths[0] != "/203.0.113.7/json/" {
+		t.Errorf("请求路径 = %v, want 只请求一次 /203.0.113.7/json/", paths)
+	}
+}
+
+// TestCollectStateNotBlockedByHostInfo 公网 IP 查询未返回时，实时状态采集仍在截止时间内完成
+func TestCollectStateNotBlockedByHostInfo(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Write([]byte("203.0.113.7"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newTestCollector()
+	next := *c.cfg()
+	next.EnablePublicIP = true
+	next.PublicIPEndpoints = []string{server.URL}
+	next.PublicIPv6Endpoints = []string{server.URL}
+	c.config.Store(&next)
+
+	go c.CollectHostInfo()
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("公网 IP 查询未发出")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.CollectStateContext(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("CollectStateContext 被 CollectHostInfo 阻塞")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// applyReloadedConfig 将 next 中可热更新的字段应用到 cur，返回变更项和被忽略的变更项
+// 连接、鉴权和权限相关的字段需要重启才能生效，这里只记录不应用
+func applyReloadedConfig(cur, next *Config) (changed, ignored []string) {
+	diff := func(name string, old, new interface{}) bool {
+		if fmt.Sprint(old) == fmt.Sprint(new) {
+			return false
+		}
+		changed = append(changed, fmt.Sprintf("%s: %v -> %v", name, old, new))
+		return true
+	}
+	immutable := func(name string, old, new interface{}) {
+		if fmt.Sprint(old) != fmt.Sprint(new) {
+			ignored = append(ignored, name)
+		}
+	}
+
+	// 上报的主机名 (下次认证时生效)
+	if diff("hostname", cur.Hostname, next.Hostname) {
+		cur.Hostname = next.Hostname
+	}
+
+	// 上报周期与日志
+	if diff("reportInterval", cur.ReportInterval, next.ReportInterval) {
+		cur.ReportInterval = next.ReportInterval
+	}
+	if diff("hostInfoInterval", cur.HostInfoInterval, next.HostInfoInterval) {
+		cur.HostInfoInterval = next.HostInfoInterval
+	}
+	if diff("minReportInterval", cur.MinReportInterval, next.MinReportInterval) {
+		cur.MinReportInterval = next.MinReportInterval
+	}
+	if diff("reconnectDelay", cur.ReconnectDelay, next.ReconnectDelay) {
+		cur.ReconnectDelay = next.ReconnectDelay
+	}
+	if diff("maxReconnectDelay", cur.MaxReconnectDelay, next.MaxReconnectDelay) {
+		cur.MaxReconnectDelay = next.MaxReconnectDelay
+	}
+	if diff("stateBufferMaxAge", cur.StateBufferMaxAge, next.StateBufferMaxAge) {
+		cur.StateBufferMaxAge = next.StateBufferMaxAge
+	}
+	if diff("shutdownTimeout", cur.ShutdownTimeout, next.ShutdownTimeout) {
+		cur.ShutdownTimeout = next.ShutdownTimeout
+	}
+	if diff("debug", cur.Debug, next.Debug) {
+		cur.Debug = next.Debug
+	}
+	if diff("logLevel", cur.LogLevel, next.LogLevel) {
+		cur.LogLevel = next.LogLevel
+	}
+	if diff("logFormat", cur.LogFormat, next.LogFormat) {
+		cur.LogFormat = next.LogFormat
+	}
+
+	// 采集开关 (每次采集时读取，下一次采集生效)
+	if diff("enableGpu", cur.EnableGPU, next.EnableGPU) {
+		cur.EnableGPU = next.EnableGPU
+	}
+	if diff("enableDocker", cur.EnableDocker, next.EnableDocker) {
+		cur.EnableDocker = next.EnableDocker
+	}
+	if diff("enablePublicIp", cur.EnablePublicIP, next.EnablePublicIP) {
+		cur.EnablePublicIP = next.EnablePublicIP
+	}
+	if diff("enableConnCount", cur.EnableConnCount, next.EnableConnCount) {
+		cur.EnableConnCount = next.EnableConnCount
+	}
+	if diff("dockerStats", cur.DockerStats, next.DockerStats) {
+		cur.DockerStats = next.DockerStats
+	}
+	if diff("dockerMaxContainers", cur.DockerMaxContainers, next.DockerMaxContainers) {
+		cur.DockerMaxContainers = next.DockerMaxContainers
+	}
+	if diff("topProcessCount", cur.TopProcessCount, next.TopProcessCount) {
+		cur.TopProcessCount = next.TopProcessCount
+	}
+	if diff("logErrorWatch", cur.LogErrorWatch, next.LogErrorWatch) {
+		cur.LogErrorWatch = next.LogErrorWatch
+	}
+	if diff("logErrorTail", cur.LogErrorTail, next.LogErrorTail) {
+		cur.LogErrorTail = next.LogErrorTail
+	}
+	if diff("watchProcesses", cur.WatchProcesses, next.WatchProcesses) {
+		cur.WatchProcesses = next.WatchProcesses
+	}
+	if diff("netInterfaceExclude", cur.NetInterfaceExclude, next.NetInterfaceExclude) {
+		cur.NetInterfaceExclude = next.NetInterfaceExclude
+	}
+	if diff("stateFields", cur.StateFields, next.StateFields) {
+		cur.StateFields = next.StateFields
+	}
+	if diff("hostInfoFields", cur.HostInfoFields, next.HostInfoFields) {
+		cur.HostInfoFields = next.HostInfoFields
+	}
+	if diff("tags", cur.Tags, next.Tags) {
+		cur.Tags = next.Tags
+	}
+	if diff("includeAllInterfaces", cur.IncludeAllInterfaces, next.IncludeAllInterfaces) {
+		cur.IncludeAllInterfaces = next.IncludeAllInterfaces
+	}
+	if diff("dnsResolver", cur.DNSResolver, next.DNSResolver) {
+		cur.DNSResolver = next.DNSResolver
+	}
+	if diff("listenPorts", cur.ListenPorts, next.ListenPorts) {
+		cur.ListenPorts = next.ListenPorts
+	}
+	if diff("diskExcludeFsTypes", cur.DiskExcludeFsTypes, next.DiskExcludeFsTypes) {
+		cur.DiskExcludeFsTypes = next.DiskExcludeFsTypes
+	}
+	if diff("diskExcludeMounts", cur.DiskExcludeMounts, next.DiskExcludeMounts) {
+		cur.DiskExcludeMounts = next.DiskExcludeMounts
+	}
+	if diff("enableSmart", cur.EnableSMART, next.EnableSMART) {
+		cur.EnableSMART = next.EnableSMART
+	}
+	if diff("ntpServer", cur.NTPServer, next.NTPServer) {
+		cur.NTPServer = next.NTPServer
+	}
+	if diff("dnsProbeHost", cur.DNSProbeHost, next.DNSProbeHost) {
+		cur.DNSProbeHost = next.DNSProbeHost
+	}
+	if diff("publicIpEndpoints", cur.PublicIPEndpoints, next.PublicIPEndpoints) {
+		cur.PublicIPEndpoints = next.PublicIPEndpoints
+	}
+	if diff("publicIpv6Endpoints", cur.PublicIPv6Endpoints, next.PublicIPv6Endpoints) {
+		cur.PublicIPv6Endpoints = next.PublicIPv6Endpoints
+	}
+	if diff("enableGeoIp", cur.EnableGeoIP, next.EnableGeoIP) {
+		cur.EnableGeoIP = next.EnableGeoIP
+	}
+	if diff("geoIpEndpoint", cur.GeoIPEndpoint, next.GeoIPEndpoint) {
+		cur.GeoIPEndpoint = next.GeoIPEndpoint
+	}
+
+	// 任务参数 (下一个任务生效；收紧 execAllowlist 后立即限制新的命令)
+	if diff("execAllowlist", cur.ExecAllowlist, next.ExecAllowlist) {
+		cur.ExecAllowlist = next.ExecAllowlist
+	}
+	if diff("execMaxOutput", cur.ExecMaxOutput, next.ExecMaxOutput) {
+		cur.ExecMaxOutput = next.ExecMaxOutput
+	}
+	if diff("processListMax", cur.ProcessListMax, next.ProcessListMax) {
+		cur.ProcessListMax = next.ProcessListMax
+	}
+	if diff("speedtestUrl", cur.SpeedtestURL, next.SpeedtestURL) {
+		cur.SpeedtestURL = next.SpeedtestURL
+	}
+	if diff("speedtestUploadUrl", cur.SpeedtestUploadURL, next.SpeedtestUploadURL) {
+		cur.SpeedtestUploadURL = next.SpeedtestUploadURL
+	}
+	if diff("speedtestCooldown", cur.SpeedtestCooldown, next.SpeedtestCooldown) {
+		cur.SpeedtestCooldown = next.SpeedtestCooldown
+	}
+
+	// 需要重启才能生效的字段
+	immutable("serverUrl", cur.ServerURL, next.ServerURL)
+	immutable("serverUrls", cur.ServerURLs, next.ServerURLs)
+	immutable("failoverAttempts", cur.FailoverAttempts, next.FailoverAttempts)
+	immutable("serverId", cur.ServerID, next.ServerID)
+	immutable("agentKey", cur.AgentKey, next.AgentKey)
+	immutable("authHmac", cur.AuthHMAC, next.AuthHMAC)
+	immutable("compression", cur.Compression, next.Compression)
+	immutable("proxyUrl", cur.ProxyURL, next.ProxyURL)
+	immutable("tlsSkipVerify", cur.TLSSkipVerify, next.TLSSkipVerify)
+	immutable("statusAddr", cur.StatusAddr, next.StatusAddr)
+	immutable("pidFile", cur.PidFile, next.PidFile)
+	immutable("allowExec", cur.AllowExec, next.AllowExec)
+	immutable("allowPty", cur.AllowPTY, next.AllowPTY)
+	immutable("ptyCommand", cur.PTYCommand, next.PTYCommand)
+	immutable("execEnv", cur.ExecEnv, next.ExecEnv)
+	immutable("ptyRecordDir", cur.PTYRecordDir, next.PTYRecordDir)
+	immutable("ptyRecordInput", cur.PTYRecordInput, next.PTYRecordInput)
+	immutable("allowDockerControl", cur.AllowDockerControl, next.AllowDockerControl)
+	immutable("allowSelfUpdate", cur.AllowSelfUpdate, next.AllowSelfUpdate)
+	immutable("enableMetricQuery", cur.EnableMetricQuery, next.EnableMetricQuery)
+	immutable("shellPath", cur.ShellPath, next.ShellPath)
+	immutable("shellArgs", cur.ShellArgs, next.ShellArgs)
+	immutable("shellWorkDir", cur.ShellWorkDir, next.ShellWorkDir)
+	immutable("ptyIdleTimeout", cur.PTYIdleTimeout, next.PTYIdleTimeout)
+	immutable("gpuInterval", cur.GPUInterval, next.GPUInterval)
+	immutable("clientCertFile", cur.ClientCertFile, next.ClientCertFile)
+	immutable("clientKeyFile", cur.ClientKeyFile, next.ClientKeyFile)
+	immutable("caCertFile", cur.CACertFile, next.CACertFile)
+	immutable("eventBufferSize", cur.EventBufferSize, next.EventBufferSize)
+	immutable("noReport", cur.NoReport, next.NoReport)
+	immutable("prometheusEnabled", cur.PrometheusEnabled, next.PrometheusEnabled)
+	immutable("selfUpdateHosts", cur.SelfUpdateHosts, next.SelfUpdateHosts)
+	immutable("logReadAllowlist", cur.LogReadAllowlist, next.LogReadAllowlist)
+	immutable("urgentConditions", cur.UrgentConditions, next.UrgentConditions)
+	immutable("alerts", cur.Alerts, next.Alerts)
+	immutable("engineIoVersion", cur.EngineIOVersion, next.EngineIOVersion)
+	immutable("transport", cur.Transport, next.Transport)
+
+	return changed, ignored
+}
+
+// cfg 当前生效的配置快照 (只读，重新加载时替换为新的 *Config)
+func (a *AgentClient) cfg() *Config {
+	return a.config.Load()
+}
+
+// cfg 当前生效的配置快照 (与 AgentClient 共用)
+func (c *Collector) cfg() *Config {
+	return c.config.Load()
+}
+
+// ReloadConfig 应用重新加载的配置 (SIGHUP)，不断开当前连接
+func (a *AgentClient) ReloadConfig(next *Config) {
+	if errs := validateConfig(next); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Errorf("[Config] 重新加载失败，保留当前配置: %v", err)
+		}
+		return
+	}
+
+	// 在当前配置的副本上应用变更后整体替换，采集和上报协程读到的 *Config 不会被并发修改
+	a.mu.Lock()
+	updated := *a.cfg()
+	changed, ignored := applyReloadedConfig(&updated, next)
+	a.config.Store(&updated)
+	a.mu.Unlock()
+
+	if len(ignored) > 0 {
+		logger.Warnf("[Config] 以下配置项需要重启才能生效，已忽略: %s", strings.Join(ignored, ", "))
+	}
+	if len(changed) == 0 {
+		logger.Infof("[Config] 配置已重新加载，无变更")
+		return
+	}
+	for _, c := range changed {
+		logger.Infof("[Config] %s", c)
+	}
+
+	logger.Configure(a.cfg())
+
+	// 通知 reportLoop 按新的间隔重置定时器
+	select {
+	case a.reloadChan <- struct{}{}:
+	default:
+	}
+}
+
+// reportIntervals 当前的状态/主机信息上报间隔
+func (a *AgentClient) reportIntervals() (state, hostInfo time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Duration(a.cfg().ReportInterval) * time.Millisecond,
+		time.Duration(a.cfg().HostInfoInterval) * time.Millisecond
+}
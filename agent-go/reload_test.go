@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestReloadConfigConcurrentWithCollect 重新加载与采集并发执行 (配合 go test -race 检查数据竞争)
+func TestReloadConfigConcurrentWithCollect(t *testing.T) {
+	config := newDefaultConfig()
+	config.ServerID = "test"
+	config.AgentKey = "test"
+	config.EnableGPU = false
+	config.EnableDocker = false
+	config.EnablePublicIP = false
+	config.NTPServer = ""
+	config.DNSProbeHost = ""
+	a := NewAgentClient(config)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 3; i++ {
+			a.collector.CollectState()
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		next := *config
+		next.ReportInterval = 1000 + i
+		next.StateFields = []string{"cpu"}
+		next.TopProcessCount = i % 3
+		a.ReloadConfig(&next)
+	}
+	wg.Wait()
+
+	if got := a.cfg().ReportInterval; got != 1019 {
+		t.Errorf("ReportInterval = %d, want 1019", got)
+	}
+	if a.collector.cfg() != a.cfg() {
+		t.Errorf("collector 与 AgentClient 的配置不一致")
+	}
+	if config.ReportInterval != 1500 {
+		t.Errorf("重新加载修改了旧的配置快照: ReportInterval = %d", config.ReportInterval)
+	}
+}
+
+// TestApplyReloadedConfigCoversAllFields 每个配置项都必须热更新或列为需要重启，不能被静默忽略
+func TestApplyReloadedConfigCoversAllFields(t *testing.T) {
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		t.Run(field.Name, func(t *testing.T) {
+			cur := newDefaultConfig()
+			next := *cur
+			setDifferentValue(t, reflect.ValueOf(&next).Elem().Field(i))
+
+			changed, ignored := applyReloadedConfig(cur, &next)
+			if len(changed)+len(ignored) != 1 {
+				t.Fatalf("修改 %s 后 changed=%v ignored=%v，应恰好报告一项", field.Name, changed, ignored)
+			}
+			if len(changed) == 1 && !reflect.DeepEqual(reflect.ValueOf(cur).Elem().Field(i).Interface(), reflect.ValueOf(next).Field(i).Interface()) {
+				t.Errorf("%s 报告为已更新但没有应用", field.Name)
+			}
+		})
+	}
+}
+
+// setDifferentValue 将字段修改为与默认值不同的值
+func setDifferentValue(t *testing.T, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int64:
+		v.SetInt(v.Int() + 7)
+	case reflect.Float64:
+		v.SetFloat(v.Float() + 7)
+	case reflect.String:
+		v.SetString(v.String() + "x")
+	case reflect.Slice:
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if elem.Kind() == reflect.String {
+			elem.SetString("x")
+		}
+		v.Set(reflect.Append(reflect.MakeSlice(v.Type(), 0, 1), elem))
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		m.SetMapIndex(reflect.ValueOf("KEY").Convert(v.Type().Key()), reflect.New(v.Type().Elem()).Elem())
+		v.Set(m)
+	default:
+		t.Fatalf("不支持的字段类型 %s", v.Type())
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyReload Windows 没有 SIGHUP，不支持信号触发的配置重载
+func notifyReload(c chan<- os.Signal) {}
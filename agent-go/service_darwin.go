@@ -0,0 +1,188 @@
+//go:build darwin
+// +build darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const launchdLabel = "com.apimonitor.agent"
+const launchdPlistPath = "/Library/LaunchDaemons/" + launchdLabel + ".plist"
+
+// serviceEnvVars 安装时写入 plist 的环境变量 (与 main 中的环境变量覆盖一致)
+var serviceEnvVars = []string{"API_MONITOR_SERVER", "API_MONITOR_SERVER_ID", "API_MONITOR_KEY", "API_MONITOR_HOSTNAME"}
+
+// IsRunningAsService macOS 下由 launchd 直接运行普通进程，始终返回 false
+func IsRunningAsService() bool {
+	return false
+}
+
+// RunAsService macOS 下不需要特殊的服务模式
+func RunAsService() {
+	fmt.Println("macOS 下请使用 install 安装 launchd 服务，服务直接以普通模式运行")
+}
+
+// InstallService 生成 LaunchDaemon plist 并加载 (开机自启，退出后自动拉起)
+// configPath 非空时作为 --config 参数写入 ProgramArguments
+func InstallService(configPath string) error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取程序路径失败: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	if _, err := os.Stat(launchdPlistPath); err == nil {
+		return fmt.Errorf("服务已存在: %s", launchdPlistPath)
+	}
+
+	// LaunchDaemon plist 必须属于 root 且不可被其他用户写入
+	// launchd 没有 EnvironmentFile，EnvironmentVariables 中可能包含 agentKey，因此只允许 root 读取 (0600)
+	if err := os.WriteFile(launchdPlistPath, []byte(buildLaunchdPlist(exePath, configPath, os.Getenv)), 0600); err != nil {
+		return fmt.Errorf("写入 plist 文件失败: %v", err)
+	}
+	if err := os.Chown(launchdPlistPath, 0, 0); err != nil {
+		return fmt.Errorf("设置 plist 文件所有者失败: %v", err)
+	}
+
+	if err := launchctl("bootstrap", "system", launchdPlistPath); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务安装成功!")
+	fmt.Println("   服务标识:", launchdLabel)
+	fmt.Println("   Plist 文件:", launchdPlistPath, "(0600，包含安装时的环境变量)")
+	fmt.Println("   启动类型: 开机自启 (已启动)")
+	fmt.Println()
+	fmt.Println("使用以下命令管理服务:")
+	fmt.Println("   启动: sudo ./agent start")
+	fmt.Println("   停止: sudo ./agent stop")
+	fmt.Println("   状态: sudo launchctl print system/" + launchdLabel)
+
+	return nil
+}
+
+// buildLaunchdPlist 生成 LaunchDaemon plist 内容，getenv 用于读取需要写入的环境变量
+func buildLaunchdPlist(exePath, configPath string, getenv func(string) string) string {
+	var env strings.Builder
+	for _, name := range serviceEnvVars {
+		if value := getenv(name); value != "" {
+			fmt.Fprintf(&env, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", plistEscape(name), plistEscape(value))
+		}
+	}
+
+	args := fmt.Sprintf("\t\t<string>%s</string>\n", plistEscape(exePath))
+	if configPath != "" {
+		args += fmt.Sprintf("\t\t<string>--config</string>\n\t\t<string>%s</string>\n", plistEscape(configPath))
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+%s	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`, launchdLabel, args, plistEscape(filepath.Dir(exePath)), env.String())
+}
+
+// plistEscape 转义 plist 中的 XML 特殊字符
+func plistEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// UninstallService 卸载 launchd 服务并删除 plist
+func UninstallService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(launchdPlistPath); err != nil {
+		return fmt.Errorf("服务不存在: %v", err)
+	}
+
+	// 先停止服务 (未加载时会失败，不影响删除)
+	launchctl("bootout", "system/"+launchdLabel)
+
+	if err := os.Remove(launchdPlistPath); err != nil {
+		return fmt.Errorf("删除 plist 文件失败: %v", err)
+	}
+
+	fmt.Println("✅ 服务已卸载")
+	return nil
+}
+
+// StartService 启动 launchd 服务 (未加载时先加载)
+func StartService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+
+	if launchctl("print", "system/"+launchdLabel) == nil {
+		if err := launchctl("kickstart", "system/"+launchdLabel); err != nil {
+			return err
+		}
+	} else if err := launchctl("bootstrap", "system", launchdPlistPath); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务已启动")
+	return nil
+}
+
+// StopService 停止 launchd 服务 (KeepAlive 会自动拉起进程，因此需要卸载而不是结束进程)
+func StopService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+	if err := launchctl("bootout", "system/"+launchdLabel); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务已停止")
+	return nil
+}
+
+// requireRoot 服务管理需要 root 权限
+func requireRoot() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("需要 root 权限，请使用 sudo 运行")
+	}
+	return nil
+}
+
+// launchctl 执行 launchctl 命令，失败时附带命令输出
+func launchctl(args ...string) error {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s 失败: %v %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
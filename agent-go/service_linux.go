@@ -0,0 +1,228 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const serviceName = "api-monitor-agent"
+const serviceDescription = "API Monitor 服务器监控代理，用于采集和上报服务器指标"
+const systemdUnitPath = "/etc/systemd/system/" + serviceName + ".service"
+
+// systemdEnvFilePath 保存安装时环境变量的 EnvironmentFile (0600，只有 root 可读)
+// unit 文件对所有用户可读，systemctl show 也会输出 Environment=，agentKey 不能写在 unit 里
+const systemdEnvFilePath = "/etc/" + serviceName + ".env"
+
+// serviceEnvVars 安装时写入 EnvironmentFile 的环境变量 (与 main 中的环境变量覆盖一致)
+var serviceEnvVars = []string{"API_MONITOR_SERVER", "API_MONITOR_SERVER_ID", "API_MONITOR_KEY", "API_MONITOR_HOSTNAME"}
+
+// IsRunningAsService Linux 下由 systemd 直接运行普通进程，始终返回 false
+func IsRunningAsService() bool {
+	return false
+}
+
+// RunAsService Linux 下不需要特殊的服务模式
+func RunAsService() {
+	fmt.Println("Linux 下请使用 install 安装 systemd 服务，服务直接以普通模式运行")
+}
+
+// InstallService 安装 systemd 服务 (开机自启并立即启动)，configPath 非空时写入 --config 参数
+func InstallService(configPath string) error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取程序路径失败: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	if _, err := os.Stat(systemdUnitPath); err == nil {
+		return fmt.Errorf("服务已存在: %s", systemdUnitPath)
+	}
+
+	envFile, err := buildSystemdEnvFile(os.Getenv)
+	if err != nil {
+		return err
+	}
+	envFilePath := ""
+	if envFile != "" {
+		if err := writeSecretFile(systemdEnvFilePath, []byte(envFile)); err != nil {
+			return fmt.Errorf("写入环境变量文件失败: %v", err)
+		}
+		envFilePath = systemdEnvFilePath
+	}
+
+	if err := os.WriteFile(systemdUnitPath, []byte(buildSystemdUnit(exePath, configPath, envFilePath)), 0644); err != nil {
+		return fmt.Errorf("写入 unit 文件失败: %v", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl("enable", "--now", serviceName); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务安装成功!")
+	fmt.Println("   服务名称:", serviceName)
+	fmt.Println("   Unit 文件:", systemdUnitPath)
+	if envFilePath != "" {
+		fmt.Println("   环境变量:", envFilePath, "(0600)")
+	}
+	fmt.Println("   启动类型: 开机自启 (已启动)")
+	fmt.Println()
+	fmt.Println("使用以下命令管理服务:")
+	fmt.Println("   启动: systemctl start", serviceName)
+	fmt.Println("   停止: systemctl stop", serviceName)
+	fmt.Println("   状态: systemctl status", serviceName)
+	fmt.Println("   日志: journalctl -u", serviceName, "-f")
+
+	return nil
+}
+
+// buildSystemdEnvFile 生成 EnvironmentFile 内容 (KEY="value"，没有需要写入的变量时返回空)
+// EnvironmentFile 不展开 % 说明符，双引号内只需转义反斜杠和双引号
+func buildSystemdEnvFile(getenv func(string) string) (string, error) {
+	var env strings.Builder
+	for _, name := range serviceEnvVars {
+		value := getenv(name)
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("环境变量 %s 不能包含换行", name)
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+		fmt.Fprintf(&env, "%s=\"%s\"\n", name, value)
+	}
+	return env.String(), nil
+}
+
+// writeSecretFile 以 0600 写入文件 (文件已存在时同样收紧权限)
+func writeSecretFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// systemdEscape 转义 unit 文件中的 % 说明符，并按 C 风格加引号
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(fmt.Sprintf("%q", s), "%", "%%")
+}
+
+// buildSystemdUnit 生成 systemd unit 文件内容，envFilePath 非空时通过 EnvironmentFile 加载环境变量
+func buildSystemdUnit(exePath, configPath, envFilePath string) string {
+	var env string
+	if envFilePath != "" {
+		env = "EnvironmentFile=" + strings.ReplaceAll(envFilePath, "%", "%%") + "\n"
+	}
+
+	execStart := systemdEscape(exePath)
+	if configPath != "" {
+		execStart += " --config " + systemdEscape(configPath)
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=%s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+WorkingDirectory=%s
+%sRestart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, serviceDescription, execStart, strings.ReplaceAll(filepath.Dir(exePath), "%", "%%"), env)
+}
+
+// UninstallService 停止并卸载 systemd 服务
+func UninstallService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(systemdUnitPath); err != nil {
+		return fmt.Errorf("服务不存在: %v", err)
+	}
+
+	// 先停止并禁用服务 (失败不影响删除)
+	systemctl("disable", "--now", serviceName)
+
+	if err := os.Remove(systemdUnitPath); err != nil {
+		return fmt.Errorf("删除 unit 文件失败: %v", err)
+	}
+	if err := os.Remove(systemdEnvFilePath); err != nil && !os.IsNotExist(err) {
+		fmt.Println("⚠️  删除环境变量文件失败:", err)
+	}
+	systemctl("daemon-reload")
+
+	fmt.Println("✅ 服务已卸载")
+	return nil
+}
+
+// StartService 启动 systemd 服务
+func StartService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+	if err := systemctl("start", serviceName); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务已启动")
+	return nil
+}
+
+// StopService 停止 systemd 服务
+func StopService() error {
+	if err := requireRoot(); err != nil {
+		return err
+	}
+	if err := systemctl("stop", serviceName); err != nil {
+		return err
+	}
+
+	fmt.Println("✅ 服务已停止")
+	return nil
+}
+
+// requireRoot 服务管理需要 root 权限
+func requireRoot() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("需要 root 权限，请使用 sudo 运行")
+	}
+	return nil
+}
+
+// systemctl 执行 systemctl 命令，失败时附带命令输出
+func systemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s 失败: %v %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
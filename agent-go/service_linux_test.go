@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuildSystemdUnitKeepsSecretsOut agentKey 只写入 EnvironmentFile，unit 中的 % 被转义
+func TestBuildSystemdUnitKeepsSecretsOut(t *testing.T) {
+	env := map[string]string{
+		"API_MONITOR_SERVER": "https://dash.example.com",
+		"API_MONITOR_KEY":    `s3cr%t"\key`,
+	}
+	envFile, err := buildSystemdEnvFile(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "API_MONITOR_SERVER=\"https://dash.example.com\"\nAPI_MONITOR_KEY=\"s3cr%t\\\"\\\\key\"\n"
+	if envFile != want {
+		t.Errorf("buildSystemdEnvFile() = %q, want %q", envFile, want)
+	}
+
+	unit := buildSystemdUnit("/opt/agent 100%/agent", "/etc/agent%h.json", systemdEnvFilePath)
+	if strings.Contains(unit, "s3cr") || strings.Contains(unit, "Environment=") {
+		t.Errorf("unit 文件包含环境变量:\n%s", unit)
+	}
+	for _, line := range []string{
+		`ExecStart="/opt/agent 100%%/agent" --config "/etc/agent%%h.json"`,
+		"WorkingDirectory=/opt/agent 100%%",
+		"EnvironmentFile=" + systemdEnvFilePath,
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("unit 文件缺少 %q:\n%s", line, unit)
+		}
+	}
+
+	if unit := buildSystemdUnit("/usr/local/bin/agent", "", ""); strings.Contains(unit, "EnvironmentFile") {
+		t.Errorf("没有环境变量时不应写入 EnvironmentFile:\n%s", unit)
+	}
+	if _, err := buildSystemdEnvFile(func(string) string { return "a\nb" }); err == nil {
+		t.Errorf("包含换行的值未返回错误")
+	}
+}
+
+// TestWriteSecretFile 新建和覆盖已有文件时权限都为 0600
+func TestWriteSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.env")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSecretFile(path, []byte("API_MONITOR_KEY=\"x\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("权限 = %o, want 600", perm)
+	}
+}
@@ -1,4 +1,5 @@
-// +build !windows
+//go:build !windows && !linux && !darwin
+// +build !windows,!linux,!darwin
 
 package main
 
@@ -15,7 +16,7 @@ func RunAsService() {
 }
 
 // InstallService 非 Windows 平台不支持
-func InstallService() error {
+func InstallService(configPath string) error {
 	return fmt.Errorf("Windows 服务模式仅在 Windows 平台可用")
 }
 
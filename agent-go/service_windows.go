@@ -3,11 +3,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"os"
-	"path/filepath"
 	"syscall"
 	"time"
 	"golang.org/x/sys/windows/svc"
@@ -95,7 +92,7 @@ loop:
 	}
 
 	changes <- svc.Status{State: svc.StopPending}
-	s.agent.Stop()
+	s.agent.StopWithTimeout("service stop")
 
 	if elog != nil {
 		elog.Info(1, fmt.Sprintf("%s 服务已停止", serviceName))
@@ -106,25 +103,22 @@ loop:
 
 // loadServiceConfig 从配置文件或注册表加载配置
 func loadServiceConfig() *Config {
-	config := &Config{
-		ServerURL:        "http://localhost:3000",
-		ReportInterval:   1500,
-		HostInfoInterval: 600000,
-		ReconnectDelay:   4000,
-	}
+	config := newDefaultConfig()
 
-	// 获取可执行文件所在目录
-	exePath, err := os.Executable()
-	if err == nil {
-		configPath := filepath.Join(filepath.Dir(exePath), "config.json")
-		if data, err := os.ReadFile(configPath); err == nil {
-			json.Unmarshal(data, config)
+	// 安装时通过 install -c 指定的配置文件会作为服务启动参数传入，否则使用程序所在目录的 config.json
+	if configPath := configPathFromArgs(os.Args[1:]); configPath != "" {
+		if err := loadConfigFile(configPath, config); err != nil {
+			logger.Errorf("[Service] 加载配置文件失败: %v", err)
+			return nil
 		}
+	} else {
+		loadConfigFile(defaultConfigPath(), config)
 	}
 
 	// 环境变量覆盖
 	if env := os.Getenv("API_MONITOR_SERVER"); env != "" {
 		config.ServerURL = env
+		config.ServerURLs = nil
 	}
 	if env := os.Getenv("API_MONITOR_SERVER_ID"); env != "" {
 		config.ServerID = env
@@ -132,11 +126,16 @@ func loadServiceConfig() *Config {
 	if env := os.Getenv("API_MONITOR_KEY"); env != "" {
 		config.AgentKey = env
 	}
+	if env := os.Getenv("API_MONITOR_HOSTNAME"); env != "" {
+		config.Hostname = env
+	}
 
 	// 验证必要配置
 	if config.ServerID == "" || config.AgentKey == "" {
 		return nil
 	}
+	clampReportInterval(config)
+	logger.Configure(config)
 
 	return config
 }
@@ -145,12 +144,12 @@ func loadServiceConfig() *Config {
 func RunAsService() {
 	err := svc.Run(serviceName, &AgentService{})
 	if err != nil {
-		log.Fatalf("服务运行失败: %v", err)
+		logger.Fatalf("[Service] 服务运行失败: %v", err)
 	}
 }
 
-// InstallService 安装 Windows 服务
-func InstallService() error {
+// InstallService 安装 Windows 服务，configPath 非空时作为服务启动参数持久化
+func InstallService(configPath string) error {
 	exePath, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("获取程序路径失败: %v", err)
@@ -168,11 +167,16 @@ func InstallService() error {
 		return fmt.Errorf("服务已存在")
 	}
 
+	args := []string{"service"}
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+
 	s, err = m.CreateService(serviceName, exePath, mgr.Config{
 		DisplayName: serviceDisplayName,
 		Description: serviceDescription,
 		StartType:   mgr.StartAutomatic,
-	}, "service")
+	}, args...)
 	if err != nil {
 		return fmt.Errorf("创建服务失败: %v", err)
 	}
@@ -185,13 +189,13 @@ func InstallService() error {
 		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
 	}, 86400) // 24小时后重置失败计数
 	if err != nil {
-		log.Printf("设置恢复选项失败: %v", err)
+		logger.Warnf("[Service] 设置恢复选项失败: %v", err)
 	}
 
 	// 安装事件日志源
 	err = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info)
 	if err != nil {
-		log.Printf("安装事件日志源失败: %v", err)
+		logger.Warnf("[Service] 安装事件日志源失败: %v", err)
 	}
 
 	fmt.Println("✅ 服务安装成功!")
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"time"
+)
+
+// SMART 采集间隔: 健康状态变化缓慢，且 smartctl 每次都要向磁盘下发命令
+const smartInterval = 5 * time.Minute
+
+// smartctlTimeout 单次 smartctl 调用的超时 (部分 RAID 卡/USB 桥接芯片上可能长时间无响应)
+const smartctlTimeout = 10 * time.Second
+
+// DiskHealthStat 单块物理磁盘的 SMART 健康状态
+type DiskHealthStat struct {
+	Device       string  `json:"device"`         // 设备路径 (如 /dev/sda)
+	Model        string  `json:"model"`          // 型号
+	Health       string  `json:"health"`         // PASSED / FAILED
+	Temperature  float64 `json:"temperature"`    // 当前温度 (°C)，未知为 0
+	PowerOnHours uint64  `json:"power_on_hours"` // 通电时长 (小时)
+}
+
+// smartctlOutput smartctl --json 输出中用到的字段
+type smartctlOutput struct {
+	Device struct {
+		Name string `json:"name"`
+	} `json:"device"`
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+}
+
+// parseSmartctlOutput 解析 smartctl --json -H -A 的输出
+// 没有 smart_status 时 (无权限、设备不支持或处于待机状态) 返回 false
+func parseSmartctlOutput(data []byte) (DiskHealthStat, bool) {
+	var out smartctlOutput
+	if err := json.Unmarshal(data, &out); err != nil || out.SmartStatus == nil {
+		return DiskHealthStat{}, false
+	}
+	health := "FAILED"
+	if out.SmartStatus.Passed {
+		health = "PASSED"
+	}
+	return DiskHealthStat{
+		Device:       out.Device.Name,
+		Model:        out.ModelName,
+		Health:       health,
+		Temperature:  out.Temperature.Current,
+		PowerOnHours: out.PowerOnTime.Hours,
+	}, true
+}
+
+// parseSmartctlScan 解析 smartctl --scan --json，返回设备路径和类型
+func parseSmartctlScan(data []byte) [][2]string {
+	var scan struct {
+		Devices []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"devices"`
+	}
+	if err := json.Unmarshal(data, &scan); err != nil {
+		return nil
+	}
+	devices := make([][2]string, 0, len(scan.Devices))
+	for _, d := range scan.Devices {
+		if d.Name != "" {
+			devices = append(devices, [2]string{d.Name, d.Type})
+		}
+	}
+	return devices
+}
+
+// runSmartctl 执行 smartctl，返回输出
+// smartctl 的退出码是状态位掩码 (如磁盘已报告故障时非 0)，因此只要有输出就交给解析函数判断
+func runSmartctl(smartctl string, args ...string) []byte {
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	output, _ := exec.CommandContext(ctx, smartctl, args...).Output()
+	return output
+}
+
+// collectDiskHealth 通过 smartctl 采集所有物理磁盘的健康状态
+// 未安装 smartctl 或没有权限 (需要 root) 时返回空列表
+func collectDiskHealth() []DiskHealthStat {
+	smartctl, err := exec.LookPath("smartctl")
+	if err != nil {
+		return []DiskHealthStat{}
+	}
+
+	result := []DiskHealthStat{}
+	for _, dev := range parseSmartctlScan(runSmartctl(smartctl, "--scan", "--json")) {
+		// -n standby: 磁盘处于待机状态时不唤醒
+		args := []string{"--json", "-H", "-A", "-n", "standby"}
+		if dev[1] != "" {
+			args = append(args, "-d", dev[1])
+		}
+		stat, ok := parseSmartctlOutput(runSmartctl(smartctl, append(args, dev[0])...))
+		if !ok {
+			logger.Debugf("[SMART] 无法读取 %s 的 SMART 信息 (权限不足、不支持或处于待机)", dev[0])
+			continue
+		}
+		if stat.Device == "" {
+			stat.Device = dev[0]
+		}
+		result = append(result, stat)
+	}
+	return result
+}
+
+// collectSMARTState 磁盘 SMART 健康状态 (带节流缓存，每 smartInterval 采集一次)
+func (c *Collector) collectSMARTState() func(*State) {
+	c.mu.Lock()
+	if time.Since(c.lastSMARTTime) < smartInterval {
+		health := c.lastDiskHealth
+		c.mu.Unlock()
+		return func(s *State) { s.DiskHealth = health }
+	}
+	c.lastSMARTTime = time.Now()
+	c.mu.Unlock()
+
+	health := collectDiskHealth()
+
+	c.mu.Lock()
+	c.lastDiskHealth = health
+	c.mu.Unlock()
+	return func(s *State) { s.DiskHealth = health }
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// 测速参数
+const (
+	speedtestPhaseDuration   = 10 * time.Second  // 下载/上传各自最长持续时间
+	speedtestMaxUploadBytes  = 100 * 1024 * 1024 // 上传最多发送的数据量
+	defaultSpeedtestCooldown = 600               // 秒，两次测速的最小间隔
+)
+
+// SpeedtestResult 测速结果
+type SpeedtestResult struct {
+	Method       string  `json:"method"` // http (配置的测速地址) / speedtest (Ookla CLI) / speedtest-cli
+	DownloadMbps float64 `json:"download_mbps"`
+	UploadMbps   float64 `json:"upload_mbps"` // 未配置上传地址时为 0
+	LatencyMs    float64 `json:"latency_ms"`
+	Server       string  `json:"server,omitempty"`
+}
+
+// handleSpeedtest 执行一次测速 (只能由任务触发，带冷却时间防止被反复调用占满带宽)
+func (a *AgentClient) handleSpeedtest(ctx context.Context) (*SpeedtestResult, error) {
+	cooldown := time.Duration(a.cfg().SpeedtestCooldown) * time.Second
+	if a.cfg().SpeedtestCooldown <= 0 {
+		cooldown = defaultSpeedtestCooldown * time.Second
+	}
+
+	a.mu.Lock()
+	if a.speedtestRunning {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("已有测速正在进行")
+	}
+	if wait := cooldown - time.Since(a.lastSpeedtest); wait > 0 {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("测速冷却中，请 %.0f 秒后再试", wait.Seconds())
+	}
+	a.speedtestRunning = true
+	a.lastSpeedtest = time.Now()
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.speedtestRunning = false
+		a.mu.Unlock()
+	}()
+
+	if a.cfg().SpeedtestURL != "" {
+		return httpSpeedtest(ctx, a.cfg())
+	}
+	return cliSpeedtest(ctx)
+}
+
+// httpSpeedtest 使用配置的地址测速: GET speedtestUrl 测下载，POST speedtestUploadUrl 测上传
+func httpSpeedtest(ctx context.Context, config *Config) (*SpeedtestResult, error) {
+	transport := &http.Transport{DisableCompression: true}
+	if err := applyTransportProxy(config, transport); err != nil {
+		return nil, fmt.Errorf("代理配置无效: %v", err)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	result := &SpeedtestResult{Method: "http", Server: config.SpeedtestURL}
+
+	// 延迟: 下载请求的首字节时间
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.SpeedtestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的测速地址: %v", err)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载测速失败: %v", err)
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载测速失败: HTTP %d", resp.StatusCode)
+	}
+
+	// 下载: 读取响应体直到结束或达到时长上限
+	body := &deadlineReader{r: resp.Body, deadline: time.Now().Add(speedtestPhaseDuration)}
+	start = time.Now()
+	n, _ := io.Copy(io.Discard, body)
+	resp.Body.Close()
+	result.DownloadMbps = mbps(n, time.Since(start))
+
+	// 上传: 在时长上限内持续发送数据
+	if config.SpeedtestUploadURL != "" {
+		upload := &countingReader{limit: speedtestMaxUploadBytes, deadline: time.Now().Add(speedtestPhaseDuration)}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.SpeedtestUploadURL, upload)
+		if err != nil {
+			return nil, fmt.Errorf("无效的上传测速地址: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		start = time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("上传测速失败: %v", err)
+		}
+		resp.Body.Close()
+		result.UploadMbps = mbps(upload.n, time.Since(start))
+	}
+	return result, nil
+}
+
+// cliSpeedtest 未配置测速地址时调用已安装的 speedtest CLI (Ookla speedtest 或 Python speedtest-cli)
+func cliSpeedtest(ctx context.Context) (*SpeedtestResult, error) {
+	if path, err := exec.LookPath("speedtest"); err == nil {
+		output, err := exec.CommandContext(ctx, path, "--format=json", "--accept-license", "--accept-gdpr").Output()
+		if err != nil {
+			return nil, fmt.Errorf("speedtest 执行失败: %v", err)
+		}
+		return parseOoklaSpeedtest(output)
+	}
+	if path, err := exec.LookPath("speedtest-cli"); err == nil {
+		output, err := exec.CommandContext(ctx, path, "--json").Output()
+		if err != nil {
+			return nil, fmt.Errorf("speedtest-cli 执行失败: %v", err)
+		}
+		return parseSpeedtestCLI(output)
+	}
+	return nil, fmt.Errorf("未配置 speedtestUrl，且未安装 speedtest / speedtest-cli")
+}
+
+// parseOoklaSpeedtest 解析 Ookla speedtest --format=json (带宽单位为 bytes/s)
+func parseOoklaSpeedtest(data []byte) (*SpeedtestResult, error) {
+	var out struct {
+		Ping struct {
+			Latency float64 `json:"latency"`
+		} `json:"ping"`
+		Download struct {
+			Bandwidth float64 `json:"bandwidth"`
+		} `json:"download"`
+		Upload struct {
+			Bandwidth float64 `json:"bandwidth"`
+		} `json:"upload"`
+		Server struct {
+			Name     string `json:"name"`
+			Location string `json:"location"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析 speedtest 输出失败: %v", err)
+	}
+	return &SpeedtestResult{
+		Method:       "speedtest",
+		DownloadMbps: out.Download.Bandwidth * 8 / 1e6,
+		UploadMbps:   out.Upload.Bandwidth * 8 / 1e6,
+		LatencyMs:    out.Ping.Latency,
+		Server:       out.Server.Name + " " + out.Server.Location,
+	}, nil
+}
+
+// parseSpeedtestCLI 解析 speedtest-cli --json (带宽单位为 bit/s)
+func parseSpeedtestCLI(data []byte) (*SpeedtestResult, error) {
+	var out struct {
+		Download float64 `json:"download"`
+		Upload   float64 `json:"upload"`
+		Ping     float64 `json:"ping"`
+		Server   struct {
+			Sponsor string `json:"sponsor"`
+			Name    string `json:"name"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析 speedtest-cli 输出失败: %v", err)
+	}
+	return &SpeedtestResult{
+		Method:       "speedtest-cli",
+		DownloadMbps: out.Download / 1e6,
+		UploadMbps:   out.Upload / 1e6,
+		LatencyMs:    out.Ping,
+		Server:       out.Server.Sponsor + " " + out.Server.Name,
+	}, nil
+}
+
+// mbps 根据传输字节数和耗时计算 Mbps
+func mbps(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / elapsed.Seconds() / 1e6
+}
+
+// deadlineReader 到达截止时间后返回 EOF
+type deadlineReader struct {
+	r        io.Reader
+	deadline time.Time
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if time.Now().After(d.deadline) {
+		return 0, io.EOF
+	}
+	return d.r.Read(p)
+}
+
+// countingReader 生成上传数据，达到数据量或截止时间后返回 EOF
+type countingReader struct {
+	n        int64
+	limit    int64
+	deadline time.Time
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.n >= c.limit || time.Now().After(c.deadline) {
+		return 0, io.EOF
+	}
+	if remaining := c.limit - c.n; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	for i := range p {
+		p[i] = byte(i)
+	}
+	c.n += int64(len(p))
+	return len(p), nil
+}
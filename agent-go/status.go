@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusResponse /status 接口返回的内容
+type statusResponse struct {
+	Version       string          `json:"version"`
+	ServerID      string          `json:"server_id"`
+	Connected     bool            `json:"connected"`
+	Authenticated bool            `json:"authenticated"`
+	StartedAt     time.Time       `json:"started_at"`
+	StateTime     time.Time       `json:"state_time,omitempty"`
+	Connection    connectionStats `json:"connection"`
+	HostInfo      *HostInfo       `json:"host_info"`
+	State         *State          `json:"state"`
+}
+
+// startStatusServer 启动本地状态接口 (/healthz、/status、/metrics)，StatusAddr 为空时不启动
+func (a *AgentClient) startStatusServer() {
+	if a.cfg().StatusAddr == "" {
+		if a.cfg().PrometheusEnabled {
+			logger.Warnf("[Status] 已开启 prometheusEnabled 但未配置 statusAddr，/metrics 不可用")
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/status", a.handleStatus)
+	if a.cfg().PrometheusEnabled {
+		mux.HandleFunc("/metrics", a.handleMetrics)
+	}
+
+	listener, err := net.Listen("tcp", a.cfg().StatusAddr)
+	if err != nil {
+		logger.Errorf("[Status] 监听 %s 失败: %v", a.cfg().StatusAddr, err)
+		return
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	a.mu.Lock()
+	a.statusServer = server
+	a.mu.Unlock()
+
+	logger.Infof("[Status] 状态接口已启动: http://%s", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("[Status] 状态接口异常退出: %v", err)
+		}
+	}()
+}
+
+// stopStatusServer 关闭本地状态接口
+func (a *AgentClient) stopStatusServer() {
+	a.mu.Lock()
+	server := a.statusServer
+	a.statusServer = nil
+	a.mu.Unlock()
+
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
+
+// isHealthy 已连接且认证成功时视为健康
+func (a *AgentClient) isHealthy() (connected, authenticated bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conn != nil, a.authenticated
+}
+
+func (a *AgentClient) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	connected, authenticated := a.isHealthy()
+	if !connected || !authenticated {
+		http.Error(w, "disconnected", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (a *AgentClient) handleStatus(w http.ResponseWriter, r *http.Request) {
+	connected, authenticated := a.isHealthy()
+	connStats := a.connectionStats()
+
+	a.mu.Lock()
+	resp := statusResponse{
+		Version:       VERSION,
+		ServerID:      a.cfg().ServerID,
+		Connected:     connected,
+		Authenticated: authenticated,
+		StartedAt:     a.startedAt,
+		StateTime:     a.lastStateTime,
+		Connection:    connStats,
+		HostInfo:      a.lastHostInfo,
+		State:         a.lastState,
+	}
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
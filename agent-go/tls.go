@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+)
+
+// certWatchInterval 证书文件变更检查间隔
+const certWatchInterval = 30 * time.Second
+
+// loadTLSConfig 根据配置加载客户端证书 (mTLS)、自定义 CA 和证书校验开关，未配置时返回 nil (使用系统默认)
+// 返回的配置同时用于握手 HTTP 请求和 WebSocket 连接
+func loadTLSConfig(config *Config) (*tls.Config, error) {
+	if config.ClientCertFile == "" && config.CACertFile == "" && !config.TLSSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.TLSSkipVerify, // 仅用于测试环境 (启动时会输出警告)
+	}
+
+	if config.ClientCertFile != "" {
+		keyFile := config.ClientKeyFile
+		if keyFile == "" {
+			keyFile = config.ClientCertFile // 证书与私钥在同一个 PEM 文件中
+		}
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CACertFile != "" {
+		data, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA 证书中没有有效的 PEM 证书: %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// certFilesModTime 返回证书相关文件的最新修改时间
+func certFilesModTime(config *Config) time.Time {
+	var latest time.Time
+	for _, path := range []string{config.ClientCertFile, config.ClientKeyFile, config.CACertFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// watchCertificates 监视证书文件，变更后重新加载并触发一次干净的重连
+// (已建立的连接无法中途更换证书，只能重连使新证书生效)
+func (a *AgentClient) watchCertificates() {
+	lastModTime := certFilesModTime(a.cfg())
+
+	ticker := time.NewTicker(certWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		modTime := certFilesModTime(a.cfg())
+		if !modTime.After(lastModTime) {
+			continue
+		}
+
+		tlsConfig, err := loadTLSConfig(a.cfg())
+		if err != nil {
+			// 证书可能正在写入，下个周期重试
+			logger.Warnf("[TLS] 证书已变更但加载失败: %v", err)
+			continue
+		}
+		lastModTime = modTime
+
+		a.mu.Lock()
+		a.tlsConfig = tlsConfig
+		conn := a.conn
+		a.mu.Unlock()
+
+		logger.Infof("[TLS] 证书已重新加载，正在重连以使用新证书...")
+		if conn != nil {
+			a.noteDisconnect("certificate_reload")
+			conn.Close()
+		}
+	}
+}
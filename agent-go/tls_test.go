@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newSelfSignedServer 启动使用自签名证书的 HTTPS 服务，/ 返回 200，/ws 接受 WebSocket 连接
+func newSelfSignedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	// 校验失败的握手是预期行为，不输出服务端日志
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeServerCA 将测试服务器的自签名证书写入 PEM 文件
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadTLSConfigSelfSigned 握手 HTTP 请求和 WebSocket 连接都使用 loadTLSConfig 的结果
+func TestLoadTLSConfigSelfSigned(t *testing.T) {
+	server := newSelfSignedServer(t)
+	caFile := writeServerCA(t, server)
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"默认校验拒绝自签名证书", Config{}, true},
+		{"tlsSkipVerify", Config{TLSSkipVerify: true}, false},
+		{"caCertFile", Config{CACertFile: caFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := loadTLSConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("loadTLSConfig() error = %v", err)
+			}
+
+			client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HTTP 请求 error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second, TLSClientConfig: tlsConfig}
+			conn, _, err := dialer.Dial("wss://"+strings.TrimPrefix(server.URL, "https://")+"/ws", nil)
+			if err == nil {
+				conn.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WebSocket 连接 error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestLoadTLSConfigUnset 未配置任何 TLS 选项时返回 nil，使用系统默认配置
+func TestLoadTLSConfigUnset(t *testing.T) {
+	tlsConfig, err := loadTLSConfig(&Config{})
+	if err != nil || tlsConfig != nil {
+		t.Errorf("loadTLSConfig() = %v, %v, want nil, nil", tlsConfig, err)
+	}
+}
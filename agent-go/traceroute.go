@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// 路由跟踪的跳数限制
+const (
+	tracerouteDefaultHops = 30
+	tracerouteMaxHops     = 64
+)
+
+// TracerouteRequest 路由跟踪任务参数 (也可以直接传目标主机)
+type TracerouteRequest struct {
+	Target  string `json:"target"`
+	MaxHops int    `json:"max_hops"` // 最大跳数，默认 30，最多 64
+}
+
+// TraceHop 路由中的一跳
+type TraceHop struct {
+	Hop     int       `json:"hop"`
+	Address string    `json:"address,omitempty"` // 响应的路由器地址，所有探测都超时时为空
+	RTTs    []float64 `json:"rtts"`              // 每次探测的往返延迟 (毫秒)，-1 表示超时
+}
+
+// TracerouteResult 路由跟踪结果
+type TracerouteResult struct {
+	Target   string     `json:"target"`
+	Hops     []TraceHop `json:"hops"`
+	TimedOut bool       `json:"timed_out,omitempty"` // 任务超时，hops 只包含超时前已完成的部分
+}
+
+// traceHopPattern 以跳数开头的输出行
+var traceHopPattern = regexp.MustCompile(`^\s*(\d+)\s+(.*)$`)
+
+// runTraceroute 调用系统 traceroute (Windows 为 tracert) 跟踪到目标的路由，超时由 ctx 控制
+func runTraceroute(ctx context.Context, data string) (*TracerouteResult, error) {
+	var req TracerouteRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		// 兼容直接传主机名
+		req.Target = data
+	}
+	req.Target = strings.TrimSpace(req.Target)
+	// 目标作为命令行参数传入，拒绝以 - 开头或包含空白的值，避免被解析为选项
+	if req.Target == "" || strings.HasPrefix(req.Target, "-") || strings.ContainsAny(req.Target, " \t\r\n") {
+		return nil, fmt.Errorf("无效的目标地址: %q", req.Target)
+	}
+	if req.MaxHops <= 0 {
+		req.MaxHops = tracerouteDefaultHops
+	}
+	if req.MaxHops > tracerouteMaxHops {
+		req.MaxHops = tracerouteMaxHops
+	}
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, req.Target); err != nil {
+		return nil, fmt.Errorf("DNS 解析失败: %v", err)
+	}
+
+	hops := strconv.Itoa(req.MaxHops)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "tracert", "-d", "-h", hops, "-w", "2000", req.Target)
+	} else {
+		cmd = exec.CommandContext(ctx, "traceroute", "-n", "-m", hops, "-w", "2", "-q", "3", req.Target)
+	}
+	hideWindow(cmd)
+
+	// 超时被终止时仍返回已经输出的部分
+	output, err := cmd.CombinedOutput()
+	result := &TracerouteResult{Target: req.Target, Hops: parseTraceroute(string(output))}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		return result, nil
+	}
+	if err != nil && len(result.Hops) == 0 {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("未找到 traceroute 命令")
+		}
+		return nil, fmt.Errorf("traceroute 失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return result, nil
+}
+
+// parseTraceroute 解析 traceroute -n / tracert -d 的输出
+// 每一跳形如 " 3  10.0.0.1  1.234 ms  1.101 ms *" 或 "  3    <1 ms     1 ms    *     10.0.0.1"
+func parseTraceroute(output string) []TraceHop {
+	var hops []TraceHop
+	for _, line := range strings.Split(output, "\n") {
+		match := traceHopPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		hop := TraceHop{Hop: n, RTTs: []float64{}}
+
+		fields := strings.Fields(match[2])
+		for i, field := range fields {
+			switch {
+			case field == "*":
+				hop.RTTs = append(hop.RTTs, -1)
+			case i+1 < len(fields) && fields[i+1] == "ms":
+				if rtt, err := strconv.ParseFloat(strings.TrimPrefix(field, "<"), 64); err == nil {
+					hop.RTTs = append(hop.RTTs, rtt)
+				}
+			case strings.HasSuffix(field, "ms"):
+				if rtt, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSuffix(field, "ms"), "<"), 64); err == nil {
+					hop.RTTs = append(hop.RTTs, rtt)
+				}
+			case hop.Address == "":
+				if ip := net.ParseIP(strings.Trim(field, "[]()")); ip != nil {
+					hop.Address = ip.String()
+				}
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gorilla/websocket"
+)
+
+// 传输方式 (Config.Transport)
+const (
+	transportAuto      = "auto"      // 优先 WebSocket，升级失败时回退到 HTTP 长轮询
+	transportWebSocket = "websocket" // 只使用 WebSocket
+	transportPolling   = "polling"   // 只使用 HTTP 长轮询 (代理/WAF 拦截 WebSocket 时)
+)
+
+// agentConn Socket.IO 底层连接 (*websocket.Conn 或 HTTP 长轮询)，按帧收发文本消息
+type agentConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// pollingConn 基于 Engine.IO polling 传输的连接: 后台 GET 长轮询接收，POST 发送
+// 与握手共用同一个 sid，服务端在 pingInterval 内没有数据时也会返回 (ping 包)
+type pollingConn struct {
+	client   *http.Client
+	url      string // 带 sid 的 polling 地址
+	eio      int
+	incoming chan string
+	closed   chan struct{}
+
+	closeOnce sync.Once
+	writeMu   sync.Mutex // 服务端不允许同时存在多个 POST 请求
+
+	mu       sync.Mutex
+	err      error     // 长轮询失败的原因
+	deadline time.Time // 读超时
+}
+
+// pollingWriteTimeout 单次 POST 的超时
+const pollingWriteTimeout = wsWriteTimeout
+
+// newPollingConn 在握手得到的 sid 上建立长轮询连接，pollTimeout 应大于 pingInterval
+func newPollingConn(transport http.RoundTripper, pollURL string, eio int, pollTimeout time.Duration) *pollingConn {
+	p := &pollingConn{
+		client:   &http.Client{Timeout: pollTimeout, Transport: transport},
+		url:      pollURL,
+		eio:      eio,
+		incoming: make(chan string, 64),
+		closed:   make(chan struct{}),
+	}
+	go p.pollLoop()
+	return p
+}
+
+// pollLoop 持续发起 GET 请求并把 payload 拆成单个包
+func (p *pollingConn) pollLoop() {
+	for {
+		select {
+		case <-p.closed:
+			return
+		default:
+		}
+
+		packets, err := p.poll()
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		for _, packet := range packets {
+			// 6 (noop) 只用于结束挂起的轮询
+			if packet == "6" {
+				continue
+			}
+			select {
+			case p.incoming <- packet:
+			case <-p.closed:
+				return
+			}
+		}
+	}
+}
+
+func (p *pollingConn) poll() ([]string, error) {
+	resp, err := p.client.Get(p.url + "&t=" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("长轮询失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return decodePollingPayload(string(body), p.eio)
+}
+
+// fail 记录长轮询失败原因并关闭连接
+func (p *pollingConn) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.closeOnce.Do(func() { close(p.closed) })
+}
+
+// ReadMessage 返回下一个包，超过读超时返回错误
+func (p *pollingConn) ReadMessage() (int, []byte, error) {
+	p.mu.Lock()
+	deadline := p.deadline
+	p.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case packet := <-p.incoming:
+		return websocket.TextMessage, []byte(packet), nil
+	case <-p.closed:
+		p.mu.Lock()
+		err := p.err
+		p.mu.Unlock()
+		if err == nil {
+			err = fmt.Errorf("连接已关闭")
+		}
+		return 0, nil, err
+	case <-timeout:
+		return 0, nil, fmt.Errorf("长轮询读取超时")
+	}
+}
+
+// WriteMessage 以 POST 发送一个包
+func (p *pollingConn) WriteMessage(_ int, data []byte) error {
+	select {
+	case <-p.closed:
+		return fmt.Errorf("连接已关闭")
+	default:
+	}
+	return p.post(encodePollingPayload([]string{string(data)}, p.eio))
+}
+
+func (p *pollingConn) post(payload string) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pollingWriteTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewBufferString(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("发送失败: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (p *pollingConn) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	p.deadline = t
+	p.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline 每次 POST 使用固定的超时，这里无需处理
+func (p *pollingConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// Close 通知服务端关闭会话 (close 包) 并停止长轮询
+func (p *pollingConn) Close() error {
+	first := false
+	p.closeOnce.Do(func() {
+		first = true
+		close(p.closed)
+	})
+	if first {
+		p.post(encodePollingPayload([]string{"1"}, p.eio))
+	}
+	return nil
+}
+
+// encodePollingPayload 编码 polling payload: v4 以 \x1e 分隔，v3 为 <长度>:<包> 依次拼接
+func encodePollingPayload(packets []string, eio int) string {
+	if eio != engineIOv3 {
+		return strings.Join(packets, "\x1e")
+	}
+	var b strings.Builder
+	for _, packet := range packets {
+		b.WriteString(strconv.Itoa(utf16Len(packet)))
+		b.WriteByte(':')
+		b.WriteString(packet)
+	}
+	return b.String()
+}
+
+// decodePollingPayload 解码 polling payload，v3 的长度以 UTF-16 码元计 (与 JS 字符串长度一致)
+func decodePollingPayload(payload string, eio int) ([]string, error) {
+	if payload == "" {
+		return nil, nil
+	}
+	if eio != engineIOv3 {
+		return strings.Split(payload, "\x1e"), nil
+	}
+
+	var packets []string
+	units := utf16.Encode([]rune(payload))
+	for len(units) > 0 {
+		colon := -1
+		for i, u := range units {
+			if u == ':' {
+				colon = i
+				break
+			}
+		}
+		if colon <= 0 {
+			return nil, fmt.Errorf("无效的 polling payload")
+		}
+		length, err := strconv.Atoi(string(utf16.Decode(units[:colon])))
+		if err != nil || length < 0 || colon+1+length > len(units) {
+			return nil, fmt.Errorf("无效的 polling payload")
+		}
+		packets = append(packets, string(utf16.Decode(units[colon+1:colon+1+length])))
+		units = units[colon+1+length:]
+	}
+	return packets, nil
+}
+
+// utf16Len 字符串的 UTF-16 长度
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// selfUpdateTimeout 下载新版本的最长时间
+const selfUpdateTimeout = 10 * time.Minute
+
+// SelfUpdateRequest 自更新请求
+type SelfUpdateRequest struct {
+	URL    string `json:"url"`    // 新版本二进制下载地址
+	SHA256 string `json:"sha256"` // 二进制的 SHA256 (十六进制)
+}
+
+// validateSelfUpdateURL 自更新的下载地址必须为 https，配置了 selfUpdateHosts 时主机名必须在列表中
+func validateSelfUpdateURL(rawURL string, hosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("无效的下载地址: %q", rawURL)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("下载地址必须使用 https: %q", rawURL)
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(host, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("下载主机不在 selfUpdateHosts 中: %s", u.Hostname())
+}
+
+// handleSelfUpdate 下载新版本二进制，校验后原子替换当前程序并重启 (通过进度事件反馈各阶段)
+func (a *AgentClient) handleSelfUpdate(taskID string, data string) {
+	var req SelfUpdateRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		a.sendTaskError(taskID, "解析请求失败: "+err.Error())
+		return
+	}
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if req.URL == "" {
+		a.sendTaskError(taskID, "缺少下载地址")
+		return
+	}
+	if len(req.SHA256) != sha256.Size*2 {
+		a.sendTaskError(taskID, "缺少有效的 SHA256 校验值")
+		return
+	}
+	// SHA256 与地址来自同一个任务，只能保证完整性；来源由 https 和 selfUpdateHosts 保证
+	if err := validateSelfUpdateURL(req.URL, a.cfg().SelfUpdateHosts); err != nil {
+		a.sendTaskError(taskID, err.Error())
+		return
+	}
+
+	progress := &TaskProgress{
+		TaskID:     taskID,
+		Name:       "Agent 自更新",
+		Percentage: 0,
+		Message:    "正在准备...",
+	}
+	a.updateProgress(taskID, progress)
+
+	exePath, err := os.Executable()
+	if err != nil {
+		a.finishWithError(taskID, progress, "获取程序路径失败: "+err.Error())
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	// 1. 下载到程序同目录 (保证后续 rename 在同一文件系统内，是原子操作)
+	progress.Percentage = 10
+	progress.Message = "正在下载新版本..."
+	progress.DetailMsg = req.URL
+	a.updateProgress(taskID, progress)
+
+	newPath := exePath + ".new"
+	checksum, err := a.downloadFile(req.URL, newPath)
+	if err != nil {
+		os.Remove(newPath)
+		a.finishWithError(taskID, progress, "下载失败: "+err.Error())
+		return
+	}
+
+	// 2. 校验 SHA256，不一致时删除下载的文件，当前程序保持不变
+	progress.Percentage = 60
+	progress.Message = "正在校验文件..."
+	progress.DetailMsg = checksum
+	a.updateProgress(taskID, progress)
+
+	if checksum != req.SHA256 {
+		os.Remove(newPath)
+		a.finishWithError(taskID, progress, fmt.Sprintf("SHA256 校验失败: 期望 %s，实际 %s", req.SHA256, checksum))
+		return
+	}
+
+	// 3. 替换程序: 当前程序 -> .old，新版本 -> 当前路径，失败时回滚
+	progress.Percentage = 80
+	progress.Message = "正在替换程序..."
+	a.updateProgress(taskID, progress)
+
+	if err := replaceExecutable(exePath, newPath); err != nil {
+		os.Remove(newPath)
+		a.finishWithError(taskID, progress, "替换程序失败: "+err.Error())
+		return
+	}
+
+	// 4. 完成，先上报结果再重启
+	progress.Percentage = 100
+	progress.Message = "更新完成，正在重启..."
+	progress.DetailMsg = ""
+	progress.IsDone = true
+	a.updateProgress(taskID, progress)
+
+	a.sendTaskResult(map[string]interface{}{
+		"id":         taskID,
+		"successful": true,
+		"data":       "新版本已安装，Agent 正在重启",
+	})
+
+	logger.Infof("[Upgrade] 新版本已安装，正在重启...")
+	if !a.stop("shutdown") {
+		logger.Infof("[Upgrade] Agent 已在关闭中，新版本将在下次启动时生效")
+		return
+	}
+	if err := restartSelf(exePath); err != nil {
+		logger.Warnf("[Upgrade] 重启失败，请手动重启: %v", err)
+		os.Exit(1)
+	}
+}
+
+// downloadFile 下载文件到 dest (可执行权限)，返回内容的 SHA256
+func (a *AgentClient) downloadFile(url, dest string) (string, error) {
+	transport := &http.Transport{}
+	if err := applyTransportProxy(a.cfg(), transport); err != nil {
+		return "", err
+	}
+	hosts := a.cfg().SelfUpdateHosts
+	client := &http.Client{
+		Timeout:   selfUpdateTimeout,
+		Transport: transport,
+		// 重定向后的地址同样需要满足 https 和主机限制
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("重定向次数过多")
+			}
+			return validateSelfUpdateURL(r.URL.String(), hosts)
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replaceExecutable 用 newPath 替换 exePath，旧版本保留为 .old (Windows 下运行中的程序只能重命名，不能删除)
+func replaceExecutable(exePath, newPath string) error {
+	oldPath := exePath + ".old"
+	os.Remove(oldPath)
+
+	if err := os.Rename(exePath, oldPath); err != nil {
+		return fmt.Errorf("备份当前程序失败: %v", err)
+	}
+	if err := os.Rename(newPath, exePath); err != nil {
+		// 回滚
+		if rollbackErr := os.Rename(oldPath, exePath); rollbackErr != nil {
+			return fmt.Errorf("%v (回滚失败: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// cleanupOldExecutable 删除上次自更新留下的旧版本程序
+func cleanupOldExecutable() {
+	exePath, err := os.Executable()
+	if err != nil {
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+	os.Remove(exePath + ".old")
+}
+
+// restartAgent 重启 Agent (重新加载手动修改的配置)，调用前任务结果应已确认送达
+// Unix 下原地 exec 当前程序 (PID 不变)，Windows 服务模式下退出并由服务恢复选项重新拉起
+func (a *AgentClient) restartAgent() {
+	exePath, err := os.Executable()
+	if err != nil {
+		logger.Warnf("[Agent] 获取程序路径失败，无法重启: %v", err)
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	logger.Infof("[Agent] 收到重启任务，正在重启...")
+	if !a.stop("restart") {
+		logger.Infof("[Agent] Agent 已在关闭中，忽略重启任务")
+		return
+	}
+	if err := restartSelf(exePath); err != nil {
+		logger.Warnf("[Agent] 重启失败，请手动重启: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestValidateSelfUpdateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		hosts   []string
+		wantErr bool
+	}{
+		{"https without host list", "https://example.com/agent", nil, false},
+		{"plain http", "http://example.com/agent", nil, true},
+		{"no host", "https:///agent", nil, true},
+		{"allowed host", "https://Downloads.Example.com/agent", []string{"downloads.example.com"}, false},
+		{"other host", "https://evil.example.net/agent", []string{"downloads.example.com"}, true},
+		{"allowed host over http", "http://downloads.example.com/agent", []string{"downloads.example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSelfUpdateURL(tt.url, tt.hosts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSelfUpdateURL(%q, %q) error = %v, wantErr %v", tt.url, tt.hosts, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSelf 以新版本程序替换当前进程 (PID 不变，systemd/launchd 不会认为服务退出)
+func restartSelf(exePath string) error {
+	return syscall.Exec(exePath, os.Args, os.Environ())
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// restartSelf Windows 不支持 exec 替换进程
+// 服务模式下以非零状态退出，由服务恢复选项 (失败后自动重启) 拉起新版本；普通模式下启动新进程后退出
+func restartSelf(exePath string) error {
+	if !IsRunningAsService() {
+		cmd := exec.Command(exePath, os.Args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		os.Exit(0)
+	}
+	os.Exit(1)
+	return nil
+}
@@ -14,6 +14,15 @@ const userSettings = require('../../src/services/userSettings');
 const { createLogger } = require('../../src/utils/logger');
 const logger = createLogger('AgentService');
 
+/**
+ * 定长时间比较两个字符串 (避免通过响应时间猜测密钥)
+ */
+function safeEqual(a, b) {
+  const bufA = Buffer.from(String(a));
+  const bufB = Buffer.from(String(b));
+  return bufA.length === bufB.length && crypto.timingSafeEqual(bufA, bufB);
+}
+
 class AgentService extends EventEmitter {
   constructor() {
     super();
@@ -35,6 +44,9 @@ class AgentService extends EventEmitter {
     // 实时状态缓存: serverId -> { state, timestamp }
     this.stateCache = new Map();
 
+    // 最近收到的任务结果 ID: serverId -> Set(result_id)，用于丢弃 Agent 未收到确认时重发的结果
+    this.recentTaskResults = new Map();
+
     // 心跳超时定时器: serverId -> timerId
     this.heartbeatTimers = new Map();
 
@@ -122,6 +134,46 @@ class AgentService extends EventEmitter {
     return providedKey === this.globalAgentKey;
   }
 
+  /**
+   * 为 Agent 连接生成一次性认证 nonce，随命名空间确认 (40/agent,{"sid":...,"nonce":...}) 下发
+   * 开启 authHmac 的 Agent 据此只发送 HMAC-SHA256(key, nonce)，密钥不经过网络
+   * @param {Object} socket - Socket.IO 连接 (命名空间中间件阶段，尚未发送 CONNECT 包)
+   */
+  attachAuthNonce(socket) {
+    const nonce = crypto.randomBytes(16).toString('hex');
+    socket.data.authNonce = nonce;
+
+    // Socket.IO 没有提供扩展 CONNECT 包的接口，只拦截第一个 CONNECT 包追加 nonce
+    const packet = socket.packet;
+    socket.packet = function (pkt, opts) {
+      if (pkt && pkt.type === 0 && pkt.data && typeof pkt.data === 'object') {
+        pkt.data.nonce = nonce;
+        socket.packet = packet;
+      }
+      return packet.call(this, pkt, opts);
+    };
+  }
+
+  /**
+   * 校验 Agent 认证数据
+   * 携带 signature 时按本连接下发的 nonce 校验 HMAC (nonce 只能使用一次)，否则比较明文密钥 (兼容旧版 Agent)
+   * @param {Object} socket - Socket.IO 连接
+   * @param {Object} data - AGENT_CONNECT 数据
+   */
+  verifyAgentAuth(socket, data) {
+    if (!data || !this.globalAgentKey) return false;
+
+    if (data.signature !== undefined) {
+      const nonce = socket.data.authNonce;
+      socket.data.authNonce = null;
+      if (!nonce || data.nonce !== nonce || typeof data.signature !== 'string') return false;
+      const expected = crypto.createHmac('sha256', this.globalAgentKey).update(nonce).digest('hex');
+      return safeEqual(data.signature, expected);
+    }
+
+    return typeof data.key === 'string' && safeEqual(data.key, this.globalAgentKey);
+  }
+
   /**
    * 获取当前连接的 Agent 数量
    */
@@ -182,10 +234,17 @@ class AgentService extends EventEmitter {
       },
       pingTimeout: 30000,
       pingInterval: 10000,
+      // permessage-deflate 作用于整个 Socket.IO 服务 (包括 /metrics 的前端连接)，每个连接常驻一份 zlib 上下文 (约 300KB)，
+      // 默认关闭；需要 Agent compression 时设置 AGENT_WS_COMPRESSION=true，小于阈值的消息不压缩
+      perMessageDeflate: process.env.AGENT_WS_COMPRESSION === 'true' ? { threshold: 1024 } : false,
     });
 
     // Agent 命名空间 - 处理 Agent 连接
     const agentNamespace = this.io.of('/agent');
+    agentNamespace.use((socket, next) => {
+      this.attachAuthNonce(socket);
+      next();
+    });
     agentNamespace.on('connection', socket => this.handleAgentConnection(socket));
 
     // Metrics 命名空间 - 处理前端订阅
@@ -353,8 +412,8 @@ class AgentService extends EventEmitter {
     socket.on(Events.AGENT_CONNECT, data => {
       clearTimeout(authTimeout);
 
-      // 验证密钥
-      if (!data || data.key !== this.globalAgentKey) {
+      // 验证密钥 (HMAC 签名或明文密钥)
+      if (!this.verifyAgentAuth(socket, data)) {
         console.warn('[AgentService] Agent 认证失败: 无效密钥');
         socket.emit(Events.DASHBOARD_AUTH_FAIL, { reason: 'Invalid key' });
         socket.disconnect();
@@ -416,6 +475,7 @@ class AgentService extends EventEmitter {
         server_time: Date.now(),
         heartbeat_interval: this.heartbeatTimeout / 2,
         resolved_id: serverId, // 告知 Agent 实际使用的 ID
+        task_result_ack: true, // 任务结果会被确认，Agent 未收到确认时才重发 (按 result_id 去重)
       });
 
       // 触发上线通知
@@ -507,8 +567,11 @@ class AgentService extends EventEmitter {
     });
 
     // 4. 接收任务结果
-    socket.on(Events.AGENT_TASK_RESULT, result => {
+    socket.on(Events.AGENT_TASK_RESULT, (result, ack) => {
       if (!authenticated) return;
+      // 确认收到，Agent 未收到确认时会重发
+      if (typeof ack === 'function') ack({ ok: true });
+      if (this.isDuplicateTaskResult(serverId, result)) return;
       this.log(`任务结果: ${serverId} -> ${result.id} (${result.successful ? '成功' : '失败'})`);
       // TODO: 处理任务结果 (日志记录、通知等)
     });
@@ -525,10 +588,24 @@ class AgentService extends EventEmitter {
       }
     });
 
+    // 7. PTY 会话关闭 (Shell 退出、被关闭或空闲超时)
+    socket.on(Events.AGENT_PTY_CLOSED, data => {
+      if (!authenticated) return;
+      this.log(`PTY 会话已关闭: ${serverId} -> ${data.id} (${data.reason})`);
+      this.emit(`pty_closed:${data.id}`, data.reason);
+    });
+
+    // Agent 主动退出前的通知 (随后会断开连接)
+    socket.on(Events.AGENT_DISCONNECT, data => {
+      if (!authenticated) return;
+      socket._shutdownReason = (data && data.reason) || 'shutdown';
+      this.log(`Agent 正在退出: ${serverId} (${socket._shutdownReason})`);
+    });
+
     // 5. 断开连接
     socket.on('disconnect', reason => {
       if (serverId) {
-        const msg = `Agent 离线: ${serverId} (${reason})`;
+        const msg = `Agent 离线: ${serverId} (${socket._shutdownReason ? `主动退出: ${socket._shutdownReason}` : reason})`;
         this.log(msg);
         // 如果是被新连接替换，不更新离线状态
         if (socket._isReplaced) {
@@ -847,6 +924,31 @@ class AgentService extends EventEmitter {
     return this.hostInfoCache.get(serverId) || null;
   }
 
+  /**
+   * 判断任务结果是否为重发的重复结果 (按 result_id，每台主机保留最近 200 个)
+   * @param {string} serverId
+   * @param {Object} result
+   * @returns {boolean}
+   */
+  isDuplicateTaskResult(serverId, result) {
+    const resultId = result && result.result_id;
+    if (!resultId) return false;
+
+    let seen = this.recentTaskResults.get(serverId);
+    if (!seen) {
+      seen = new Set();
+      this.recentTaskResults.set(serverId, seen);
+    }
+    if (seen.has(resultId)) return true;
+
+    seen.add(resultId);
+    if (seen.size > 200) {
+      // Set 按插入顺序迭代，删除最早的 ID
+      seen.delete(seen.values().next().value);
+    }
+    return false;
+  }
+
   /**
    * 发送任务并等待结果
    * @param {string} serverId
@@ -15,11 +15,14 @@ const Events = {
   // Dashboard -> Agent
   DASHBOARD_AUTH_OK: 'dashboard:auth_ok', // 认证成功
   DASHBOARD_AUTH_FAIL: 'dashboard:auth_fail', // 认证失败
+  AGENT_REAUTH_REQUIRED: 'agent:reauth_required', // 要求 Agent 在当前连接上重新认证 (如密钥轮换)
   DASHBOARD_TASK: 'dashboard:task', // 下发任务
   DASHBOARD_PING: 'dashboard:ping', // 心跳检测
   DASHBOARD_PTY_INPUT: 'dashboard:pty_input', // PTY 输入流
   DASHBOARD_PTY_RESIZE: 'dashboard:pty_resize', // PTY 窗口缩放
+  DASHBOARD_PTY_CLOSE: 'dashboard:pty_close', // 关闭 PTY 会话
   AGENT_PTY_DATA: 'agent:pty_data', // PTY 输出流
+  AGENT_PTY_CLOSED: 'agent:pty_closed', // PTY 会话已关闭 ({ id, reason: exited/closed/idle_timeout })
 
   // Dashboard -> Frontend (房间广播)
   METRICS_UPDATE: 'metrics:update', // 单个主机指标更新
@@ -55,6 +58,16 @@ const TaskTypes = {
   DOCKER_UPDATE_CONTAINER: 24, // 容器一键更新
   DOCKER_RENAME_CONTAINER: 25, // 容器重命名
   DOCKER_TASK_PROGRESS: 26, // 查询任务进度
+  METRIC_QUERY: 27, // 按需查询原始指标
+  PING: 28, // 可达性探测 (data: host:port 为 TCP，host 为 ICMP；delay 为往返延迟)
+  SELF_UPDATE: 29, // Agent 自更新 (data: { url, sha256 })，url 必须为 https，需要 Agent 开启 allowSelfUpdate，通过 agent:task_progress 反馈进度
+  PROCESS_LIST: 30, // 完整进程表 (data: { limit } 可选)，按 CPU 使用率降序
+  HTTP_CHECK: 31, // HTTP/HTTPS 探测 (data: { url, expect_status, follow_redirects })，error_type 区分 dns/tls/timeout/connect/status
+  SPEEDTEST: 32, // 带宽测速，返回 { download_mbps, upload_mbps, latency_ms }，有冷却时间
+  RESTART: 33, // 重启 Agent (重新加载配置)，返回结果后 Unix 原地重启，Windows 服务由恢复选项拉起
+  LOG_READ: 34, // 读取日志文件末尾 (data: { path, lines })，path 留空为 Agent 自身日志，只能读取 logReadAllowlist 中的文件
+  TRACEROUTE: 35, // 路由跟踪 (data: { target, max_hops } 或主机名)，返回每一跳的地址和延迟，需要 allowExec
+  DNS_LOOKUP: 36, // DNS 查询 (data: { host, type } 或域名，type 为 A/AAAA/MX/TXT/CNAME)，error_type 区分 nxdomain/timeout/error
 };
 
 // ==================== 数据结构 ====================
@@ -126,7 +139,7 @@ const TaskSchema = {
   id: '', // 任务 ID
   type: 0, // 任务类型 (TaskTypes)
   data: '', // 任务数据 (JSON 字符串或命令)
-  timeout: 0, // 超时时间 (秒, 0 表示无限)
+  timeout: 0, // 超时时间 (秒, 0 表示使用 Agent 默认值 30 秒)
 };
 
 /**
@@ -139,6 +152,7 @@ const TaskResultSchema = {
   successful: false, // 是否成功
   data: '', // 执行结果或错误信息
   delay: 0, // 执行耗时 (毫秒)
+  reason: '', // 失败原因 (可选，超时为 'timeout')
 };
 
 // ==================== 工具函数 ====================
@@ -72,6 +72,16 @@ class SSHService {
                 agentService.on(`pty:${taskId}`, ptyOutputHandler);
                 ws._ptyOutputHandler = ptyOutputHandler;
 
+                // Agent 端会话结束 (Shell 退出或空闲超时) 时通知前端
+                const ptyClosedHandler = reason => {
+                  if (ws.readyState === ws.OPEN) {
+                    const message = reason === 'idle_timeout' ? '终端空闲超时，会话已关闭' : '终端会话已结束';
+                    ws.send(JSON.stringify({ type: 'error', message }));
+                  }
+                };
+                agentService.once(`pty_closed:${taskId}`, ptyClosedHandler);
+                ws._ptyClosedHandler = ptyClosedHandler;
+
                 // 下发启动 PTY 任务
                 agentService.sendTask(serverId, {
                   id: taskId,
@@ -199,6 +209,15 @@ class SSHService {
           if (ws._ptyOutputHandler) {
             agentService.off(`pty:${ws._taskId}`, ws._ptyOutputHandler);
           }
+          if (ws._ptyClosedHandler) {
+            agentService.off(`pty_closed:${ws._taskId}`, ws._ptyClosedHandler);
+          }
+          // 通知 Agent 关闭对应的终端进程
+          const { Events } = require('./protocol');
+          const socket = agentService.connections.get(ws._serverId);
+          if (socket) {
+            socket.emit(Events.DASHBOARD_PTY_CLOSE, { id: ws._taskId });
+          }
         }
         logger.info('SSH/Agent WebSocket 连接已关闭');
       });